@@ -0,0 +1,1035 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	owcrypt "github.com/blocktree/go-owcrypt"
+	"github.com/tidwall/gjson"
+)
+
+//abiKind Solidity类型分类
+type abiKind int
+
+const (
+	abiKindUint abiKind = iota
+	abiKindInt
+	abiKindBool
+	abiKindAddress
+	abiKindFixedBytes
+	abiKindBytes
+	abiKindString
+	abiKindSlice
+	abiKindArray
+	abiKindTuple
+)
+
+var (
+	abiIntRegexp   = regexp.MustCompile(`^(u?)int(\d*)$`)
+	abiBytesRegexp = regexp.MustCompile(`^bytes(\d+)$`)
+)
+
+//ABIArgument 方法、事件、错误的参数，与合约ABI JSON一致
+type ABIArgument struct {
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	Indexed    bool          `json:"indexed,omitempty"`
+	Components []ABIArgument `json:"components,omitempty"`
+}
+
+//ABIType 解析后的Solidity类型
+type ABIType struct {
+	kind       abiKind
+	size       int        //intN/uintN的位数，bytesN的字节数，T[k]的长度
+	elem       *ABIType   //数组元素类型
+	components []*ABIType //元组成员类型
+	names      []string   //元组成员名称
+	alias      string     //签名中使用的类型名，如trcToken
+}
+
+//NewABIType 解析类型，元组可写为tuple加components，或签名形式(T1,T2)
+func NewABIType(typ string, components []ABIArgument) (*ABIType, error) {
+
+	typ = strings.TrimSpace(typ)
+
+	//数组后缀从右往左解析，uint256[2][]是uint256[2]的动态数组
+	if strings.HasSuffix(typ, "]") {
+		i := strings.LastIndex(typ, "[")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid type: %s", typ)
+		}
+		elem, err := NewABIType(typ[:i], components)
+		if err != nil {
+			return nil, err
+		}
+		length := typ[i+1 : len(typ)-1]
+		if len(length) == 0 {
+			return &ABIType{kind: abiKindSlice, elem: elem}, nil
+		}
+		size, err := strconv.Atoi(length)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid array length: %s", typ)
+		}
+		return &ABIType{kind: abiKindArray, elem: elem, size: size}, nil
+	}
+
+	if strings.HasPrefix(typ, "(") && strings.HasSuffix(typ, ")") {
+		t := &ABIType{kind: abiKindTuple}
+		parts, err := splitABITypes(typ[1 : len(typ)-1])
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range parts {
+			c, err := NewABIType(part, nil)
+			if err != nil {
+				return nil, err
+			}
+			t.components = append(t.components, c)
+			t.names = append(t.names, "")
+		}
+		return t, nil
+	}
+
+	switch typ {
+	case "tuple":
+		t := &ABIType{kind: abiKindTuple}
+		for _, arg := range components {
+			c, err := NewABIType(arg.Type, arg.Components)
+			if err != nil {
+				return nil, err
+			}
+			t.components = append(t.components, c)
+			t.names = append(t.names, arg.Name)
+		}
+		return t, nil
+	case "bool":
+		return &ABIType{kind: abiKindBool}, nil
+	case "address":
+		return &ABIType{kind: abiKindAddress}, nil
+	case "string":
+		return &ABIType{kind: abiKindString}, nil
+	case "bytes":
+		return &ABIType{kind: abiKindBytes}, nil
+	case "trcToken":
+		return &ABIType{kind: abiKindUint, size: 256, alias: "trcToken"}, nil
+	case "function":
+		return &ABIType{kind: abiKindFixedBytes, size: 24, alias: "function"}, nil
+	}
+
+	if m := abiIntRegexp.FindStringSubmatch(typ); m != nil {
+		bits := 256
+		if len(m[2]) > 0 {
+			bits, _ = strconv.Atoi(m[2])
+		}
+		if bits < 8 || bits > 256 || bits%8 != 0 {
+			return nil, fmt.Errorf("invalid type: %s", typ)
+		}
+		if m[1] == "u" {
+			return &ABIType{kind: abiKindUint, size: bits}, nil
+		}
+		return &ABIType{kind: abiKindInt, size: bits}, nil
+	}
+	if m := abiBytesRegexp.FindStringSubmatch(typ); m != nil {
+		size, _ := strconv.Atoi(m[1])
+		if size < 1 || size > 32 {
+			return nil, fmt.Errorf("invalid type: %s", typ)
+		}
+		return &ABIType{kind: abiKindFixedBytes, size: size}, nil
+	}
+	return nil, fmt.Errorf("unsupported type: %s", typ)
+}
+
+//splitABITypes 按顶层逗号拆分类型列表
+func splitABITypes(s string) ([]string, error) {
+	parts := make([]string, 0)
+	if len(strings.TrimSpace(s)) == 0 {
+		return parts, nil
+	}
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses: %s", s)
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses: %s", s)
+	}
+	return append(parts, s[start:]), nil
+}
+
+//String 签名中的规范类型名
+func (t *ABIType) String() string {
+	if len(t.alias) > 0 {
+		return t.alias
+	}
+	switch t.kind {
+	case abiKindUint:
+		return "uint" + strconv.Itoa(t.size)
+	case abiKindInt:
+		return "int" + strconv.Itoa(t.size)
+	case abiKindBool:
+		return "bool"
+	case abiKindAddress:
+		return "address"
+	case abiKindFixedBytes:
+		return "bytes" + strconv.Itoa(t.size)
+	case abiKindBytes:
+		return "bytes"
+	case abiKindString:
+		return "string"
+	case abiKindSlice:
+		return t.elem.String() + "[]"
+	case abiKindArray:
+		return t.elem.String() + "[" + strconv.Itoa(t.size) + "]"
+	}
+	names := make([]string, 0, len(t.components))
+	for _, c := range t.components {
+		names = append(names, c.String())
+	}
+	return "(" + strings.Join(names, ",") + ")"
+}
+
+//isDynamic 是否为动态类型，动态类型在头部只写偏移量
+func (t *ABIType) isDynamic() bool {
+	switch t.kind {
+	case abiKindBytes, abiKindString, abiKindSlice:
+		return true
+	case abiKindArray:
+		return t.elem.isDynamic()
+	case abiKindTuple:
+		for _, c := range t.components {
+			if c.isDynamic() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//headSize 在头部占用的字节数
+func (t *ABIType) headSize() int {
+	if t.isDynamic() {
+		return 32
+	}
+	switch t.kind {
+	case abiKindArray:
+		return t.size * t.elem.headSize()
+	case abiKindTuple:
+		size := 0
+		for _, c := range t.components {
+			size += c.headSize()
+		}
+		return size
+	}
+	return 32
+}
+
+//EncodeABI 按类型编码参数，不含方法ID
+func EncodeABI(types []*ABIType, values []interface{}) ([]byte, error) {
+
+	if len(types) != len(values) {
+		return nil, fmt.Errorf("expect %d values, got %d", len(types), len(values))
+	}
+
+	headSize := 0
+	for _, t := range types {
+		headSize += t.headSize()
+	}
+	head := make([]byte, 0, headSize)
+	tail := make([]byte, 0)
+	for i, t := range types {
+		encoded, err := t.encode(values[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s value %d: %v", t.String(), i, err)
+		}
+		if t.isDynamic() {
+			head = append(head, abiWord(big.NewInt(int64(headSize+len(tail))))...)
+			tail = append(tail, encoded...)
+		} else {
+			head = append(head, encoded...)
+		}
+	}
+	return append(head, tail...), nil
+}
+
+//encode 编码单个值
+func (t *ABIType) encode(value interface{}) ([]byte, error) {
+
+	switch t.kind {
+	case abiKindUint, abiKindInt:
+		n, err := abiInt(value)
+		if err != nil {
+			return nil, err
+		}
+		return t.encodeInt(n)
+	case abiKindBool:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("bool value should be a bool")
+		}
+		if b {
+			return abiWord(big.NewInt(1)), nil
+		}
+		return abiWord(big.NewInt(0)), nil
+	case abiKindAddress:
+		var addr []byte
+		switch v := value.(type) {
+		case string:
+			a, err := abiAddress(v)
+			if err != nil {
+				return nil, err
+			}
+			addr = a
+		case []byte:
+			if len(v) != 20 && len(v) != 21 {
+				return nil, fmt.Errorf("address should be 20 bytes")
+			}
+			addr = v[len(v)-20:]
+		default:
+			return nil, fmt.Errorf("address value should be a string")
+		}
+		return append(make([]byte, 12), addr...), nil
+	case abiKindFixedBytes:
+		b, err := abiBytesValue(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > t.size {
+			return nil, fmt.Errorf("%s value has %d bytes", t.String(), len(b))
+		}
+		return append(b, make([]byte, 32-len(b))...), nil
+	case abiKindBytes, abiKindString:
+		var b []byte
+		if t.kind == abiKindString {
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("string value should be a string")
+			}
+			b = []byte(s)
+		} else {
+			v, err := abiBytesValue(value)
+			if err != nil {
+				return nil, err
+			}
+			b = v
+		}
+		encoded := abiWord(big.NewInt(int64(len(b))))
+		encoded = append(encoded, b...)
+		if pad := len(b) % 32; pad > 0 {
+			encoded = append(encoded, make([]byte, 32-pad)...)
+		}
+		return encoded, nil
+	case abiKindSlice, abiKindArray:
+		items, ok := abiSliceValue(value)
+		if !ok {
+			return nil, fmt.Errorf("%s value should be an array", t.String())
+		}
+		if t.kind == abiKindArray && len(items) != t.size {
+			return nil, fmt.Errorf("%s value has %d items", t.String(), len(items))
+		}
+		types := make([]*ABIType, len(items))
+		for i := range types {
+			types[i] = t.elem
+		}
+		encoded, err := EncodeABI(types, items)
+		if err != nil {
+			return nil, err
+		}
+		if t.kind == abiKindSlice {
+			encoded = append(abiWord(big.NewInt(int64(len(items)))), encoded...)
+		}
+		return encoded, nil
+	}
+
+	//元组可以是按顺序的数组，或按成员名称的map
+	items, ok := abiSliceValue(value)
+	if !ok {
+		m, isMap := value.(map[string]interface{})
+		if !isMap {
+			return nil, fmt.Errorf("tuple value should be an array or object")
+		}
+		items = make([]interface{}, len(t.components))
+		for i, name := range t.names {
+			v, exist := m[name]
+			if !exist {
+				return nil, fmt.Errorf("tuple member: %s is missing", name)
+			}
+			items[i] = v
+		}
+	}
+	return EncodeABI(t.components, items)
+}
+
+//encodeInt 整数编码，检查位数，负数为补码
+func (t *ABIType) encodeInt(n *big.Int) ([]byte, error) {
+	if t.kind == abiKindUint {
+		if n.Sign() < 0 || n.BitLen() > t.size {
+			return nil, fmt.Errorf("%s value: %s is out of range", t.String(), n.String())
+		}
+		return abiWord(n), nil
+	}
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(t.size-1))
+	if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+		return nil, fmt.Errorf("%s value: %s is out of range", t.String(), n.String())
+	}
+	if n.Sign() < 0 {
+		n = new(big.Int).Add(n, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return abiWord(n), nil
+}
+
+//DecodeABI 按类型解码数据，uint/int为*big.Int，address为base58地址，bytes/bytesN为[]byte，数组和元组为[]interface{}
+func DecodeABI(types []*ABIType, data []byte, isTestnet bool) ([]interface{}, error) {
+
+	values := make([]interface{}, 0, len(types))
+	pos := 0
+	for _, t := range types {
+		var (
+			value interface{}
+			err   error
+		)
+		if t.isDynamic() {
+			offset, err := abiReadLength(data, pos)
+			if err != nil {
+				return nil, err
+			}
+			if offset > len(data) {
+				return nil, fmt.Errorf("offset %d is out of range", offset)
+			}
+			value, err = t.decode(data[offset:], isTestnet)
+			if err != nil {
+				return nil, err
+			}
+			pos += 32
+		} else {
+			if pos+t.headSize() > len(data) {
+				return nil, fmt.Errorf("data is too short for %s", t.String())
+			}
+			value, err = t.decode(data[pos:], isTestnet)
+			if err != nil {
+				return nil, err
+			}
+			pos += t.headSize()
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+//decode 解码单个值，data从该值的起始位置开始
+func (t *ABIType) decode(data []byte, isTestnet bool) (interface{}, error) {
+
+	switch t.kind {
+	case abiKindUint, abiKindInt, abiKindBool, abiKindAddress, abiKindFixedBytes:
+		if len(data) < 32 {
+			return nil, fmt.Errorf("data is too short for %s", t.String())
+		}
+	}
+
+	switch t.kind {
+	case abiKindUint:
+		n := new(big.Int).SetBytes(data[:32])
+		if n.BitLen() > t.size {
+			return nil, fmt.Errorf("%s value is out of range", t.String())
+		}
+		return n, nil
+	case abiKindInt:
+		n := new(big.Int).SetBytes(data[:32])
+		if data[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(t.size-1))
+		if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, fmt.Errorf("%s value is out of range", t.String())
+		}
+		return n, nil
+	case abiKindBool:
+		n := new(big.Int).SetBytes(data[:32])
+		if n.Cmp(big.NewInt(1)) > 0 {
+			return nil, fmt.Errorf("invalid bool value")
+		}
+		return n.Sign() == 1, nil
+	case abiKindAddress:
+		//高12字节可能是旧版编码写入的0x41，忽略
+		return EncodeAddress(hex.EncodeToString(data[12:32]), isTestnet)
+	case abiKindFixedBytes:
+		return append([]byte{}, data[:t.size]...), nil
+	case abiKindBytes, abiKindString:
+		size, err := abiReadLength(data, 0)
+		if err != nil {
+			return nil, err
+		}
+		if 32+size > len(data) {
+			return nil, fmt.Errorf("data is too short for %s", t.String())
+		}
+		b := append([]byte{}, data[32:32+size]...)
+		if t.kind == abiKindString {
+			return string(b), nil
+		}
+		return b, nil
+	case abiKindSlice, abiKindArray:
+		size := t.size
+		if t.kind == abiKindSlice {
+			n, err := abiReadLength(data, 0)
+			if err != nil {
+				return nil, err
+			}
+			data = data[32:]
+			if n*t.elem.headSize() > len(data) {
+				return nil, fmt.Errorf("data is too short for %d items of %s", n, t.elem.String())
+			}
+			size = n
+		}
+		types := make([]*ABIType, size)
+		for i := range types {
+			types[i] = t.elem
+		}
+		return DecodeABI(types, data, isTestnet)
+	}
+	return DecodeABI(t.components, data, isTestnet)
+}
+
+//abiReadLength 读取偏移量或长度
+func abiReadLength(data []byte, pos int) (int, error) {
+	if pos+32 > len(data) {
+		return 0, fmt.Errorf("data is too short")
+	}
+	n := new(big.Int).SetBytes(data[pos : pos+32])
+	if !n.IsInt64() || n.Int64() > int64(len(data)) {
+		return 0, fmt.Errorf("length %s is out of range", n.String())
+	}
+	return int(n.Int64()), nil
+}
+
+//abiWord 大端32字节
+func abiWord(n *big.Int) []byte {
+	word := make([]byte, 32)
+	b := n.Bytes()
+	copy(word[32-len(b):], b)
+	return word
+}
+
+//abiKindInt 数字可以是*big.Int、整数、JSON数字、十进制或0x开头的十六进制字符串
+func abiInt(value interface{}) (*big.Int, error) {
+	var s string
+	switch v := value.(type) {
+	case *big.Int:
+		return v, nil
+	case json.Number:
+		s = v.String()
+	case string:
+		s = v
+	case float64:
+		if v != float64(int64(v)) {
+			return nil, fmt.Errorf("integer value: %v is not an integer", v)
+		}
+		return big.NewInt(int64(v)), nil
+	case int:
+		return big.NewInt(int64(v)), nil
+	case int64:
+		return big.NewInt(v), nil
+	case int32:
+		return big.NewInt(int64(v)), nil
+	case uint64:
+		return new(big.Int).SetUint64(v), nil
+	case uint32:
+		return big.NewInt(int64(v)), nil
+	default:
+		return nil, fmt.Errorf("integer value: %v is not a number", value)
+	}
+
+	n, ok := new(big.Int), false
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		n, ok = n.SetString(s[2:], 16)
+	} else {
+		n, ok = n.SetString(s, 10)
+	}
+	if !ok {
+		return nil, fmt.Errorf("integer value: %s is not a number", s)
+	}
+	return n, nil
+}
+
+//abiBytesValue 字节值为[]byte或0x开头的十六进制字符串
+func abiBytesValue(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case []byte:
+		return append([]byte{}, v...), nil
+	case string:
+		return hex.DecodeString(strings.TrimPrefix(v, "0x"))
+	}
+	return nil, fmt.Errorf("bytes value should be []byte or a hex string")
+}
+
+//abiSliceValue 任意切片或数组转为[]interface{}，[]byte不视为数组
+func abiSliceValue(value interface{}) ([]interface{}, bool) {
+	if items, ok := value.([]interface{}); ok {
+		return items, true
+	}
+	v := reflect.ValueOf(value)
+	if value == nil || (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	items := make([]interface{}, v.Len())
+	for i := range items {
+		items[i] = v.Index(i).Interface()
+	}
+	return items, true
+}
+
+//abiKindAddress 地址转20字节，支持base58地址、41(测试网a0)开头的hex和20字节hex
+func abiAddress(s string) ([]byte, error) {
+	h := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if b, err := hex.DecodeString(h); err == nil {
+		if len(b) == 20 {
+			return b, nil
+		}
+		if len(b) == 21 && (b[0] == 0x41 || b[0] == 0xa0) {
+			return b[1:], nil
+		}
+	}
+	for _, isTestnet := range []bool{false, true} {
+		if _, addr, err := DecodeAddress(s, isTestnet); err == nil {
+			return addr[1:], nil
+		}
+	}
+	return nil, fmt.Errorf("invalid address: %s", s)
+}
+
+//ParseMethodSignature 解析方法签名，如transfer(address,uint256)
+func ParseMethodSignature(signature string) (string, []*ABIType, error) {
+	i := strings.Index(signature, "(")
+	if i <= 0 || !strings.HasSuffix(signature, ")") {
+		return "", nil, fmt.Errorf("invalid method signature: %s", signature)
+	}
+	tuple, err := NewABIType(signature[i:], nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return signature[:i], tuple.components, nil
+}
+
+//EncodeCall 按方法签名编码调用数据，方法ID + 参数
+func EncodeCall(signature string, args ...interface{}) ([]byte, error) {
+	name, types, err := ParseMethodSignature(signature)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := EncodeABI(types, args)
+	if err != nil {
+		return nil, err
+	}
+	return append(abiSelector(name, types), encoded...), nil
+}
+
+//abiSelector 方法ID，规范签名Keccak-256的前4字节
+func abiSelector(name string, types []*ABIType) []byte {
+	return abiSignatureHash(name, types)[:4]
+}
+
+func abiSignatureHash(name string, types []*ABIType) []byte {
+	names := make([]string, 0, len(types))
+	for _, t := range types {
+		names = append(names, t.String())
+	}
+	return owcrypt.Hash([]byte(name+"("+strings.Join(names, ",")+")"), 0, owcrypt.HASH_ALG_KECCAK256)
+}
+
+//ABIEntry 合约ABI中的方法、事件、错误等条目
+type ABIEntry struct {
+	Type            string        `json:"type"`
+	Name            string        `json:"name"`
+	Inputs          []ABIArgument `json:"inputs"`
+	Outputs         []ABIArgument `json:"outputs"`
+	Anonymous       bool          `json:"anonymous,omitempty"`
+	Constant        bool          `json:"constant,omitempty"`
+	Payable         bool          `json:"payable,omitempty"`
+	StateMutability string        `json:"stateMutability,omitempty"`
+
+	inputs  []*ABIType
+	outputs []*ABIType
+}
+
+//Signature 规范签名
+func (e *ABIEntry) Signature() string {
+	names := make([]string, 0, len(e.inputs))
+	for _, t := range e.inputs {
+		names = append(names, t.String())
+	}
+	return e.Name + "(" + strings.Join(names, ",") + ")"
+}
+
+//ID 方法、错误为签名哈希前4字节，事件为完整的32字节topic
+func (e *ABIEntry) ID() []byte {
+	hash := abiSignatureHash(e.Name, e.inputs)
+	if e.Type == "event" {
+		return hash
+	}
+	return hash[:4]
+}
+
+//ParseArguments 字符串参数转为编码值，数组和元组参数为JSON，bool为true/false，其他类型保持字符串
+func (e *ABIEntry) ParseArguments(args []string) ([]interface{}, error) {
+	if len(args) != len(e.inputs) {
+		return nil, fmt.Errorf("%s expects %d arguments, got %d", e.Signature(), len(e.inputs), len(args))
+	}
+	values := make([]interface{}, 0, len(args))
+	for i, t := range e.inputs {
+		switch t.kind {
+		case abiKindBool:
+			b, err := strconv.ParseBool(args[i])
+			if err != nil {
+				return nil, fmt.Errorf("argument %d: %v", i, err)
+			}
+			values = append(values, b)
+		case abiKindSlice, abiKindArray, abiKindTuple:
+			var v interface{}
+			decoder := json.NewDecoder(strings.NewReader(args[i]))
+			decoder.UseNumber()
+			if err := decoder.Decode(&v); err != nil {
+				return nil, fmt.Errorf("argument %d should be JSON: %v", i, err)
+			}
+			values = append(values, v)
+		default:
+			values = append(values, args[i])
+		}
+	}
+	return values, nil
+}
+
+//ABI 合约ABI
+type ABI struct {
+	Entries   []*ABIEntry
+	IsTestNet bool //解码地址使用的网络
+}
+
+//ParseABI 解析ABI JSON，支持数组以及getcontract返回的{"entrys": [...]}，类型名不区分大小写
+func ParseABI(data string, isTestnet bool) (*ABI, error) {
+
+	raw := gjson.Parse(data)
+	if entrys := raw.Get("entrys"); entrys.Exists() {
+		raw = entrys
+	}
+	if !raw.IsArray() {
+		return nil, fmt.Errorf("ABI should be an array")
+	}
+
+	abi := &ABI{IsTestNet: isTestnet}
+	if err := json.Unmarshal([]byte(raw.Raw), &abi.Entries); err != nil {
+		return nil, err
+	}
+	for _, e := range abi.Entries {
+		e.Type = strings.ToLower(e.Type)
+		if len(e.Type) == 0 {
+			e.Type = "function"
+		}
+		for _, arg := range e.Inputs {
+			t, err := NewABIType(arg.Type, arg.Components)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %v", e.Type, e.Name, err)
+			}
+			e.inputs = append(e.inputs, t)
+		}
+		for _, arg := range e.Outputs {
+			t, err := NewABIType(arg.Type, arg.Components)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %v", e.Type, e.Name, err)
+			}
+			e.outputs = append(e.outputs, t)
+		}
+	}
+	return abi, nil
+}
+
+//Method 按名称或完整签名查找方法，重载的方法需要使用完整签名
+func (abi *ABI) Method(name string) (*ABIEntry, error) {
+	var found *ABIEntry
+	for _, e := range abi.Entries {
+		if e.Type != "function" {
+			continue
+		}
+		if e.Signature() == name {
+			return e, nil
+		}
+		if e.Name == name {
+			if found != nil {
+				return nil, fmt.Errorf("method: %s is overloaded, use the full signature", name)
+			}
+			found = e
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("method: %s is not found", name)
+	}
+	return found, nil
+}
+
+//Pack 编码方法调用数据
+func (abi *ABI) Pack(method string, args ...interface{}) ([]byte, error) {
+	e, err := abi.Method(method)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := EncodeABI(e.inputs, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", e.Signature(), err)
+	}
+	return append(e.ID(), encoded...), nil
+}
+
+//Unpack 解码方法返回数据
+func (abi *ABI) Unpack(method string, data []byte) ([]interface{}, error) {
+	e, err := abi.Method(method)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeABI(e.outputs, data, abi.IsTestNet)
+}
+
+//DecodeCall 按方法ID解码调用数据
+func (abi *ABI) DecodeCall(data []byte) (*ABIEntry, []interface{}, error) {
+	if len(data) < 4 {
+		return nil, nil, fmt.Errorf("call data is too short")
+	}
+	for _, e := range abi.Entries {
+		if e.Type == "function" && string(e.ID()) == string(data[:4]) {
+			values, err := DecodeABI(e.inputs, data[4:], abi.IsTestNet)
+			return e, values, err
+		}
+	}
+	return nil, nil, fmt.Errorf("method id: %x is not found", data[:4])
+}
+
+//DecodeEvent 解码事件日志，topics和data为hex。
+//indexed的动态类型参数在topic中只有哈希，解码为[]byte
+func (abi *ABI) DecodeEvent(topics []string, data string) (*ABIEntry, map[string]interface{}, error) {
+
+	topicBytes := make([][]byte, 0, len(topics))
+	for _, topic := range topics {
+		b, err := hex.DecodeString(strings.TrimPrefix(topic, "0x"))
+		if err != nil || len(b) != 32 {
+			return nil, nil, fmt.Errorf("invalid topic: %s", topic)
+		}
+		topicBytes = append(topicBytes, b)
+	}
+	dataBytes, err := hex.DecodeString(strings.TrimPrefix(data, "0x"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, e := range abi.Entries {
+		if e.Type != "event" {
+			continue
+		}
+		indexed := topicBytes
+		if !e.Anonymous {
+			if len(topicBytes) == 0 || string(topicBytes[0]) != string(e.ID()) {
+				continue
+			}
+			indexed = topicBytes[1:]
+		}
+		values, err := abi.decodeEventArgs(e, indexed, dataBytes)
+		if err != nil {
+			if e.Anonymous {
+				continue
+			}
+			return nil, nil, err
+		}
+		return e, values, nil
+	}
+	return nil, nil, fmt.Errorf("event is not found")
+}
+
+func (abi *ABI) decodeEventArgs(e *ABIEntry, topics [][]byte, data []byte) (map[string]interface{}, error) {
+
+	var (
+		values      = make(map[string]interface{})
+		dataTypes   = make([]*ABIType, 0)
+		dataNames   = make([]string, 0)
+		topicCursor = 0
+	)
+	for i, arg := range e.Inputs {
+		name := arg.Name
+		if len(name) == 0 {
+			name = "arg" + strconv.Itoa(i)
+		}
+		if !arg.Indexed {
+			dataTypes = append(dataTypes, e.inputs[i])
+			dataNames = append(dataNames, name)
+			continue
+		}
+		if topicCursor >= len(topics) {
+			return nil, fmt.Errorf("event %s has too few topics", e.Name)
+		}
+		topic := topics[topicCursor]
+		topicCursor++
+		t := e.inputs[i]
+		if t.isDynamic() || t.kind == abiKindArray || t.kind == abiKindTuple {
+			values[name] = topic
+			continue
+		}
+		v, err := t.decode(topic, abi.IsTestNet)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = v
+	}
+	if topicCursor != len(topics) {
+		return nil, fmt.Errorf("event %s has %d indexed arguments, got %d topics", e.Name, topicCursor, len(topics))
+	}
+
+	decoded, err := DecodeABI(dataTypes, data, abi.IsTestNet)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range decoded {
+		values[dataNames[i]] = v
+	}
+	return values, nil
+}
+
+//DecodeRevert 解析revert数据，支持Error(string)、Panic(uint256)和ABI中声明的自定义错误
+func (abi *ABI) DecodeRevert(data string) (string, bool) {
+
+	if reason, ok := DecodeRevertReason(data); ok {
+		return reason, true
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(data, "0x"))
+	if err != nil || len(b) < 4 {
+		return "", false
+	}
+	for _, e := range abi.Entries {
+		if e.Type != "error" || string(e.ID()) != string(b[:4]) {
+			continue
+		}
+		values, err := DecodeABI(e.inputs, b[4:], abi.IsTestNet)
+		if err != nil {
+			return "", false
+		}
+		args := make([]string, 0, len(values))
+		for _, v := range values {
+			args = append(args, formatABIValue(v))
+		}
+		return e.Name + "(" + strings.Join(args, ", ") + ")", true
+	}
+	return "", false
+}
+
+//ABIJSONValue 解码后的值转为可JSON序列化的形式，整数为十进制字符串，字节为0x开头的hex
+func ABIJSONValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case *big.Int:
+		return value.String()
+	case []byte:
+		return "0x" + hex.EncodeToString(value)
+	case []interface{}:
+		items := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			items = append(items, ABIJSONValue(item))
+		}
+		return items
+	case map[string]interface{}:
+		fields := make(map[string]interface{}, len(value))
+		for name, item := range value {
+			fields[name] = ABIJSONValue(item)
+		}
+		return fields
+	}
+	return v
+}
+
+//formatABIValue 以可读形式显示解码后的值
+func formatABIValue(v interface{}) string {
+	switch value := v.(type) {
+	case []byte:
+		return "0x" + hex.EncodeToString(value)
+	case []interface{}:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, formatABIValue(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+//GetContractABI 获取合约ABI
+func (wm *WalletManager) GetContractABI(contractAddress string) (*ABI, error) {
+	info, err := wm.GetContractInfo(contractAddress)
+	if err != nil {
+		return nil, err
+	}
+	if len(info.ABI) == 0 {
+		return nil, fmt.Errorf("contract: %s has no ABI", contractAddress)
+	}
+	return ParseABI(info.ABI, wm.Config.IsTestNet)
+}
+
+//CallConstantContract 通过triggerconstantcontract调用只读方法并解码返回值
+func (wm *WalletManager) CallConstantContract(contractAddress, ownerAddress string, abi *ABI, method string, args ...interface{}) ([]interface{}, error) {
+
+	data, err := abi.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	contractHex, _, err := DecodeAddress(contractAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return nil, err
+	}
+	ownerHex, _, err := DecodeAddress(ownerAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return nil, err
+	}
+	result, err := wm.TriggerConstantContract(contractHex, hex.EncodeToString(data), 0, ownerHex)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range result.ConstantResult {
+		if reason, ok := abi.DecodeRevert(c); ok {
+			return nil, fmt.Errorf("contract call reverted: %s", reason)
+		}
+	}
+	if reason, reverted := result.RevertReason(); reverted {
+		return nil, fmt.Errorf("contract call reverted: %s", reason)
+	}
+	if len(result.ConstantResult) == 0 {
+		return nil, fmt.Errorf("contract call returns nothing")
+	}
+	output, err := hex.DecodeString(result.ConstantResult[0])
+	if err != nil {
+		return nil, err
+	}
+	return abi.Unpack(method, output)
+}
@@ -0,0 +1,315 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//Solidity ABI规范中的示例
+func TestEncodeCall_SpecVectors(t *testing.T) {
+
+	tests := []struct {
+		signature string
+		args      []interface{}
+		expect    string
+	}{
+		{
+			"baz(uint32,bool)",
+			[]interface{}{69, true},
+			"cdcd77c0" +
+				"0000000000000000000000000000000000000000000000000000000000000045" +
+				"0000000000000000000000000000000000000000000000000000000000000001",
+		},
+		{
+			"bar(bytes3[2])",
+			[]interface{}{[]interface{}{[]byte("abc"), []byte("def")}},
+			"fce353f6" +
+				"6162630000000000000000000000000000000000000000000000000000000000" +
+				"6465660000000000000000000000000000000000000000000000000000000000",
+		},
+		{
+			"sam(bytes,bool,uint256[])",
+			[]interface{}{[]byte("dave"), true, []int{1, 2, 3}},
+			"a5643bf2" +
+				"0000000000000000000000000000000000000000000000000000000000000060" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"00000000000000000000000000000000000000000000000000000000000000a0" +
+				"0000000000000000000000000000000000000000000000000000000000000004" +
+				"6461766500000000000000000000000000000000000000000000000000000000" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000003",
+		},
+		{
+			"f(uint256,uint32[],bytes10,bytes)",
+			[]interface{}{"0x123", []interface{}{"0x456", "0x789"}, []byte("1234567890"), []byte("Hello, world!")},
+			"8be65246" +
+				"0000000000000000000000000000000000000000000000000000000000000123" +
+				"0000000000000000000000000000000000000000000000000000000000000080" +
+				"3132333435363738393000000000000000000000000000000000000000000000" +
+				"00000000000000000000000000000000000000000000000000000000000000e0" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000456" +
+				"0000000000000000000000000000000000000000000000000000000000000789" +
+				"000000000000000000000000000000000000000000000000000000000000000d" +
+				"48656c6c6f2c20776f726c642100000000000000000000000000000000000000",
+		},
+		{
+			"g(uint256[][],string[])",
+			[]interface{}{[]interface{}{[]int{1, 2}, []int{3}}, []string{"one", "two", "three"}},
+			"2289b18c" +
+				"0000000000000000000000000000000000000000000000000000000000000040" +
+				"0000000000000000000000000000000000000000000000000000000000000140" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000040" +
+				"00000000000000000000000000000000000000000000000000000000000000a0" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"0000000000000000000000000000000000000000000000000000000000000002" +
+				"0000000000000000000000000000000000000000000000000000000000000001" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"0000000000000000000000000000000000000000000000000000000000000060" +
+				"00000000000000000000000000000000000000000000000000000000000000a0" +
+				"00000000000000000000000000000000000000000000000000000000000000e0" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"6f6e650000000000000000000000000000000000000000000000000000000000" +
+				"0000000000000000000000000000000000000000000000000000000000000003" +
+				"74776f0000000000000000000000000000000000000000000000000000000000" +
+				"0000000000000000000000000000000000000000000000000000000000000005" +
+				"7468726565000000000000000000000000000000000000000000000000000000",
+		},
+	}
+
+	for _, test := range tests {
+		data, err := EncodeCall(test.signature, test.args...)
+		if err != nil {
+			t.Errorf("EncodeCall %s failed: %v", test.signature, err)
+			continue
+		}
+		if hex.EncodeToString(data) != test.expect {
+			t.Errorf("EncodeCall %s = %x, expected %s", test.signature, data, test.expect)
+		}
+
+		//解码后重新编码应一致
+		_, types, _ := ParseMethodSignature(test.signature)
+		values, err := DecodeABI(types, data[4:], false)
+		if err != nil {
+			t.Errorf("DecodeABI %s failed: %v", test.signature, err)
+			continue
+		}
+		encoded, err := EncodeABI(types, values)
+		if err != nil || !bytes.Equal(encoded, data[4:]) {
+			t.Errorf("re-encode %s = %x, %v", test.signature, encoded, err)
+		}
+	}
+}
+
+func TestABIType_Parse(t *testing.T) {
+
+	tests := map[string]string{
+		"uint":                      "uint256",
+		"int":                       "int256",
+		"uint256[2][]":              "uint256[2][]",
+		"(address,(uint8,bytes)[])": "(address,(uint8,bytes)[])",
+		"trcToken":                  "trcToken",
+		"( uint256 , string )[3]":   "(uint256,string)[3]",
+	}
+	for typ, expect := range tests {
+		abiType, err := NewABIType(typ, nil)
+		if err != nil {
+			t.Errorf("NewABIType %s failed: %v", typ, err)
+			continue
+		}
+		if abiType.String() != expect {
+			t.Errorf("NewABIType %s = %s, expected %s", typ, abiType.String(), expect)
+		}
+	}
+
+	for _, typ := range []string{"uint7", "uint264", "bytes0", "bytes33", "uint[0]", "(uint256", "foo"} {
+		if _, err := NewABIType(typ, nil); err == nil {
+			t.Errorf("invalid type %s is accepted", typ)
+		}
+	}
+}
+
+func TestEncodeABI_Values(t *testing.T) {
+
+	types := func(s string) []*ABIType {
+		_, types, err := ParseMethodSignature("f(" + s + ")")
+		if err != nil {
+			t.Fatalf("ParseMethodSignature failed: %v", err)
+		}
+		return types
+	}
+
+	//base58、41开头的hex和0x开头的hex编码结果一致，高位不保留41
+	_, ownerBytes, _ := DecodeAddress(OWNERADDRESS, false)
+	expect := append(make([]byte, 12), ownerBytes[1:]...)
+	for _, addr := range []interface{}{OWNERADDRESS, hex.EncodeToString(ownerBytes), "0x" + hex.EncodeToString(ownerBytes[1:]), ownerBytes} {
+		encoded, err := EncodeABI(types("address"), []interface{}{addr})
+		if err != nil || !bytes.Equal(encoded, expect) {
+			t.Errorf("address %v = %x, %v", addr, encoded, err)
+		}
+	}
+
+	//负数为补码，解码还原
+	encoded, _ := EncodeABI(types("int16"), []interface{}{-2})
+	if !bytes.Equal(encoded, append(bytes.Repeat([]byte{0xff}, 31), 0xfe)) {
+		t.Errorf("int16(-2) = %x", encoded)
+	}
+	values, err := DecodeABI(types("int16"), encoded, false)
+	if err != nil || values[0].(*big.Int).Int64() != -2 {
+		t.Errorf("decode int16(-2) = %v, %v", values, err)
+	}
+
+	//元组可按成员名称传入
+	tuple, err := NewABIType("tuple", []ABIArgument{{Name: "to", Type: "address"}, {Name: "memo", Type: "string"}})
+	if err != nil {
+		t.Fatalf("NewABIType tuple failed: %v", err)
+	}
+	byName, err := EncodeABI([]*ABIType{tuple}, []interface{}{map[string]interface{}{"to": TOADDRESS, "memo": "hi"}})
+	if err != nil {
+		t.Fatalf("encode tuple by name failed: %v", err)
+	}
+	byOrder, _ := EncodeABI([]*ABIType{tuple}, []interface{}{[]interface{}{TOADDRESS, "hi"}})
+	if !bytes.Equal(byName, byOrder) {
+		t.Errorf("tuple by name = %x, by order = %x", byName, byOrder)
+	}
+	values, err = DecodeABI([]*ABIType{tuple}, byName, false)
+	if err != nil || !reflect.DeepEqual(values[0], []interface{}{TOADDRESS, "hi"}) {
+		t.Errorf("decode tuple = %v, %v", values, err)
+	}
+
+	bad := []struct {
+		types string
+		value interface{}
+	}{
+		{"uint8", 256},
+		{"uint256", -1},
+		{"int8", 128},
+		{"bytes2", []byte("abc")},
+		{"uint256[2]", []int{1}},
+		{"address", "not-an-address"},
+		{"bool", 1},
+	}
+	for _, b := range bad {
+		if _, err := EncodeABI(types(b.types), []interface{}{b.value}); err == nil {
+			t.Errorf("%s value %v is accepted", b.types, b.value)
+		}
+	}
+
+	//偏移量、长度越界
+	if _, err := DecodeABI(types("bytes"), abiWord(big.NewInt(64)), false); err == nil {
+		t.Errorf("out of range offset is accepted")
+	}
+	if _, err := DecodeABI(types("uint256[]"), append(abiWord(big.NewInt(32)), abiWord(big.NewInt(1000))...), false); err == nil {
+		t.Errorf("out of range length is accepted")
+	}
+	if _, err := DecodeABI(types("bool"), abiWord(big.NewInt(2)), false); err == nil {
+		t.Errorf("invalid bool is accepted")
+	}
+}
+
+const testTRC20ABI = `{"entrys": [
+	{"outputs": [{"type": "bool"}], "inputs": [{"name": "_to", "type": "address"}, {"name": "_value", "type": "uint256"}], "name": "transfer", "stateMutability": "Nonpayable", "type": "Function"},
+	{"outputs": [{"type": "uint256"}], "constant": true, "inputs": [{"name": "who", "type": "address"}], "name": "balanceOf", "stateMutability": "View", "type": "Function"},
+	{"outputs": [{"type": "string"}, {"type": "uint8"}], "constant": true, "name": "info", "stateMutability": "View", "type": "Function"},
+	{"inputs": [{"indexed": true, "name": "from", "type": "address"}, {"indexed": true, "name": "to", "type": "address"}, {"name": "value", "type": "uint256"}], "name": "Transfer", "type": "Event"},
+	{"inputs": [{"name": "available", "type": "uint256"}, {"name": "required", "type": "uint256"}], "name": "InsufficientBalance", "type": "Error"}
+]}`
+
+func TestABI_Contract(t *testing.T) {
+
+	abi, err := ParseABI(testTRC20ABI, false)
+	if err != nil {
+		t.Fatalf("ParseABI failed: %v", err)
+	}
+
+	transfer, err := abi.Method("transfer")
+	if err != nil || transfer.Signature() != "transfer(address,uint256)" || hex.EncodeToString(transfer.ID()) != TRC20_TRANSFER_METHOD_ID {
+		t.Fatalf("Method transfer = %+v, %v", transfer, err)
+	}
+	if _, err := abi.Method("approve"); err == nil {
+		t.Errorf("unknown method is found")
+	}
+
+	//与makeTransactionParameter编码一致
+	_, toBytes, _ := DecodeAddress(TOADDRESS, false)
+	data, err := abi.Pack("transfer", TOADDRESS, big.NewInt(1000000))
+	if err != nil {
+		t.Fatalf("Pack failed: %v", err)
+	}
+	legacy, _ := makeTransactionParameter(TRC20_TRANSFER_METHOD_ID, []SolidityParam{
+		{SOLIDITY_TYPE_ADDRESS, hex.EncodeToString(toBytes)},
+		{SOLIDITY_TYPE_UINT256, big.NewInt(1000000)},
+	})
+	if hex.EncodeToString(data) != legacy {
+		t.Errorf("Pack = %x, makeTransactionParameter = %s", data, legacy)
+	}
+
+	method, args, err := abi.DecodeCall(data)
+	if err != nil || method.Name != "transfer" || args[0] != TOADDRESS || args[1].(*big.Int).Int64() != 1000000 {
+		t.Errorf("DecodeCall = %v, %v, %v", method, args, err)
+	}
+
+	output, _ := EncodeABI(abi.Entries[2].outputs, []interface{}{"Tether USD", 6})
+	values, err := abi.Unpack("info", output)
+	if err != nil || values[0] != "Tether USD" || values[1].(*big.Int).Int64() != 6 {
+		t.Errorf("Unpack = %v, %v", values, err)
+	}
+
+	//Transfer事件
+	_, ownerBytes, _ := DecodeAddress(OWNERADDRESS, false)
+	topics := []string{
+		"ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+		hex.EncodeToString(append(make([]byte, 12), ownerBytes[1:]...)),
+		hex.EncodeToString(append(make([]byte, 12), toBytes[1:]...)),
+	}
+	event, fields, err := abi.DecodeEvent(topics, hex.EncodeToString(abiWord(big.NewInt(42))))
+	if err != nil {
+		t.Fatalf("DecodeEvent failed: %v", err)
+	}
+	if event.Name != "Transfer" || fields["from"] != OWNERADDRESS || fields["to"] != TOADDRESS || fields["value"].(*big.Int).Int64() != 42 {
+		t.Errorf("DecodeEvent = %s, %v", event.Name, fields)
+	}
+	if _, _, err := abi.DecodeEvent(topics[:2], ""); err == nil {
+		t.Errorf("event with missing topic is decoded")
+	}
+
+	//revert原因
+	errorString := "08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"0000000000000000000000000000000000000000000000000000000000000005" +
+		"6572726f72000000000000000000000000000000000000000000000000000000"
+	if reason, ok := abi.DecodeRevert(errorString); !ok || reason != "error" {
+		t.Errorf("DecodeRevert Error(string) = %s, %v", reason, ok)
+	}
+	custom, _ := EncodeCall("InsufficientBalance(uint256,uint256)", 1, 2)
+	if reason, ok := abi.DecodeRevert(hex.EncodeToString(custom)); !ok || reason != "InsufficientBalance(1, 2)" {
+		t.Errorf("DecodeRevert custom error = %s, %v", reason, ok)
+	}
+	if _, ok := abi.DecodeRevert(strings.Repeat("00", 36)); ok {
+		t.Errorf("unknown revert data is decoded")
+	}
+}
@@ -0,0 +1,310 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+	"github.com/golang/protobuf/proto"
+	"github.com/shopspring/decimal"
+)
+
+//发送地址选择策略
+const (
+	AddressSelectSmallest   = "smallest"   //余额足够的最小地址优先，默认
+	AddressSelectLargest    = "largest"    //余额最大的地址优先
+	AddressSelectResource   = "resource"   //可用带宽、能量最多的地址优先
+	AddressSelectRoundRobin = "roundRobin" //轮流使用账户下的地址
+)
+
+//AddressSelector 发送地址选择策略，返回按优先顺序排列的候选地址，调用方选择第一个余额足够的地址
+type AddressSelector interface {
+	Sort(accountID string, balances []*openwallet.Balance) []*openwallet.Balance
+}
+
+//NewAddressSelectors 内置的发送地址选择策略，可通过WalletManager.AddressSelectors添加自定义策略
+func NewAddressSelectors(wm *WalletManager) map[string]AddressSelector {
+	return map[string]AddressSelector{
+		AddressSelectSmallest:   &balanceSelector{},
+		AddressSelectLargest:    &balanceSelector{desc: true},
+		AddressSelectResource:   &resourceSelector{wm: wm},
+		AddressSelectRoundRobin: &roundRobinSelector{cursor: make(map[string]int)},
+	}
+}
+
+//balanceSelector 按余额排序
+type balanceSelector struct {
+	desc bool
+}
+
+func (s *balanceSelector) Sort(accountID string, balances []*openwallet.Balance) []*openwallet.Balance {
+	sort.SliceStable(balances, func(i, j int) bool {
+		a, _ := decimal.NewFromString(balances[i].Balance)
+		b, _ := decimal.NewFromString(balances[j].Balance)
+		if s.desc {
+			return a.GreaterThan(b)
+		}
+		return a.LessThan(b)
+	})
+	return balances
+}
+
+//resourceSelector 按可用带宽排序，带宽相同时按可用能量排序，查询失败的地址排在最后
+type resourceSelector struct {
+	wm *WalletManager
+}
+
+func (s *resourceSelector) Sort(accountID string, balances []*openwallet.Balance) []*openwallet.Balance {
+	type resource struct {
+		bandwidth int64
+		energy    int64
+	}
+	resources := make(map[string]resource)
+	for _, b := range balances {
+		res, err := s.wm.GetAccountResource(b.Address)
+		if err != nil {
+			resources[b.Address] = resource{bandwidth: -1, energy: -1}
+			continue
+		}
+		resources[b.Address] = resource{
+			bandwidth: res.FreeNetLimit - res.FreeNetUsed + res.NetLimit - res.NetUsed,
+			energy:    res.EnergyLimit - res.EnergyUsed,
+		}
+	}
+	sort.SliceStable(balances, func(i, j int) bool {
+		a, b := resources[balances[i].Address], resources[balances[j].Address]
+		if a.bandwidth != b.bandwidth {
+			return a.bandwidth > b.bandwidth
+		}
+		return a.energy > b.energy
+	})
+	return balances
+}
+
+//roundRobinSelector 每次从上一次的下一个地址开始
+type roundRobinSelector struct {
+	mu     sync.Mutex
+	cursor map[string]int
+}
+
+func (s *roundRobinSelector) Sort(accountID string, balances []*openwallet.Balance) []*openwallet.Balance {
+	if len(balances) == 0 {
+		return balances
+	}
+	sort.SliceStable(balances, func(i, j int) bool {
+		return balances[i].Address < balances[j].Address
+	})
+
+	s.mu.Lock()
+	start := s.cursor[accountID] % len(balances)
+	s.cursor[accountID] = start + 1
+	s.mu.Unlock()
+
+	return append(balances[start:], balances[:start]...)
+}
+
+//AddressLockFile 发送地址锁数据库文件
+const AddressLockFile = "addresslock.db"
+
+//addressLock 持久化的发送地址锁
+type addressLock struct {
+	TxID       string `storm:"id"`
+	Address    string
+	Expiration int64 //锁定到期时间，毫秒
+}
+
+//AddressLocker 发送地址锁，创建交易单时锁定选中的发送地址，未完成的交易使用期间不再被选择。
+//交易跟踪器确认交易上链或失败、广播失败、交易单被放弃或超过有效期后解锁。
+//调用Load后锁同步保存到数据库，重启后仍然有效
+type AddressLocker struct {
+	mu     sync.Mutex
+	locks  map[string]map[string]int64 //地址 -> 交易ID -> 锁定到期时间，毫秒
+	dbFile string
+}
+
+//NewAddressLocker 创建发送地址锁
+func NewAddressLocker() *AddressLocker {
+	return &AddressLocker{
+		locks: make(map[string]map[string]int64),
+	}
+}
+
+//Load 从数据库加载未过期的锁，之后的加锁和解锁都保存到该数据库
+func (l *AddressLocker) Load(dbFile string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	db, err := storm.Open(dbFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var saved []*addressLock
+	if err := db.All(&saved); err != nil {
+		return err
+	}
+	now := time.Now().UnixNano() / 1000000
+	for _, lock := range saved {
+		if lock.Expiration <= now {
+			db.DeleteStruct(lock)
+			continue
+		}
+		if l.locks[lock.Address] == nil {
+			l.locks[lock.Address] = make(map[string]int64)
+		}
+		l.locks[lock.Address][lock.TxID] = lock.Expiration
+	}
+	l.dbFile = dbFile
+	return nil
+}
+
+//save 保存锁的变更到数据库，未调用Load时只保存在内存
+func (l *AddressLocker) save(fn func(db *storm.DB) error) error {
+	if len(l.dbFile) == 0 {
+		return nil
+	}
+	db, err := storm.Open(l.dbFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return fn(db)
+}
+
+//Lock 锁定地址直到交易完成，expiration为交易有效期，毫秒时间戳
+func (l *AddressLocker) Lock(address, txID string, expiration int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.locks[address] == nil {
+		l.locks[address] = make(map[string]int64)
+	}
+	l.locks[address][txID] = expiration
+	return l.save(func(db *storm.DB) error {
+		return db.Save(&addressLock{TxID: txID, Address: address, Expiration: expiration})
+	})
+}
+
+//Unlock 交易完成，解锁交易使用的地址
+func (l *AddressLocker) Unlock(txID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	found := false
+	for address, txs := range l.locks {
+		if _, ok := txs[txID]; ok {
+			found = true
+		}
+		delete(txs, txID)
+		if len(txs) == 0 {
+			delete(l.locks, address)
+		}
+	}
+	if !found {
+		return nil
+	}
+	return l.save(func(db *storm.DB) error {
+		return db.DeleteStruct(&addressLock{TxID: txID})
+	})
+}
+
+//IsLocked 地址是否有未完成的交易，清除已过有效期的锁
+func (l *AddressLocker) IsLocked(address string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now().UnixNano() / 1000000
+	expired := make([]string, 0)
+	for txID, expiration := range l.locks[address] {
+		if expiration <= now {
+			delete(l.locks[address], txID)
+			expired = append(expired, txID)
+		}
+	}
+	if len(expired) > 0 {
+		l.save(func(db *storm.DB) error {
+			for _, txID := range expired {
+				db.DeleteStruct(&addressLock{TxID: txID})
+			}
+			return nil
+		})
+	}
+	if len(l.locks[address]) == 0 {
+		delete(l.locks, address)
+		return false
+	}
+	return true
+}
+
+//lockSender 锁定交易的发送地址，锁到交易有效期结束，返回交易ID。
+//创建交易单时已锁定的交易重复加锁不影响
+func (wm *WalletManager) lockSender(address, txHex string) (string, error) {
+	txBytes, err := hex.DecodeString(txHex)
+	if err != nil {
+		return "", err
+	}
+	tx := &core.Transaction{}
+	if err := proto.Unmarshal(txBytes, tx); err != nil {
+		return "", err
+	}
+	txHash, err := getTxHash(tx)
+	if err != nil {
+		return "", err
+	}
+	txID := hex.EncodeToString(txHash)
+	if err := wm.AddressLocker.Lock(address, txID, tx.GetRawData().GetExpiration()); err != nil {
+		return "", err
+	}
+	return txID, nil
+}
+
+//trackSubmitted 广播成功后，交易跟踪器运行时由跟踪器在交易完成后解锁发送地址，
+//否则无法确认交易是否上链，保持锁定直到交易有效期结束
+func (wm *WalletManager) trackSubmitted(txID, signedHex string) {
+	if tracker := wm.TxTracker; tracker != nil && tracker.IsRunning() {
+		if _, err := tracker.Track(signedHex); err != nil {
+			wm.Log.Std.Error("track transaction[%s] failed, err=%v", txID, err)
+		}
+	}
+}
+
+//ReleaseRawTransaction 放弃未广播的交易单，解锁创建时锁定的发送地址
+func (decoder *TransactionDecoder) ReleaseRawTransaction(rawTx *openwallet.RawTransaction) error {
+	return decoder.wm.releaseRawTransaction(rawTx)
+}
+
+func (wm *WalletManager) releaseRawTransaction(rawTx *openwallet.RawTransaction) error {
+	if len(rawTx.RawHex) == 0 {
+		return nil
+	}
+	for _, txHex := range splitRawHex(rawTx.RawHex) {
+		txHash, err := getTxHash1(txHex)
+		if err != nil {
+			return err
+		}
+		if err := wm.AddressLocker.Unlock(hex.EncodeToString(txHash)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
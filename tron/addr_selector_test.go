@@ -0,0 +1,193 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+)
+
+func testSenderBalances() []*openwallet.Balance {
+	return []*openwallet.Balance{
+		{Address: "C", Balance: "5"},
+		{Address: "A", Balance: "10"},
+		{Address: "B", Balance: "1"},
+	}
+}
+
+func sortedAddresses(balances []*openwallet.Balance) string {
+	s := ""
+	for _, b := range balances {
+		s += b.Address
+	}
+	return s
+}
+
+func TestAddressSelectors(t *testing.T) {
+
+	selectors := NewAddressSelectors(tw)
+
+	if s := sortedAddresses(selectors[AddressSelectSmallest].Sort("acc", testSenderBalances())); s != "BCA" {
+		t.Errorf("unexpected smallest order: %s", s)
+	}
+	if s := sortedAddresses(selectors[AddressSelectLargest].Sort("acc", testSenderBalances())); s != "ACB" {
+		t.Errorf("unexpected largest order: %s", s)
+	}
+
+	roundRobin := selectors[AddressSelectRoundRobin]
+	for _, expect := range []string{"ABC", "BCA", "CAB", "ABC"} {
+		if s := sortedAddresses(roundRobin.Sort("acc", testSenderBalances())); s != expect {
+			t.Errorf("unexpected round robin order: %s, expect: %s", s, expect)
+		}
+	}
+	//不同账户独立轮转
+	if s := sortedAddresses(roundRobin.Sort("other", testSenderBalances())); s != "ABC" {
+		t.Errorf("unexpected round robin order of other account: %s", s)
+	}
+}
+
+func TestAddressLocker(t *testing.T) {
+
+	locker := NewAddressLocker()
+	now := time.Now().UnixNano() / 1000000
+
+	locker.Lock("A", "tx1", now+60000)
+	locker.Lock("A", "tx2", now+60000)
+	locker.Lock("B", "tx3", now-1)
+
+	if !locker.IsLocked("A") {
+		t.Errorf("address A should be locked")
+	}
+	if locker.IsLocked("B") {
+		t.Errorf("expired lock should be released")
+	}
+
+	locker.Unlock("tx1")
+	if !locker.IsLocked("A") {
+		t.Errorf("address A should be locked by tx2")
+	}
+	locker.Unlock("tx2")
+	if locker.IsLocked("A") {
+		t.Errorf("address A should be unlocked")
+	}
+}
+
+func TestLockSender(t *testing.T) {
+
+	txRaw, err := tw.CreateFreezeBalanceV2Transaction(OWNERADDRESS, "1", core.ResourceCode_ENERGY, testStakeTxOptions())
+	if err != nil {
+		t.Fatalf("CreateFreezeBalanceV2Transaction failed: %v", err)
+	}
+	txID, err := tw.lockSender(OWNERADDRESS, txRaw)
+	if err != nil || !tw.AddressLocker.IsLocked(OWNERADDRESS) {
+		t.Fatalf("sender should be locked before broadcast, err: %v", err)
+	}
+
+	//交易跟踪器未运行，无法确认交易结果，保持锁定到交易有效期结束
+	tw.trackSubmitted(txID, txRaw)
+	if !tw.AddressLocker.IsLocked(OWNERADDRESS) {
+		t.Errorf("sender should stay locked when tracker is not running")
+	}
+
+	decoder := NewTransactionDecoder(tw)
+	if err := decoder.ReleaseRawTransaction(&openwallet.RawTransaction{RawHex: txRaw}); err != nil {
+		t.Fatalf("ReleaseRawTransaction failed: %v", err)
+	}
+	if tw.AddressLocker.IsLocked(OWNERADDRESS) {
+		t.Errorf("sender should be unlocked after the raw transaction is released")
+	}
+}
+
+func TestAddressLockerLoad(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "addresslock")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	dbFile := filepath.Join(dir, AddressLockFile)
+
+	now := time.Now().UnixNano() / 1000000
+	locker := NewAddressLocker()
+	if err := locker.Load(dbFile); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	locker.Lock("A", "tx1", now+60000)
+	locker.Lock("B", "tx2", now+60000)
+	locker.Lock("C", "tx3", now+60000)
+	locker.Unlock("tx2")
+
+	//重启后未完成交易的发送地址仍然锁定
+	reloaded := NewAddressLocker()
+	if err := reloaded.Load(dbFile); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reloaded.IsLocked("A") || !reloaded.IsLocked("C") {
+		t.Errorf("locks should be restored from database")
+	}
+	if reloaded.IsLocked("B") {
+		t.Errorf("released lock should not be restored")
+	}
+}
+
+func TestSelectSenderBalances(t *testing.T) {
+
+	decoder := NewTransactionDecoder(tw)
+	rawTx := &openwallet.RawTransaction{
+		Account: &openwallet.AssetsAccount{AccountID: "acc"},
+	}
+
+	tw.AddressLocker.Lock("C", "testSelectSenderBalances", time.Now().Add(time.Minute).UnixNano()/1000000)
+	defer tw.AddressLocker.Unlock("testSelectSenderBalances")
+
+	balances, err := decoder.selectSenderBalances(rawTx, testSenderBalances())
+	if err != nil || sortedAddresses(balances) != "BA" {
+		t.Errorf("unexpected balances: %s, err: %v", sortedAddresses(balances), err)
+	}
+
+	rawTx.SetExtParam(addressStrategyExtKey, AddressSelectLargest)
+	balances, err = decoder.selectSenderBalances(rawTx, testSenderBalances())
+	if err != nil || sortedAddresses(balances) != "AB" {
+		t.Errorf("unexpected balances: %s, err: %v", sortedAddresses(balances), err)
+	}
+
+	rawTx.SetExtParam(addressStrategyExtKey, "unknown")
+	if _, err := decoder.selectSenderBalances(rawTx, testSenderBalances()); err == nil {
+		t.Errorf("unknown strategy should be rejected")
+	}
+
+	rawTx.SetExtParam(fromExtKey, "B")
+	balances, err = decoder.selectSenderBalances(rawTx, testSenderBalances())
+	if err != nil || sortedAddresses(balances) != "B" {
+		t.Errorf("unexpected balances: %s, err: %v", sortedAddresses(balances), err)
+	}
+
+	rawTx.SetExtParam(fromExtKey, "C")
+	if _, err := decoder.selectSenderBalances(rawTx, testSenderBalances()); err == nil {
+		t.Errorf("locked from address should be rejected")
+	}
+
+	rawTx.SetExtParam(fromExtKey, "D")
+	if _, err := decoder.selectSenderBalances(rawTx, testSenderBalances()); err == nil {
+		t.Errorf("unknown from address should be rejected")
+	}
+}
@@ -478,6 +478,12 @@ func (bs *TronBlockScanner) InitTronExtractResult(tx *Contract, result *ExtractR
 		TxType:      0,
 	}
 
+	if len(tx.Memo) > 0 {
+		transx.IsMemo = true
+		transx.Memo = tx.Memo
+		transx.SetExtParam("memo", tx.Memo)
+	}
+
 	wxID := openwallet.GenTransactionWxID(transx)
 	transx.WxID = wxID
 
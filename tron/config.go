@@ -49,6 +49,25 @@ const (
 	TRX               int64 = SUN * 1000000 //1 TRX = 1000000 * sun
 	GasPrice                = SUN * 140
 	CreateAccountCost       = SUN * 1100000 //1.1 TRX = 1100000 * sun
+	//MaxTxExpiration 协议允许的最大交易有效期
+	MaxTxExpiration = 24 * time.Hour
+	//DefaultTxExpiration 默认交易有效期
+	DefaultTxExpiration = 10 * time.Minute
+	//DefaultRefBlockCacheTime 默认参考区块缓存刷新间隔
+	DefaultRefBlockCacheTime = 3 * time.Second
+)
+
+//带宽消耗
+const (
+	BandwidthPrice     = SUN * 1000 //1 Bandwidth = 1000 sun
+	SignatureBandwidth = 67         //签名占用的带宽字节数
+	ResultBandwidth    = 64         //交易结果占用的带宽字节数
+)
+
+//参考区块类型
+const (
+	RefBlockHead       = "head"       //最新区块
+	RefBlockSolidified = "solidified" //最新固化区块
 )
 
 //WalletConfig configs for Wallet
@@ -114,6 +133,22 @@ type WalletConfig struct {
 	DataDir string
 	//Ignore the dust trade
 	IgnoreDustTRX decimal.Decimal
+	//交易有效期，不能超过MaxTxExpiration
+	TxExpiration time.Duration
+	//交易参考区块类型：head，solidified
+	RefBlock string
+	//参考区块缓存刷新间隔，0不缓存
+	RefBlockCacheTime time.Duration
+	//合约调用交易广播前先通过triggerconstantcontract模拟执行
+	DryRun bool
+	//发送地址选择策略：smallest，largest，resource，roundRobin
+	AddressSelectStrategy string
+	//远程签名服务地址，非空时交易单由远程签名服务签名
+	SignerURL string
+	//远程签名服务访问令牌
+	SignerToken string
+	//加载配置后启动交易跟踪器，跟踪广播后的交易结果并释放发送地址锁
+	TxTracker bool
 }
 
 //NewConfig Create config instance
@@ -170,6 +205,12 @@ func NewConfig() *WalletConfig {
 	c.CycleSeconds = time.Second * 10
 	//小数位长度
 	c.CoinDecimal = decimal.NewFromFloat(100000000)
+	//交易有效期
+	c.TxExpiration = DefaultTxExpiration
+	//交易参考区块类型
+	c.RefBlock = RefBlockHead
+	//参考区块缓存刷新间隔
+	c.RefBlockCacheTime = DefaultRefBlockCacheTime
 
 	//默认配置内容
 	c.DefaultConfig = `
@@ -199,6 +240,20 @@ threshold = ""
 cycleSeconds = ""
 # feeLimit, the maximum energy is 1000000000
 feeLimit = 10000000
+# transaction expiration window, sample: 60s, 10m, the maximum is 24h
+txExpiration = 10m
+# reference block of transaction, head or solidified
+refBlock = head
+# reference block cache refresh interval, 0s disables the cache
+refBlockCacheTime = 3s
+# simulate smart contract calls by triggerconstantcontract before creating and submitting
+dryRun = false
+# sender address selection strategy: smallest, largest, resource, roundRobin
+addressSelectStrategy = smallest
+# remote signing service url, transactions are signed by it when set
+signerURL = ""
+# remote signing service bearer token
+signerToken = ""
 `
 
 	//创建目录
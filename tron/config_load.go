@@ -17,7 +17,9 @@ package tron
 
 import (
 	"errors"
+	"fmt"
 	"path/filepath"
+	"time"
 
 	"github.com/astaxie/beego/config"
 	"github.com/shopspring/decimal"
@@ -57,11 +59,71 @@ func (wm *WalletManager) LoadConfig() error {
 		wm.Config.WalletDataPath = c.String("mainNetDataPath")
 	}
 	wm.Config.IgnoreDustTRX, _ = decimal.NewFromString(c.String("ignoreDustTRX"))
+	if err := wm.Config.loadTxConfig(c); err != nil {
+		return err
+	}
+	wm.RefBlockCache = NewRefBlockCache(wm.Config.RefBlockCacheTime)
+	if len(wm.Config.SignerURL) > 0 {
+		wm.Signer = NewHTTPSigner(wm.Config.SignerURL, wm.Config.SignerToken)
+	}
 	wm.WalletClient = NewClient(wm.Config.ServerAPI, "", false)
+	wm.startTxTracker()
+
+	return nil
+}
+
+//loadTxConfig 读取构建交易单的配置，txExpiration、refBlock和refBlockCacheTime无效时返回错误，不静默使用默认值
+func (wc *WalletConfig) loadTxConfig(c config.Configer) error {
+
+	wc.TxExpiration = DefaultTxExpiration
+	if txExpiration := c.String("txExpiration"); len(txExpiration) > 0 {
+		d, err := time.ParseDuration(txExpiration)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid txExpiration: %s, should be a positive duration such as 10m", txExpiration)
+		}
+		wc.TxExpiration = d
+	}
+	if wc.TxExpiration > MaxTxExpiration {
+		wc.TxExpiration = MaxTxExpiration
+	}
+
+	wc.RefBlock = RefBlockHead
+	if refBlock := c.String("refBlock"); len(refBlock) > 0 {
+		if refBlock != RefBlockHead && refBlock != RefBlockSolidified {
+			return fmt.Errorf("invalid refBlock: %s, should be %s or %s", refBlock, RefBlockHead, RefBlockSolidified)
+		}
+		wc.RefBlock = refBlock
+	}
 
+	wc.RefBlockCacheTime = DefaultRefBlockCacheTime
+	if refBlockCacheTime := c.String("refBlockCacheTime"); len(refBlockCacheTime) > 0 {
+		d, err := time.ParseDuration(refBlockCacheTime)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid refBlockCacheTime: %s, should be a duration such as 3s", refBlockCacheTime)
+		}
+		wc.RefBlockCacheTime = d
+	}
+
+	wc.DryRun, _ = c.Bool("dryRun")
+
+	wc.AddressSelectStrategy = AddressSelectSmallest
+	if strategy := c.String("addressSelectStrategy"); len(strategy) > 0 {
+		wc.AddressSelectStrategy = strategy
+	}
+
+	wc.SignerURL = c.String("signerURL")
+	wc.SignerToken = c.String("signerToken")
+	wc.TxTracker, _ = c.Bool("txTracker")
 	return nil
 }
 
+//startTxTracker 配置txTracker = true时启动交易跟踪器，未启动时调用方可自行调用TxTracker.Start
+func (wm *WalletManager) startTxTracker() {
+	if wm.Config.TxTracker && wm.TxTracker != nil {
+		wm.TxTracker.Start()
+	}
+}
+
 //LoadAssetsConfig 加载外部配置
 func (wm *WalletManager) LoadAssetsConfig(c config.Configer) error {
 
@@ -89,8 +151,19 @@ func (wm *WalletManager) LoadAssetsConfig(c config.Configer) error {
 	wm.WalletClient = NewClient(wm.Config.ServerAPI, "", false)
 	wm.Config.DataDir = c.String("dataDir")
 	wm.Config.IgnoreDustTRX, _ = decimal.NewFromString(c.String("ignoreDustTRX"))
+	if err := wm.Config.loadTxConfig(c); err != nil {
+		return err
+	}
+	wm.RefBlockCache = NewRefBlockCache(wm.Config.RefBlockCacheTime)
+	if len(wm.Config.SignerURL) > 0 {
+		wm.Signer = NewHTTPSigner(wm.Config.SignerURL, wm.Config.SignerToken)
+	}
 
 	//数据文件夹
 	wm.Config.makeDataDir()
+	if err := wm.AddressLocker.Load(filepath.Join(wm.Config.dbPath, AddressLockFile)); err != nil {
+		return err
+	}
+	wm.startTxTracker()
 	return nil
 }
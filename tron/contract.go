@@ -20,9 +20,12 @@ import (
 	"fmt"
 	"github.com/blocktree/openwallet/v2/common"
 	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+	"github.com/golang/protobuf/proto"
 	"github.com/imroc/req"
 	"math/big"
 	"strings"
+	"sync"
 )
 
 /*
@@ -56,12 +59,29 @@ const (
 	FeeLimit = 10000000
 )
 
+//TRC20UnlimitedAllowance 授权数量为uint256最大值
+const TRC20UnlimitedAllowance = "unlimited"
+
 const (
 	TRC20_BALANCE_OF_METHOD  = "balanceOf(address)"
+	TRC20_ALLOWANCE_METHOD   = "allowance(address,address)"
 	TRC20_TRANSFER_METHOD_ID = "a9059cbb"
 	TRX_TRANSFER_EVENT_ID    = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
 )
 
+const (
+	TRC20_TRANSFER_FROM_METHOD_ID      = "23b872dd"
+	TRC20_APPROVE_METHOD_ID            = "095ea7b3"
+	TRC20_INCREASE_ALLOWANCE_METHOD_ID = "39509351"
+	TRC20_DECREASE_ALLOWANCE_METHOD_ID = "a457c2d7"
+)
+
+//合约revert返回数据的方法ID
+const (
+	SOLIDITY_ERROR_METHOD_ID = "08c379a0" //Error(string)
+	SOLIDITY_PANIC_METHOD_ID = "4e487b71" //Panic(uint256)
+)
+
 const (
 	SOLIDITY_TYPE_ADDRESS = "address"
 	SOLIDITY_TYPE_UINT256 = "uint256"
@@ -73,42 +93,24 @@ type SolidityParam struct {
 	ParamValue interface{}
 }
 
-func makeRepeatString(c string, count uint) string {
-	cs := make([]string, 0)
-	for i := 0; i < int(count); i++ {
-		cs = append(cs, c)
-	}
-	return strings.Join(cs, "")
-}
-
+//makeTransactionParameter 按参数类型ABI编码，拼接在methodId之后
 func makeTransactionParameter(methodId string, params []SolidityParam) (string, error) {
 
-	data := methodId
-	for i, _ := range params {
-		var param string
-		if params[i].ParamType == SOLIDITY_TYPE_ADDRESS {
-			param = strings.ToLower(params[i].ParamValue.(string))
-			param = strings.TrimPrefix(param, "0x")
-			if len(param) != 42 {
-				return "", fmt.Errorf("length of address error.")
-			}
-			param = makeRepeatString("0", 22) + param
-		} else if params[i].ParamType == SOLIDITY_TYPE_UINT256 {
-			intParam := params[i].ParamValue.(*big.Int)
-			param = intParam.Text(16)
-			l := len(param)
-			if l > 64 {
-				return "", fmt.Errorf("integer overflow.")
-			}
-			param = makeRepeatString("0", uint(64-l)) + param
-			//fmt.Println("makeTransactionData intParam:", intParam.String(), " param:", param)
-		} else {
-			return "", fmt.Errorf("not support solidity type")
+	types := make([]*ABIType, 0, len(params))
+	values := make([]interface{}, 0, len(params))
+	for _, p := range params {
+		t, err := NewABIType(p.ParamType, nil)
+		if err != nil {
+			return "", err
 		}
-
-		data += param
+		types = append(types, t)
+		values = append(values, p.ParamValue)
 	}
-	return data, nil
+	data, err := EncodeABI(types, values)
+	if err != nil {
+		return "", err
+	}
+	return methodId + hex.EncodeToString(data), nil
 }
 
 //TriggerSmartContract 初始智能合约方法
@@ -134,6 +136,85 @@ func (wm *WalletManager) TriggerSmartContract(
 	return NewTransactionExtention(r), nil
 }
 
+//TriggerConstantContract 模拟执行合约调用，不上链，data为完整的调用数据(方法ID+参数)，地址为hex格式
+func (wm *WalletManager) TriggerConstantContract(
+	contractAddress string,
+	data string,
+	callValue int64,
+	ownerAddress string) (*TransactionExtention, error) {
+	params := req.Param{
+		"contract_address": contractAddress,
+		"data":             data,
+		"call_value":       callValue,
+		"owner_address":    ownerAddress,
+	}
+	r, err := wm.WalletClient.Call("/wallet/triggerconstantcontract", params)
+	if err != nil {
+		return nil, err
+	}
+	return NewTransactionExtention(r), nil
+}
+
+//DryRunTransaction 模拟执行交易单中的合约调用，预计执行失败时返回revert原因
+func (wm *WalletManager) DryRunTransaction(txHex string) error {
+
+	txBytes, err := hex.DecodeString(txHex)
+	if err != nil {
+		return err
+	}
+	tx := &core.Transaction{}
+	if err := proto.Unmarshal(txBytes, tx); err != nil {
+		return err
+	}
+
+	for _, c := range tx.GetRawData().GetContract() {
+		if c.GetType() != core.Transaction_Contract_TriggerSmartContract {
+			continue
+		}
+		tc := &core.TriggerSmartContract{}
+		if err := proto.Unmarshal(c.GetParameter().GetValue(), tc); err != nil {
+			return err
+		}
+		result, err := wm.SimulateContractCall(tc)
+		if err != nil {
+			return err
+		}
+		if reason, reverted := result.RevertReason(); reverted {
+			return fmt.Errorf("contract call will revert: %s", reason)
+		}
+	}
+	return nil
+}
+
+//DecodeRevertReason 解析合约revert返回的数据，支持Error(string)和Panic(uint256)
+func DecodeRevertReason(result string) (string, bool) {
+	data, err := hex.DecodeString(strings.TrimPrefix(result, "0x"))
+	if err != nil || len(data) < 4+32 {
+		return "", false
+	}
+	methodID := hex.EncodeToString(data[:4])
+	data = data[4:]
+	switch methodID {
+	case SOLIDITY_ERROR_METHOD_ID:
+		if len(data) < 64 {
+			return "", false
+		}
+		offset := new(big.Int).SetBytes(data[:32])
+		if !offset.IsUint64() || offset.Uint64()+32 > uint64(len(data)) {
+			return "", false
+		}
+		start := offset.Uint64()
+		size := new(big.Int).SetBytes(data[start : start+32])
+		if !size.IsUint64() || start+32+size.Uint64() > uint64(len(data)) {
+			return "", false
+		}
+		return string(data[start+32 : start+32+size.Uint64()]), true
+	case SOLIDITY_PANIC_METHOD_ID:
+		return fmt.Sprintf("panic code 0x%x", new(big.Int).SetBytes(data[:32])), true
+	}
+	return "", false
+}
+
 //GetContractInfo 获取智能合约信息
 func (wm *WalletManager) GetContractInfo(contractAddress string) (*ContractInfo, error) {
 	value, _, err := DecodeAddress(contractAddress, wm.Config.IsTestNet)
@@ -197,6 +278,48 @@ func (wm *WalletManager) GetTRC20Balance(address string, contractAddress string)
 	return big.NewInt(0), nil
 }
 
+//GetTRC20Allowance 查询spender可从owner转出的代币授权数量
+func (wm *WalletManager) GetTRC20Allowance(ownerAddress, spenderAddress string, contractAddress string) (*big.Int, error) {
+
+	owner, _, err := DecodeAddress(ownerAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return big.NewInt(0), err
+	}
+
+	spender, _, err := DecodeAddress(spenderAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return big.NewInt(0), err
+	}
+
+	caddr, _, err := DecodeAddress(contractAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return big.NewInt(0), err
+	}
+	param, err := makeTransactionParameter("", []SolidityParam{
+		{SOLIDITY_TYPE_ADDRESS, owner},
+		{SOLIDITY_TYPE_ADDRESS, spender},
+	})
+	if err != nil {
+		return big.NewInt(0), err
+	}
+
+	tx, err := wm.TriggerSmartContract(
+		caddr,
+		TRC20_ALLOWANCE_METHOD,
+		param,
+		0,
+		0,
+		owner)
+	if err != nil {
+		return big.NewInt(0), err
+	}
+
+	if len(tx.ConstantResult) == 0 {
+		return big.NewInt(0), fmt.Errorf("get allowance failed: %s", tx.Result.Message)
+	}
+	return common.StringValueToBigInt(tx.ConstantResult[0], 16)
+}
+
 //GetTokenBalance 获取代币余额
 func (wm *WalletManager) GetTRC10Balance(address string, tokenID string) (*big.Int, error) {
 
@@ -208,15 +331,32 @@ func (wm *WalletManager) GetTRC10Balance(address string, tokenID string) (*big.I
 	return a.AssetV2[tokenID], nil
 }
 
+//GetAssetIssueByID 查询TRC10资产发行信息
+func (wm *WalletManager) GetAssetIssueByID(tokenID string) (*AssetIssue, error) {
+
+	r, err := wm.WalletClient.Call("/wallet/getassetissuebyid", req.Param{"value": tokenID})
+	if err != nil {
+		return nil, err
+	}
+	if !r.Get("owner_address").Exists() {
+		return nil, fmt.Errorf("asset: %s is not found", tokenID)
+	}
+	return NewAssetIssue(r, wm.Config.IsTestNet)
+}
+
 type ContractDecoder struct {
 	*openwallet.SmartContractDecoderBase
 	wm *WalletManager
+
+	abiMu    sync.RWMutex
+	abiInfos map[string]*openwallet.ABIInfo //合约地址的ABI缓存
 }
 
 //NewContractDecoder 智能合约解析器
 func NewContractDecoder(wm *WalletManager) *ContractDecoder {
 	decoder := ContractDecoder{}
 	decoder.wm = wm
+	decoder.abiInfos = make(map[string]*openwallet.ABIInfo)
 	return &decoder
 }
 
@@ -0,0 +1,788 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/common"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+	"github.com/golang/protobuf/proto"
+	"github.com/imroc/req"
+	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//DefaultContractAwaitTimeout 广播合约交易后等待执行结果的默认超时
+	DefaultContractAwaitTimeout = 90 * time.Second
+	//contractAwaitInterval 等待执行结果的查询间隔，约一个出块时间
+	contractAwaitInterval = 3 * time.Second
+	//EnergyPrice 未获取到链参数getEnergyFee时使用的能量单价
+	EnergyPrice = SUN * 420
+)
+
+//ContractCallParam 合约交易单Raw为JSON(RawType = TxRawTypeJSON)时的调用参数。
+//创建交易单后Raw仍为JSON，保留请求的调用参数，feeLimit为实际使用的值，transaction为待签名的交易hex，签名和广播前按调用参数检查交易
+//	{
+//		"data": "a9059cbb...",     //调用数据hex，为空时按ABIParam编码
+//		"tokenId": 1002000,        //转入合约的TRC10代币ID
+//		"callTokenValue": 1000000, //转入合约的TRC10数量，最小单位
+//		"feeLimit": 100000000,     //最多燃烧的sun，0使用配置
+//		"transaction": "0a83..."   //创建后的待签名交易hex
+//	}
+type ContractCallParam struct {
+	Data           string `json:"data"`
+	TokenID        int64  `json:"tokenId"`
+	CallTokenValue int64  `json:"callTokenValue"`
+	FeeLimit       int64  `json:"feeLimit"`
+	Transaction    string `json:"transaction,omitempty"`
+}
+
+//makeTriggerSmartContract 创建合约调用，callValue为转入的sun，tokenID和callTokenValue为转入的TRC10
+func (wm *WalletManager) makeTriggerSmartContract(ownerAddress, contractAddress string, data []byte, callValue, tokenID, callTokenValue int64) (*core.TriggerSmartContract, error) {
+
+	if callValue < 0 || callTokenValue < 0 {
+		return nil, fmt.Errorf("call value should not be negative")
+	}
+	if callTokenValue > 0 && tokenID <= 0 {
+		return nil, fmt.Errorf("token id is required for call token value")
+	}
+	_, ownerAddressBytes, err := DecodeAddress(ownerAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return nil, err
+	}
+	_, contractAddressBytes, err := DecodeAddress(contractAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return nil, err
+	}
+	return &core.TriggerSmartContract{
+		OwnerAddress:    ownerAddressBytes,
+		ContractAddress: contractAddressBytes,
+		CallValue:       callValue,
+		Data:            data,
+		TokenId:         tokenID,
+		CallTokenValue:  callTokenValue,
+	}, nil
+}
+
+//CreateContractCallTransaction 创建合约调用交易单，data为方法ID+参数，数量都是最小单位
+func (wm *WalletManager) CreateContractCallTransaction(ownerAddress, contractAddress string, data []byte, callValue, tokenID, callTokenValue int64, opts *TxOptions) (txRawHex string, err error) {
+	tc, err := wm.makeTriggerSmartContract(ownerAddress, contractAddress, data, callValue, tokenID, callTokenValue)
+	if err != nil {
+		return "", err
+	}
+	return wm.createAssetsTransaction(tc, core.Transaction_Contract_TriggerSmartContract, opts)
+}
+
+//SimulateContractCall 通过triggerconstantcontract模拟执行合约调用，包括转入的TRX和TRC10
+func (wm *WalletManager) SimulateContractCall(tc *core.TriggerSmartContract) (*TransactionExtention, error) {
+	params := req.Param{
+		"owner_address":    hex.EncodeToString(tc.GetOwnerAddress()),
+		"contract_address": hex.EncodeToString(tc.GetContractAddress()),
+		"data":             hex.EncodeToString(tc.GetData()),
+		"call_value":       tc.GetCallValue(),
+	}
+	if tc.GetTokenId() > 0 {
+		params["token_id"] = tc.GetTokenId()
+		params["call_token_value"] = tc.GetCallTokenValue()
+	}
+	r, err := wm.WalletClient.Call("/wallet/triggerconstantcontract", params)
+	if err != nil {
+		return nil, err
+	}
+	return NewTransactionExtention(r), nil
+}
+
+//estimateContractCallFee 预估合约调用燃烧的TRX：可用能量不足的部分按getEnergyFee燃烧，加上带宽费用
+func (wm *WalletManager) estimateContractCallFee(ownerAddress string, txHex string, energyUsed int64) (*txFeeInfo, error) {
+
+	feeInfo, err := wm.GetTransactionFeeEstimated(ownerAddress, txHex)
+	if err != nil {
+		return nil, err
+	}
+	res, err := wm.GetAccountResource(ownerAddress)
+	if err != nil {
+		return nil, err
+	}
+	params, err := wm.GetChainParameters()
+	if err != nil {
+		return nil, err
+	}
+	return contractCallFee(feeInfo, energyUsed, res.EnergyLimit-res.EnergyUsed, chainParameter(params, "getEnergyFee", EnergyPrice)), nil
+}
+
+//contractCallFee 合并带宽费用和能量燃烧费用，GasUsed和GasPrice为燃烧的能量和单价
+func contractCallFee(bandwidthFee *txFeeInfo, energyUsed, energyAvailable, energyFee int64) *txFeeInfo {
+	burn := energyUsed
+	if energyAvailable > 0 {
+		burn -= energyAvailable
+	}
+	if burn < 0 {
+		burn = 0
+	}
+	feeInfo := &txFeeInfo{
+		GasUsed:  burn,
+		GasPrice: decimal.New(energyFee, -Decimals),
+	}
+	feeInfo.CalcFee()
+	feeInfo.Fee = feeInfo.Fee.Add(bandwidthFee.Fee)
+	return feeInfo
+}
+
+//GetABIInfo 获取合约ABI，优先使用SetABIInfo设置的ABI，否则从链上查询并缓存
+func (decoder *ContractDecoder) GetABIInfo(address string) (*openwallet.ABIInfo, error) {
+
+	decoder.abiMu.RLock()
+	info, ok := decoder.abiInfos[address]
+	decoder.abiMu.RUnlock()
+	if ok {
+		return info, nil
+	}
+
+	contractInfo, err := decoder.wm.GetContractInfo(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(contractInfo.ABI) == 0 {
+		return nil, openwallet.Errorf(openwallet.ErrContractNotFound, "contract: %s has no ABI", address)
+	}
+	info = &openwallet.ABIInfo{Address: address, ABI: contractInfo.ABI}
+	decoder.abiMu.Lock()
+	decoder.abiInfos[address] = info
+	decoder.abiMu.Unlock()
+	return info, nil
+}
+
+//SetABIInfo 设置合约ABI，ABI为JSON字符串或可序列化为JSON的对象
+func (decoder *ContractDecoder) SetABIInfo(address string, abi openwallet.ABIInfo) error {
+	if _, err := parseABIInfo(&abi, decoder.wm.Config.IsTestNet); err != nil {
+		return err
+	}
+	abi.Address = address
+	decoder.abiMu.Lock()
+	decoder.abiInfos[address] = &abi
+	decoder.abiMu.Unlock()
+	return nil
+}
+
+func parseABIInfo(info *openwallet.ABIInfo, isTestnet bool) (*ABI, error) {
+	if s, ok := info.ABI.(string); ok {
+		return ParseABI(s, isTestnet)
+	}
+	raw, err := json.Marshal(info.ABI)
+	if err != nil {
+		return nil, err
+	}
+	return ParseABI(string(raw), isTestnet)
+}
+
+//contractABI 合约ABI，优先使用SmartContract中设置的ABI
+func (decoder *ContractDecoder) contractABI(contract openwallet.SmartContract) (*ABI, error) {
+	if abiJSON := contract.GetABI(); len(abiJSON) > 0 {
+		return ParseABI(abiJSON, decoder.wm.Config.IsTestNet)
+	}
+	info, err := decoder.GetABIInfo(contract.Address)
+	if err != nil {
+		return nil, err
+	}
+	return parseABIInfo(info, decoder.wm.Config.IsTestNet)
+}
+
+//encodeABIParam 按ABIParam编码调用数据，ABIParam为[method, arg1, arg2, ...]
+func (decoder *ContractDecoder) encodeABIParam(contract openwallet.SmartContract, abiParam []string) (*ABI, *ABIEntry, []byte, error) {
+	if len(abiParam) == 0 {
+		return nil, nil, nil, fmt.Errorf("ABI method is empty")
+	}
+	abi, err := decoder.contractABI(contract)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	method, err := abi.Method(abiParam[0])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	args, err := method.ParseArguments(abiParam[1:])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	data, err := abi.Pack(method.Signature(), args...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return abi, method, data, nil
+}
+
+//contractCallParam 解析交易单Raw中的调用参数，Raw为空时按ABIParam编码调用数据
+func (decoder *ContractDecoder) contractCallParam(rawTx *openwallet.SmartContractRawTransaction) (*ContractCallParam, *ABI, []byte, error) {
+
+	param := &ContractCallParam{}
+	if len(rawTx.Raw) > 0 {
+		switch rawTx.RawType {
+		case openwallet.TxRawTypeHex:
+			param.Data = rawTx.Raw
+		case openwallet.TxRawTypeJSON:
+			if err := json.Unmarshal([]byte(rawTx.Raw), param); err != nil {
+				return nil, nil, nil, err
+			}
+		case openwallet.TxRawTypeBase64:
+			data, err := base64.StdEncoding.DecodeString(rawTx.Raw)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			param.Data = hex.EncodeToString(data)
+		default:
+			return nil, nil, nil, fmt.Errorf("raw type: %d is not supported", rawTx.RawType)
+		}
+	}
+
+	if len(param.Data) > 0 {
+		data, err := hex.DecodeString(param.Data)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		//调用数据已编码时ABI只用于解析revert原因，可以没有
+		abi, _ := decoder.contractABI(rawTx.Coin.Contract)
+		return param, abi, data, nil
+	}
+
+	abi, _, data, err := decoder.encodeABIParam(rawTx.Coin.Contract, rawTx.ABIParam)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return param, abi, data, nil
+}
+
+//callerAddress 只读调用的调用地址：TxFrom，账户的第一个地址，都没有时使用合约地址
+func (decoder *ContractDecoder) callerAddress(wrapper openwallet.WalletDAI, rawTx *openwallet.SmartContractRawTransaction) string {
+	if len(rawTx.TxFrom) > 0 {
+		return rawTx.TxFrom
+	}
+	if wrapper != nil && rawTx.Account != nil {
+		addresses, err := wrapper.GetAddressList(0, 1, "AccountID", rawTx.Account.AccountID)
+		if err == nil && len(addresses) > 0 {
+			return addresses[0].Address
+		}
+	}
+	return rawTx.Coin.Contract.Address
+}
+
+//contractRevertReason 模拟执行的revert原因，优先按ABI解析自定义错误
+func contractRevertReason(abi *ABI, result *TransactionExtention) (string, bool) {
+	reason, reverted := result.RevertReason()
+	if !reverted {
+		return "", false
+	}
+	if abi != nil {
+		for _, c := range result.ConstantResult {
+			if r, ok := abi.DecodeRevert(c); ok {
+				return r, true
+			}
+		}
+	}
+	return reason, true
+}
+
+//CallSmartContractABI 只读调用合约方法，Value为调用结果的JSON数组
+func (decoder *ContractDecoder) CallSmartContractABI(wrapper openwallet.WalletDAI, rawTx *openwallet.SmartContractRawTransaction) (*openwallet.SmartContractCallResult, *openwallet.Error) {
+
+	wm := decoder.wm
+
+	abi, method, data, err := decoder.encodeABIParam(rawTx.Coin.Contract, rawTx.ABIParam)
+	if err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrContractCallMsgInvalid, "%v", err)
+	}
+	callValue := int64(0)
+	if len(rawTx.Value) > 0 {
+		callValue = common.StringNumToBigIntWithExp(rawTx.Value, wm.Decimal()).Int64()
+	}
+	tc, err := wm.makeTriggerSmartContract(decoder.callerAddress(wrapper, rawTx), rawTx.Coin.Contract.Address, data, callValue, 0, 0)
+	if err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrContractCallMsgInvalid, "%v", err)
+	}
+	result, err := wm.SimulateContractCall(tc)
+	if err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrCallFullNodeAPIFailed, "%v", err)
+	}
+	return contractCallResult(abi, method, result), nil
+}
+
+//contractCallResult 解析只读调用结果
+func contractCallResult(abi *ABI, method *ABIEntry, result *TransactionExtention) *openwallet.SmartContractCallResult {
+
+	callResult := &openwallet.SmartContractCallResult{
+		Method: method.Name,
+		Status: openwallet.SmartContractCallResultStatusFail,
+	}
+	if len(result.ConstantResult) > 0 {
+		callResult.RawHex = result.ConstantResult[0]
+	}
+	if reason, reverted := contractRevertReason(abi, result); reverted {
+		callResult.Exception = reason
+		return callResult
+	}
+
+	output, err := hex.DecodeString(callResult.RawHex)
+	if err != nil {
+		callResult.Exception = err.Error()
+		return callResult
+	}
+	values, err := DecodeABI(method.outputs, output, abi.IsTestNet)
+	if err != nil {
+		callResult.Exception = err.Error()
+		return callResult
+	}
+	value, _ := json.Marshal(ABIJSONValue(values))
+	callResult.Value = string(value)
+	callResult.Status = openwallet.SmartContractCallResultStatusSuccess
+	return callResult
+}
+
+//CreateSmartContractRawTransaction 创建合约调用交易单。
+//调用数据由Raw提供，或为空时按ABIParam编码；Value为转入合约的TRX；TRC10和feeLimit通过JSON格式的Raw设置。
+//创建后Raw为记录调用参数和待签名交易的JSON，Signatures中每个KeySignature的Message为交易ID
+func (decoder *ContractDecoder) CreateSmartContractRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.SmartContractRawTransaction) *openwallet.Error {
+
+	var (
+		wm          = decoder.wm
+		callValue   int64
+		found       *openwallet.Balance
+		rawHex      string
+		feeInfo     *txFeeInfo
+		notEnoughOf string
+	)
+
+	if rawTx.Account == nil {
+		return openwallet.Errorf(openwallet.ErrCreateRawSmartContractTransactionFailed, "account is nil")
+	}
+	accountID := rawTx.Account.AccountID
+
+	wm.senderMu.Lock()
+	defer wm.senderMu.Unlock()
+
+	param, abi, data, err := decoder.contractCallParam(rawTx)
+	if err != nil {
+		return openwallet.Errorf(openwallet.ErrContractCallMsgInvalid, "%v", err)
+	}
+	if len(rawTx.Value) > 0 {
+		callValue = common.StringNumToBigIntWithExp(rawTx.Value, wm.Decimal()).Int64()
+	}
+	txOpts := &TxOptions{FeeLimit: param.FeeLimit}
+	feeLimit := param.FeeLimit
+	if feeLimit <= 0 {
+		feeLimit = wm.Config.FeeLimit
+	}
+
+	addresses, err := wrapper.GetAddressList(0, -1, "AccountID", accountID)
+	if err != nil {
+		return openwallet.ConvertError(err)
+	}
+	if len(addresses) == 0 {
+		return openwallet.Errorf(openwallet.ErrAccountNotAddress, "[%s] have not addresses", accountID)
+	}
+	searchAddrs := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		searchAddrs = append(searchAddrs, address.Address)
+	}
+	balances, err := wm.Blockscanner.GetBalanceByAddress(searchAddrs...)
+	if err != nil {
+		return openwallet.ConvertError(err)
+	}
+	candidates, err := wm.selectSenderBalances(accountID, rawTx.TxFrom, "", balances)
+	if err != nil {
+		return openwallet.ConvertError(err)
+	}
+
+	for _, b := range candidates {
+
+		balance := common.StringNumToBigIntWithExp(b.Balance, wm.Decimal()).Int64()
+		if balance < callValue {
+			notEnoughOf = "TRX"
+			continue
+		}
+		if param.TokenID > 0 {
+			tokenBalance, err := wm.GetTRC10Balance(b.Address, strconv.FormatInt(param.TokenID, 10))
+			if err != nil || tokenBalance == nil || tokenBalance.Cmp(big.NewInt(param.CallTokenValue)) < 0 {
+				notEnoughOf = "token " + strconv.FormatInt(param.TokenID, 10)
+				continue
+			}
+		}
+
+		tc, err := wm.makeTriggerSmartContract(b.Address, rawTx.Coin.Contract.Address, data, callValue, param.TokenID, param.CallTokenValue)
+		if err != nil {
+			return openwallet.Errorf(openwallet.ErrContractCallMsgInvalid, "%v", err)
+		}
+
+		//模拟执行预估能量，预计revert则不创建交易单
+		result, err := wm.SimulateContractCall(tc)
+		if err != nil {
+			return openwallet.Errorf(openwallet.ErrCallFullNodeAPIFailed, "%v", err)
+		}
+		if reason, reverted := contractRevertReason(abi, result); reverted {
+			return openwallet.Errorf(openwallet.ErrCreateRawSmartContractTransactionFailed, "contract call will revert: %s", reason)
+		}
+
+		rawHex, err = wm.createAssetsTransaction(tc, core.Transaction_Contract_TriggerSmartContract, txOpts)
+		if err != nil {
+			return openwallet.Errorf(openwallet.ErrCreateRawSmartContractTransactionFailed, "%v", err)
+		}
+		feeInfo, err = wm.estimateContractCallFee(b.Address, rawHex, result.EnergyUsed)
+		if err != nil {
+			wm.Log.Std.Error("estimateContractCallFee from[%v] failed, err=%v", b.Address, err)
+			continue
+		}
+		burn := common.StringNumToBigIntWithExp(feeInfo.Fee.String(), wm.Decimal()).Int64()
+		if feeLimit > 0 && burn > feeLimit {
+			return openwallet.Errorf(openwallet.ErrInsufficientFees, "estimated fees: %s TRX exceed the fee limit: %s TRX", feeInfo.Fee.String(), wm.sunToTRX(feeLimit))
+		}
+		if balance < callValue+burn {
+			notEnoughOf = "TRX"
+			continue
+		}
+		found = b
+		break
+	}
+
+	if found == nil {
+		if len(notEnoughOf) == 0 {
+			notEnoughOf = "TRX"
+		}
+		return openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "the %s balance of account: %s is not enough to call contract", notEnoughOf, accountID)
+	}
+
+	addr, err := wrapper.GetAddress(found.Address)
+	if err != nil {
+		return openwallet.ConvertError(err)
+	}
+	txHash, err := getTxHash1(rawHex)
+	if err != nil {
+		return openwallet.ConvertError(err)
+	}
+	txID := hex.EncodeToString(txHash)
+
+	//锁定选中的发送地址，交易完成、交易单被放弃或超过有效期前不再被选择
+	if _, err := wm.lockSender(found.Address, rawHex); err != nil {
+		return openwallet.Errorf(openwallet.ErrCreateRawSmartContractTransactionFailed, "lock sender: %s failed: %v", found.Address, err)
+	}
+
+	param.FeeLimit = feeLimit
+	param.Transaction = rawHex
+	raw, err := json.Marshal(param)
+	if err != nil {
+		return openwallet.ConvertError(err)
+	}
+	rawTx.Raw = string(raw)
+	rawTx.RawType = openwallet.TxRawTypeJSON
+	rawTx.TxFrom = found.Address
+	rawTx.TxTo = rawTx.Coin.Contract.Address
+	rawTx.Fees = feeInfo.Fee.String()
+	rawTx.FeeRate = feeInfo.GasPrice.String()
+	rawTx.Signatures = map[string][]*openwallet.KeySignature{
+		accountID: {
+			{
+				EccType: wm.Config.CurveType,
+				Address: addr,
+				Message: txID,
+				RSV:     true,
+			},
+		},
+	}
+	rawTx.IsBuilt = true
+	return nil
+}
+
+//SignSmartContractRawTransaction 签名合约调用交易单，与普通交易单使用同样的签名方式，支持外部签名器
+func (decoder *ContractDecoder) SignSmartContractRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.SmartContractRawTransaction) *openwallet.Error {
+	if _, _, err := decoder.verifySmartContractRawTransaction(rawTx); err != nil {
+		return openwallet.Errorf(openwallet.ErrSignRawTransactionFailed, "%v", err)
+	}
+	if err := decoder.wm.signKeySignatures(wrapper, rawTx.Signatures[rawTx.Account.AccountID]); err != nil {
+		return openwallet.ConvertError(err)
+	}
+	return nil
+}
+
+//verifySmartContractRawTransaction 检查交易单内容与交易单字段一致：调用地址、合约地址、转入TRX数量、调用数据、TRC10、feeLimit和交易ID，
+//返回合约调用和待签名的交易hex
+func (decoder *ContractDecoder) verifySmartContractRawTransaction(rawTx *openwallet.SmartContractRawTransaction) (*core.TriggerSmartContract, string, error) {
+
+	wm := decoder.wm
+	if !rawTx.IsBuilt || rawTx.RawType != openwallet.TxRawTypeJSON {
+		return nil, "", fmt.Errorf("transaction is not built")
+	}
+	if rawTx.Account == nil {
+		return nil, "", fmt.Errorf("account is nil")
+	}
+	keySignatures := rawTx.Signatures[rawTx.Account.AccountID]
+	if len(keySignatures) != 1 || keySignatures[0].Address == nil {
+		return nil, "", fmt.Errorf("transaction should have one signature of account: %s", rawTx.Account.AccountID)
+	}
+	param := &ContractCallParam{}
+	if err := json.Unmarshal([]byte(rawTx.Raw), param); err != nil {
+		return nil, "", err
+	}
+	if len(param.Transaction) == 0 {
+		return nil, "", fmt.Errorf("transaction is not built")
+	}
+
+	txBytes, err := hex.DecodeString(param.Transaction)
+	if err != nil {
+		return nil, "", err
+	}
+	tx := &core.Transaction{}
+	if err := proto.Unmarshal(txBytes, tx); err != nil {
+		return nil, "", err
+	}
+	contracts := tx.GetRawData().GetContract()
+	if len(contracts) != 1 || contracts[0].GetType() != core.Transaction_Contract_TriggerSmartContract {
+		return nil, "", fmt.Errorf("transaction is not a smart contract call")
+	}
+	tc := &core.TriggerSmartContract{}
+	if err := proto.Unmarshal(contracts[0].GetParameter().GetValue(), tc); err != nil {
+		return nil, "", err
+	}
+
+	owner, err := EncodeAddress(hex.EncodeToString(tc.GetOwnerAddress()), wm.Config.IsTestNet)
+	if err != nil {
+		return nil, "", err
+	}
+	contractAddress, err := EncodeAddress(hex.EncodeToString(tc.GetContractAddress()), wm.Config.IsTestNet)
+	if err != nil {
+		return nil, "", err
+	}
+	if owner != rawTx.TxFrom || owner != keySignatures[0].Address.Address {
+		return nil, "", fmt.Errorf("transaction owner: %s is not equal to sender: %s", owner, rawTx.TxFrom)
+	}
+	if contractAddress != rawTx.TxTo || contractAddress != rawTx.Coin.Contract.Address {
+		return nil, "", fmt.Errorf("transaction contract: %s is not equal to contract: %s", contractAddress, rawTx.Coin.Contract.Address)
+	}
+	callValue := int64(0)
+	if len(rawTx.Value) > 0 {
+		callValue = common.StringNumToBigIntWithExp(rawTx.Value, wm.Decimal()).Int64()
+	}
+	if tc.GetCallValue() != callValue {
+		return nil, "", fmt.Errorf("transaction call value: %s is not equal to value: %s", wm.sunToTRX(tc.GetCallValue()), rawTx.Value)
+	}
+
+	//调用数据与请求的调用数据和ABIParam一致
+	if len(param.Data) == 0 && len(rawTx.ABIParam) == 0 {
+		return nil, "", fmt.Errorf("contract call data is not specified")
+	}
+	if len(param.Data) > 0 && !strings.EqualFold(hex.EncodeToString(tc.GetData()), param.Data) {
+		return nil, "", fmt.Errorf("transaction call data is not equal to requested data")
+	}
+	if len(rawTx.ABIParam) > 0 {
+		_, _, data, err := decoder.encodeABIParam(rawTx.Coin.Contract, rawTx.ABIParam)
+		if err != nil {
+			return nil, "", err
+		}
+		if !bytes.Equal(tc.GetData(), data) {
+			return nil, "", fmt.Errorf("transaction call data is not equal to ABIParam")
+		}
+	}
+	if tc.GetTokenId() != param.TokenID || tc.GetCallTokenValue() != param.CallTokenValue {
+		return nil, "", fmt.Errorf("transaction call token: %d:%d is not equal to requested: %d:%d", tc.GetTokenId(), tc.GetCallTokenValue(), param.TokenID, param.CallTokenValue)
+	}
+	if tx.GetRawData().GetFeeLimit() != param.FeeLimit {
+		return nil, "", fmt.Errorf("transaction fee limit: %d is not equal to requested: %d", tx.GetRawData().GetFeeLimit(), param.FeeLimit)
+	}
+
+	txHash, err := getTxHash(tx)
+	if err != nil {
+		return nil, "", err
+	}
+	if hex.EncodeToString(txHash) != keySignatures[0].Message {
+		return nil, "", fmt.Errorf("signature message is not equal to transaction id")
+	}
+	return tc, param.Transaction, nil
+}
+
+//SubmitSmartContractRawTransaction 广播合约调用交易单，AwaitResult为true时等待执行结果并解析事件
+func (decoder *ContractDecoder) SubmitSmartContractRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.SmartContractRawTransaction) (*openwallet.SmartContractReceipt, *openwallet.Error) {
+
+	wm := decoder.wm
+
+	_, txHex, err := decoder.verifySmartContractRawTransaction(rawTx)
+	if err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrSubmitRawSmartContractTransactionFailed, "%v", err)
+	}
+	keySignature := rawTx.Signatures[rawTx.Account.AccountID][0]
+	signedHex, err := InsertSignatureIntoRawTransaction(txHex, keySignature.Signature)
+	if err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrSubmitRawSmartContractTransactionFailed, "%v", err)
+	}
+	if err := wm.ValidSignedTokenTransaction(signedHex); err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrSubmitRawSmartContractTransactionFailed, "transaction signature verify failed: %v", err)
+	}
+	rawTx.IsCompleted = true
+
+	//确认发送地址已锁定，广播失败时解锁，广播成功后由交易跟踪器解锁或到有效期结束
+	lockedTxID, err := wm.lockSender(rawTx.TxFrom, txHex)
+	if err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrSubmitRawSmartContractTransactionFailed, "%v", err)
+	}
+	if wm.Config.DryRun {
+		if err := wm.DryRunTransaction(signedHex); err != nil {
+			wm.AddressLocker.Unlock(lockedTxID)
+			return nil, openwallet.Errorf(openwallet.ErrSubmitRawSmartContractTransactionFailed, "%v", err)
+		}
+	}
+
+	txid, err := wm.BroadcastTransaction(signedHex)
+	if broadcastErr, ok := err.(*BroadcastError); ok {
+		if broadcastErr.IsResubmitted() {
+			txid, err = broadcastErr.TxID, nil
+		} else {
+			err = broadcastErr.OWError()
+		}
+	}
+	if err != nil {
+		wm.AddressLocker.Unlock(lockedTxID)
+		return nil, openwallet.ConvertError(err)
+	}
+	wm.trackSubmitted(lockedTxID, signedHex)
+
+	rawTx.TxID = txid
+	rawTx.IsSubmit = true
+
+	receipt := &openwallet.SmartContractReceipt{
+		Coin:  rawTx.Coin,
+		TxID:  txid,
+		From:  rawTx.TxFrom,
+		To:    rawTx.TxTo,
+		Value: rawTx.Value,
+		Fees:  rawTx.Fees,
+	}
+	receipt.GenWxID()
+
+	if rawTx.AwaitResult {
+		timeout := DefaultContractAwaitTimeout
+		if rawTx.AwaitTimeout > 0 {
+			timeout = time.Duration(rawTx.AwaitTimeout) * time.Second
+		}
+		info, err := wm.awaitTransactionInfo(txid, timeout)
+		if err != nil {
+			//交易已广播，结果未知时仍返回回执
+			wm.Log.Std.Error("await transaction[%s] result failed, err=%v", txid, err)
+			return receipt, nil
+		}
+		decoder.fillSmartContractReceipt(receipt, info)
+		if block, err := wm.GetBlockByNum(receipt.BlockHeight); err == nil {
+			receipt.BlockHash = block.GetBlockHashID()
+		}
+	}
+	return receipt, nil
+}
+
+//awaitTransactionInfo 等待交易上链并返回执行结果
+func (wm *WalletManager) awaitTransactionInfo(txid string, timeout time.Duration) (*gjson.Result, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		info, err := wm.GetTransactionInfo(txid, false)
+		if err == nil && info.Get("id").Exists() {
+			return info, nil
+		}
+		if time.Now().Add(contractAwaitInterval).After(deadline) {
+			return nil, fmt.Errorf("transaction: %s is not confirmed in %v", txid, timeout)
+		}
+		time.Sleep(contractAwaitInterval)
+	}
+}
+
+//fillSmartContractReceipt 按gettransactioninfobyid结果填充回执的区块、状态、手续费和事件
+func (decoder *ContractDecoder) fillSmartContractReceipt(receipt *openwallet.SmartContractReceipt, info *gjson.Result) {
+
+	status, result, blockHeight := parseTxTrackerInfo(info)
+	receipt.RawReceipt = info.Raw
+	receipt.BlockHeight = blockHeight
+	receipt.ConfirmTime = info.Get("blockTimeStamp").Int() / 1000
+	receipt.Fees = decoder.wm.sunToTRX(info.Get("fee").Int())
+	receipt.Events = decoder.decodeContractEvents(receipt.Coin.Contract, info.Get("log"))
+
+	if status != TxStatusFailed {
+		receipt.Status = strconv.Itoa(openwallet.SmartContractCallResultStatusSuccess)
+		return
+	}
+	receipt.Status = strconv.Itoa(openwallet.SmartContractCallResultStatusFail)
+	receipt.Reason = result
+	abi, _ := decoder.contractABI(receipt.Coin.Contract)
+	for _, c := range info.Get("contractResult").Array() {
+		if abi != nil {
+			if reason, ok := abi.DecodeRevert(c.String()); ok {
+				receipt.Reason = result + ": " + reason
+				return
+			}
+		} else if reason, ok := DecodeRevertReason(c.String()); ok {
+			receipt.Reason = result + ": " + reason
+			return
+		}
+	}
+	if msg, err := hex.DecodeString(info.Get("resMessage").String()); err == nil && len(msg) > 0 {
+		receipt.Reason = result + ": " + string(msg)
+	}
+}
+
+//decodeContractEvents 按合约ABI解析事件日志，被调用合约使用contract的ABI，其他合约查询ABI，无法解析的日志忽略
+func (decoder *ContractDecoder) decodeContractEvents(contract openwallet.SmartContract, logs gjson.Result) []*openwallet.SmartContractEvent {
+
+	events := make([]*openwallet.SmartContractEvent, 0)
+	abis := make(map[string]*ABI)
+	for _, log := range logs.Array() {
+
+		address, err := EncodeAddress("41"+log.Get("address").String(), decoder.wm.Config.IsTestNet)
+		if err != nil {
+			continue
+		}
+		eventContract := &openwallet.SmartContract{Symbol: decoder.wm.Symbol(), Address: address}
+		if address == contract.Address {
+			eventContract = &contract
+		}
+
+		abi, exist := abis[address]
+		if !exist {
+			abi, _ = decoder.contractABI(*eventContract)
+			abis[address] = abi
+		}
+		if abi == nil {
+			continue
+		}
+
+		topics := make([]string, 0)
+		for _, topic := range log.Get("topics").Array() {
+			topics = append(topics, topic.String())
+		}
+		entry, values, err := abi.DecodeEvent(topics, log.Get("data").String())
+		if err != nil {
+			continue
+		}
+		value, _ := json.Marshal(ABIJSONValue(values))
+		events = append(events, &openwallet.SmartContractEvent{
+			Contract: eventContract,
+			Event:    entry.Name,
+			Value:    string(value),
+		})
+	}
+	return events
+}
@@ -0,0 +1,267 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
+)
+
+//testCallContract 使用测试ABI的合约，地址为TOADDRESS，不访问网络
+func testCallContract() openwallet.SmartContract {
+	contract := openwallet.SmartContract{Symbol: "TRX", Address: TOADDRESS, Protocol: TRC20}
+	contract.SetABI(testTRC20ABI)
+	return contract
+}
+
+func TestContractCallFee(t *testing.T) {
+
+	bandwidth := &txFeeInfo{Fee: decimal.RequireFromString("0.345")}
+	tests := []struct {
+		energyUsed, available int64
+		expect                string
+	}{
+		{30000, 0, "12.945"},
+		{30000, 10000, "8.745"},
+		{30000, 50000, "0.345"},
+	}
+	for _, test := range tests {
+		fee := contractCallFee(bandwidth, test.energyUsed, test.available, 420)
+		if !fee.Fee.Equal(decimal.RequireFromString(test.expect)) {
+			t.Errorf("contractCallFee(%d, %d) = %s, expected %s", test.energyUsed, test.available, fee.Fee.String(), test.expect)
+		}
+	}
+}
+
+func TestContractDecoder_ABIInfo(t *testing.T) {
+
+	decoder := NewContractDecoder(tw)
+	if err := decoder.SetABIInfo(TOADDRESS, openwallet.ABIInfo{ABI: "not json"}); err == nil {
+		t.Errorf("invalid ABI is set")
+	}
+	if err := decoder.SetABIInfo(TOADDRESS, openwallet.ABIInfo{ABI: gjson.Parse(testTRC20ABI).Value()}); err != nil {
+		t.Fatalf("SetABIInfo failed: %v", err)
+	}
+	info, err := decoder.GetABIInfo(TOADDRESS)
+	if err != nil || info.Address != TOADDRESS {
+		t.Fatalf("GetABIInfo = %+v, %v", info, err)
+	}
+
+	abi, method, data, err := decoder.encodeABIParam(openwallet.SmartContract{Address: TOADDRESS}, []string{"transfer", OWNERADDRESS, "1000"})
+	if err != nil {
+		t.Fatalf("encodeABIParam failed: %v", err)
+	}
+	if method.Name != "transfer" || hex.EncodeToString(data[:4]) != TRC20_TRANSFER_METHOD_ID {
+		t.Errorf("encodeABIParam = %s, %x", method.Name, data)
+	}
+	if _, args, err := abi.DecodeCall(data); err != nil || args[0] != OWNERADDRESS || args[1].(*big.Int).Int64() != 1000 {
+		t.Errorf("DecodeCall = %v, %v", args, err)
+	}
+	if _, _, _, err := decoder.encodeABIParam(openwallet.SmartContract{Address: TOADDRESS}, []string{"transfer", OWNERADDRESS}); err == nil {
+		t.Errorf("missing argument is accepted")
+	}
+}
+
+func TestABIEntry_ParseArguments(t *testing.T) {
+
+	abi, err := ParseABI(`[{"type": "function", "name": "f", "inputs": [
+		{"type": "bool"}, {"type": "uint256[]"}, {"type": "tuple", "components": [{"name": "to", "type": "address"}, {"name": "amount", "type": "uint256"}]}, {"type": "bytes"}
+	]}]`, false)
+	if err != nil {
+		t.Fatalf("ParseABI failed: %v", err)
+	}
+	method, _ := abi.Method("f")
+	args, err := method.ParseArguments([]string{"true", "[1, 2]", fmt.Sprintf(`{"to": "%s", "amount": "5"}`, TOADDRESS), "0xabcd"})
+	if err != nil {
+		t.Fatalf("ParseArguments failed: %v", err)
+	}
+	if _, err := abi.Pack("f", args...); err != nil {
+		t.Errorf("Pack parsed arguments failed: %v", err)
+	}
+	if _, err := method.ParseArguments([]string{"yes", "[]", "{}", ""}); err == nil {
+		t.Errorf("invalid bool is accepted")
+	}
+}
+
+func TestContractCallResult(t *testing.T) {
+
+	abi, _ := ParseABI(testTRC20ABI, false)
+	method, _ := abi.Method("info")
+
+	output, _ := EncodeABI(method.outputs, []interface{}{"Tether USD", 6})
+	result := contractCallResult(abi, method, &TransactionExtention{
+		ConstantResult: []string{hex.EncodeToString(output)},
+		Result:         &Return{Result: true},
+	})
+	if result.Status != openwallet.SmartContractCallResultStatusSuccess || result.Value != `["Tether USD","6"]` {
+		t.Errorf("contractCallResult = %+v", result)
+	}
+
+	custom, _ := EncodeCall("InsufficientBalance(uint256,uint256)", 1, 2)
+	result = contractCallResult(abi, method, &TransactionExtention{
+		ConstantResult: []string{hex.EncodeToString(custom)},
+		Result:         &Return{Result: true},
+		Ret:            "FAILED",
+	})
+	if result.Status != openwallet.SmartContractCallResultStatusFail || result.Exception != "InsufficientBalance(1, 2)" {
+		t.Errorf("reverted contractCallResult = %+v", result)
+	}
+}
+
+func TestContractDecoder_VerifySmartContractRawTransaction(t *testing.T) {
+
+	decoder := NewContractDecoder(tw)
+	contract := testCallContract()
+	data, _ := EncodeCall("transfer(address,uint256)", OWNERADDRESS, 1)
+
+	opts := testStakeTxOptions()
+	opts.FeeLimit = 50 * TRX
+	rawHex, err := tw.CreateContractCallTransaction(OWNERADDRESS, contract.Address, data, 1500000, 1002000, 10, opts)
+	if err != nil {
+		t.Fatalf("CreateContractCallTransaction failed: %v", err)
+	}
+	txHash, _ := getTxHash1(rawHex)
+	makeRaw := func(param ContractCallParam) string {
+		raw, _ := json.Marshal(param)
+		return string(raw)
+	}
+	callParam := ContractCallParam{TokenID: 1002000, CallTokenValue: 10, FeeLimit: 50 * TRX, Transaction: rawHex}
+	rawTx := &openwallet.SmartContractRawTransaction{
+		Coin:     openwallet.Coin{Symbol: "TRX", IsContract: true, Contract: contract},
+		Account:  &openwallet.AssetsAccount{AccountID: "A"},
+		Raw:      makeRaw(callParam),
+		RawType:  openwallet.TxRawTypeJSON,
+		Value:    "1.5",
+		TxFrom:   OWNERADDRESS,
+		TxTo:     contract.Address,
+		IsBuilt:  true,
+		ABIParam: []string{"transfer", OWNERADDRESS, "1"},
+		Signatures: map[string][]*openwallet.KeySignature{
+			"A": {{Address: &openwallet.Address{Address: OWNERADDRESS}, Message: hex.EncodeToString(txHash), RSV: true}},
+		},
+	}
+
+	tc, txHex, err := decoder.verifySmartContractRawTransaction(rawTx)
+	if err != nil {
+		t.Fatalf("verifySmartContractRawTransaction failed: %v", err)
+	}
+	if txHex != rawHex {
+		t.Errorf("verified transaction hex is not the built transaction")
+	}
+	if tc.GetTokenId() != 1002000 || tc.GetCallTokenValue() != 10 {
+		t.Errorf("TRC10 call value = %d:%d", tc.GetTokenId(), tc.GetCallTokenValue())
+	}
+	if decoded, err := tw.DecodeRawTransaction(rawHex); err != nil || decoded.FeeLimit != "50" {
+		t.Errorf("fee limit = %+v, %v", decoded, err)
+	}
+
+	//签名后可通过验证
+	signature, _ := tw.SignTransactionRef(hex.EncodeToString(txHash), PRIVATEKEY)
+	signedHex, err := InsertSignatureIntoRawTransaction(rawHex, signature)
+	if err != nil || tw.ValidSignedTokenTransaction(signedHex) != nil {
+		t.Errorf("signed contract call is not valid: %v", err)
+	}
+
+	tampered := []func(tx *openwallet.SmartContractRawTransaction){
+		func(tx *openwallet.SmartContractRawTransaction) { tx.Value = "2" },
+		func(tx *openwallet.SmartContractRawTransaction) { tx.TxFrom = TOADDRESS },
+		func(tx *openwallet.SmartContractRawTransaction) { tx.TxTo = OWNERADDRESS },
+		func(tx *openwallet.SmartContractRawTransaction) { tx.Signatures["A"][0].Message = "00" },
+		func(tx *openwallet.SmartContractRawTransaction) { tx.IsBuilt = false },
+		func(tx *openwallet.SmartContractRawTransaction) { tx.ABIParam = []string{"transfer", OWNERADDRESS, "2"} },
+		func(tx *openwallet.SmartContractRawTransaction) { tx.ABIParam = nil },
+		func(tx *openwallet.SmartContractRawTransaction) {
+			param := callParam
+			param.Data = hex.EncodeToString(data[:4])
+			tx.Raw = makeRaw(param)
+		},
+		func(tx *openwallet.SmartContractRawTransaction) {
+			param := callParam
+			param.CallTokenValue = 20
+			tx.Raw = makeRaw(param)
+		},
+		func(tx *openwallet.SmartContractRawTransaction) {
+			param := callParam
+			param.FeeLimit = 100 * TRX
+			tx.Raw = makeRaw(param)
+		},
+		//替换为同一调用地址和合约、调用数据不同的交易
+		func(tx *openwallet.SmartContractRawTransaction) {
+			other, _ := EncodeCall("transfer(address,uint256)", OWNERADDRESS, 2)
+			otherHex, _ := tw.CreateContractCallTransaction(OWNERADDRESS, contract.Address, other, 1500000, 1002000, 10, opts)
+			otherHash, _ := getTxHash1(otherHex)
+			param := callParam
+			param.Transaction = otherHex
+			tx.Raw = makeRaw(param)
+			tx.Signatures["A"][0].Message = hex.EncodeToString(otherHash)
+		},
+	}
+	for i, tamper := range tampered {
+		copied := *rawTx
+		keySignature := *rawTx.Signatures["A"][0]
+		copied.Signatures = map[string][]*openwallet.KeySignature{"A": {&keySignature}}
+		tamper(&copied)
+		if _, _, err := decoder.verifySmartContractRawTransaction(&copied); err == nil {
+			t.Errorf("tampered transaction %d is verified", i)
+		}
+	}
+}
+
+func TestContractDecoder_FillSmartContractReceipt(t *testing.T) {
+
+	decoder := NewContractDecoder(tw)
+	contract := testCallContract()
+	_, ownerBytes, _ := DecodeAddress(OWNERADDRESS, false)
+	_, toBytes, _ := DecodeAddress(TOADDRESS, false)
+
+	info := gjson.Parse(fmt.Sprintf(`{
+		"id": "abc",
+		"fee": 13450000,
+		"blockNumber": 100,
+		"blockTimeStamp": 1700000000000,
+		"receipt": {"result": "SUCCESS"},
+		"log": [{
+			"address": "%x",
+			"topics": ["ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef", "%064x", "%064x"],
+			"data": "%064x"
+		}]
+	}`, toBytes[1:], ownerBytes[1:], toBytes[1:], 42))
+
+	receipt := &openwallet.SmartContractReceipt{Coin: openwallet.Coin{Symbol: "TRX", IsContract: true, Contract: contract}}
+	decoder.fillSmartContractReceipt(receipt, &info)
+	if receipt.Status != "1" || receipt.BlockHeight != 100 || receipt.ConfirmTime != 1700000000 || receipt.Fees != "13.45" {
+		t.Errorf("receipt = %+v", receipt)
+	}
+	if len(receipt.Events) != 1 || receipt.Events[0].Event != "Transfer" ||
+		receipt.Events[0].Value != fmt.Sprintf(`{"from":"%s","to":"%s","value":"42"}`, OWNERADDRESS, TOADDRESS) {
+		t.Errorf("events = %+v", receipt.Events)
+	}
+
+	custom, _ := EncodeCall("InsufficientBalance(uint256,uint256)", 1, 2)
+	failed := gjson.Parse(fmt.Sprintf(`{"id": "abc", "result": "FAILED", "receipt": {"result": "REVERT"}, "contractResult": ["%x"]}`, custom))
+	receipt = &openwallet.SmartContractReceipt{Coin: openwallet.Coin{Symbol: "TRX", IsContract: true, Contract: contract}}
+	decoder.fillSmartContractReceipt(receipt, &failed)
+	if receipt.Status != "0" || receipt.Reason != "REVERT: InsufficientBalance(1, 2)" {
+		t.Errorf("failed receipt = %+v", receipt)
+	}
+}
@@ -0,0 +1,415 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	owcrypt "github.com/blocktree/go-owcrypt"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+	"github.com/golang/protobuf/proto"
+	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
+)
+
+//deployContractExtKey 部署合约交易单的扩展参数，contractType为CreateSmartContract时由CreateRawTransaction读取：
+//{"name": "", "bytecode": "hex", "abi": ABI的JSON数组或字符串, "args": ["构造函数参数"],
+// "consumeUserResourcePercent": 0~100, "originEnergyLimit": 能量, "callValue": "转入合约的TRX"}
+const deployContractExtKey = "deployContract"
+
+const (
+	//abiEntryReceive java-tron新增的receive类型，生成的protobuf中没有定义
+	abiEntryReceive core.SmartContract_ABI_Entry_EntryType = 5
+	//abiEntryError java-tron新增的自定义错误类型，生成的protobuf中没有定义
+	abiEntryError core.SmartContract_ABI_Entry_EntryType = 6
+)
+
+//abiEntryTypes ABI类型到链上枚举的映射
+var abiEntryTypes = map[string]core.SmartContract_ABI_Entry_EntryType{
+	"constructor": core.SmartContract_ABI_Entry_Constructor,
+	"function":    core.SmartContract_ABI_Entry_Function,
+	"event":       core.SmartContract_ABI_Entry_Event,
+	"fallback":    core.SmartContract_ABI_Entry_Fallback,
+	"receive":     abiEntryReceive,
+	"error":       abiEntryError,
+}
+
+//abiStateMutabilities ABI的stateMutability到链上枚举的映射
+var abiStateMutabilities = map[string]core.SmartContract_ABI_Entry_StateMutabilityType{
+	"pure":       core.SmartContract_ABI_Entry_Pure,
+	"view":       core.SmartContract_ABI_Entry_View,
+	"nonpayable": core.SmartContract_ABI_Entry_Nonpayable,
+	"payable":    core.SmartContract_ABI_Entry_Payable,
+}
+
+//DeployContractParam 部署合约参数
+type DeployContractParam struct {
+	Name                       string        //合约名称
+	Bytecode                   []byte        //编译后的合约字节码，不含构造参数
+	ABI                        string        //合约ABI的JSON
+	Args                       []interface{} //构造函数参数，按ABI中constructor的输入编码后追加到字节码
+	ConsumeUserResourcePercent int64         //调用者承担的能量比例，0~100
+	OriginEnergyLimit          int64         //部署者为每次调用最多提供的能量
+	CallValue                  int64         //部署时转入合约的sun
+}
+
+//makeCreateSmartContract 创建部署合约，部署者同时为合约的origin_address
+func (wm *WalletManager) makeCreateSmartContract(ownerAddress string, param *DeployContractParam) (*core.CreateSmartContract, error) {
+
+	if param == nil || len(param.Bytecode) == 0 {
+		return nil, fmt.Errorf("contract bytecode is empty")
+	}
+	if param.ConsumeUserResourcePercent < 0 || param.ConsumeUserResourcePercent > 100 {
+		return nil, fmt.Errorf("consume user resource percent should be in [0, 100]")
+	}
+	if param.OriginEnergyLimit <= 0 {
+		return nil, fmt.Errorf("origin energy limit should be positive")
+	}
+	if param.CallValue < 0 {
+		return nil, fmt.Errorf("call value should not be negative")
+	}
+	_, ownerAddressBytes, err := DecodeAddress(ownerAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return nil, err
+	}
+
+	abi, err := ParseABI(param.ABI, wm.Config.IsTestNet)
+	if err != nil {
+		return nil, err
+	}
+	abiProto, err := makeSmartContractABI(abi)
+	if err != nil {
+		return nil, err
+	}
+
+	bytecode := append([]byte{}, param.Bytecode...)
+	var constructor *ABIEntry
+	for _, e := range abi.Entries {
+		if e.Type == "constructor" {
+			constructor = e
+			break
+		}
+	}
+	if constructor != nil {
+		args, err := EncodeABI(constructor.inputs, param.Args)
+		if err != nil {
+			return nil, fmt.Errorf("constructor: %v", err)
+		}
+		bytecode = append(bytecode, args...)
+	} else if len(param.Args) > 0 {
+		return nil, fmt.Errorf("ABI has no constructor for arguments")
+	}
+
+	return &core.CreateSmartContract{
+		OwnerAddress: ownerAddressBytes,
+		NewContract: &core.SmartContract{
+			OriginAddress:              ownerAddressBytes,
+			Abi:                        abiProto,
+			Bytecode:                   bytecode,
+			CallValue:                  param.CallValue,
+			ConsumeUserResourcePercent: param.ConsumeUserResourcePercent,
+			Name:                       param.Name,
+			OriginEnergyLimit:          param.OriginEnergyLimit,
+		},
+	}, nil
+}
+
+//makeSmartContractABI 转换为链上保存的ABI，链上参数没有components，元组类型写为签名形式(T1,T2)
+func makeSmartContractABI(abi *ABI) (*core.SmartContract_ABI, error) {
+
+	makeParams := func(args []ABIArgument, types []*ABIType) []*core.SmartContract_ABI_Entry_Param {
+		params := make([]*core.SmartContract_ABI_Entry_Param, 0, len(args))
+		for i, arg := range args {
+			typ := arg.Type
+			if len(arg.Components) > 0 {
+				typ = types[i].String()
+			}
+			params = append(params, &core.SmartContract_ABI_Entry_Param{Indexed: arg.Indexed, Name: arg.Name, Type: typ})
+		}
+		return params
+	}
+
+	abiProto := &core.SmartContract_ABI{}
+	for _, e := range abi.Entries {
+		entryType, ok := abiEntryTypes[strings.ToLower(e.Type)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported ABI entry type: %s", e.Type)
+		}
+		entry := &core.SmartContract_ABI_Entry{
+			Anonymous: e.Anonymous,
+			Constant:  e.Constant,
+			Name:      e.Name,
+			Inputs:    makeParams(e.Inputs, e.inputs),
+			Outputs:   makeParams(e.Outputs, e.outputs),
+			Type:      entryType,
+			Payable:   e.Payable,
+		}
+		if len(e.StateMutability) > 0 {
+			v, ok := abiStateMutabilities[strings.ToLower(e.StateMutability)]
+			if !ok {
+				return nil, fmt.Errorf("unsupported state mutability: %s", e.StateMutability)
+			}
+			entry.StateMutability = v
+		}
+		abiProto.Entrys = append(abiProto.Entrys, entry)
+	}
+	return abiProto, nil
+}
+
+//CreateDeployContractTransaction 创建部署合约交易单，feeLimit通过opts设置，0使用配置。
+//返回待签名的交易hex和预测的合约地址，交易单签名后ID不变，合约地址也不变
+func (wm *WalletManager) CreateDeployContractTransaction(ownerAddress string, param *DeployContractParam, opts *TxOptions) (txRawHex string, contractAddress string, err error) {
+	cc, err := wm.makeCreateSmartContract(ownerAddress, param)
+	if err != nil {
+		return "", "", err
+	}
+	txRawHex, err = wm.createAssetsTransaction(cc, core.Transaction_Contract_CreateSmartContract, opts)
+	if err != nil {
+		return "", "", err
+	}
+	contractAddress, err = wm.PredictContractAddress(txRawHex)
+	if err != nil {
+		return "", "", err
+	}
+	return txRawHex, contractAddress, nil
+}
+
+//CreateDeployContractRawTransaction 创建部署合约的原始交易单，按选择策略选出余额足够支付转入金额和手续费的地址部署，
+//rawTx.To设置为预测的合约地址和转入的TRX，之后与其他交易单一样签名、验证和广播
+func (decoder *TransactionDecoder) CreateDeployContractRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction, param *DeployContractParam) error {
+
+	var (
+		wm        = decoder.wm
+		accountID = rawTx.Account.AccountID
+		txOpts    = NewTxOptions(rawTx.GetExtParam())
+	)
+
+	if rawTx.Coin.IsContract {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "deploy contract transaction should not be contract")
+	}
+
+	addresses, err := wrapper.GetAddressList(0, -1, "AccountID", accountID)
+	if err != nil {
+		return err
+	}
+	if len(addresses) == 0 {
+		return openwallet.Errorf(openwallet.ErrAccountNotAddress, "[%s] have not addresses", accountID)
+	}
+	searchAddrs := make([]string, 0)
+	for _, address := range addresses {
+		searchAddrs = append(searchAddrs, address.Address)
+	}
+	addrBalanceArray, err := wm.Blockscanner.GetBalanceByAddress(searchAddrs...)
+	if err != nil {
+		return err
+	}
+	addrBalanceArray, err = decoder.selectSenderBalances(rawTx, addrBalanceArray)
+	if err != nil {
+		return err
+	}
+
+	callValue := decimal.New(param.CallValue, -wm.Decimal())
+	for _, addrBalance := range addrBalanceArray {
+
+		addrBalance_dec, _ := decimal.NewFromString(addrBalance.Balance)
+		if addrBalance_dec.LessThanOrEqual(callValue) {
+			continue
+		}
+
+		rawHex, _, err := wm.CreateDeployContractTransaction(addrBalance.Address, param, txOpts)
+		if err != nil {
+			return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "%v", err)
+		}
+
+		feeInfo, err := wm.GetTransactionFeeEstimated(addrBalance.Address, rawHex)
+		if err != nil {
+			wm.Log.Std.Error("GetTransactionFeeEstimated from[%v] deploy contract failed, err=%v", addrBalance.Address, err)
+			continue
+		}
+
+		if addrBalance_dec.LessThan(callValue.Add(feeInfo.Fee)) {
+			continue
+		}
+
+		return decoder.createDeployContractRawTransaction(wrapper, rawTx, addrBalance.Address, rawHex, param.CallValue, feeInfo)
+	}
+
+	return openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "the balance is not enough to deploy contract")
+}
+
+//createDeployContractRawTransaction 按部署交易生成原始交易单，接收地址为预测的合约地址，不修改调用方的To
+func (decoder *TransactionDecoder) createDeployContractRawTransaction(
+	wrapper openwallet.WalletDAI,
+	rawTx *openwallet.RawTransaction,
+	from string,
+	rawHex string,
+	callValue int64,
+	feeInfo *txFeeInfo) error {
+
+	contractAddress, err := decoder.wm.PredictContractAddress(rawHex)
+	if err != nil {
+		return err
+	}
+	amount := decoder.wm.sunToTRX(callValue)
+	rawTx.To = map[string]string{contractAddress: amount}
+	rawTx.SetExtParam(contractTypeExtKey, core.Transaction_Contract_CreateSmartContract.String())
+
+	createTxErr := decoder.createRawTransactionParts(wrapper, rawTx, []*RawTxPart{
+		{
+			To:      contractAddress,
+			Amount:  amount,
+			rawHex:  rawHex,
+			balance: &AddrBalance{Address: from, TronBalance: big.NewInt(0)},
+			feeInfo: feeInfo,
+		},
+	})
+	if createTxErr != nil {
+		return createTxErr
+	}
+	return nil
+}
+
+//deployContractParamFromExt 读取扩展参数deployContract，构造函数参数按ABI类型解析
+func deployContractParamFromExt(ext gjson.Result, decimals int32, isTestnet bool) (*DeployContractParam, error) {
+
+	deploy := ext.Get(deployContractExtKey)
+	if !deploy.IsObject() {
+		return nil, fmt.Errorf("ext param %s is not set", deployContractExtKey)
+	}
+	bytecode, err := hex.DecodeString(strings.TrimPrefix(deploy.Get("bytecode").String(), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid contract bytecode: %v", err)
+	}
+	abiJSON := deploy.Get("abi")
+	abiData := abiJSON.Raw
+	if abiJSON.Type == gjson.String {
+		abiData = abiJSON.String()
+	}
+	callValue, err := decimal.NewFromString(deploy.Get("callValue").String())
+	if err != nil {
+		callValue = decimal.Zero
+	}
+
+	param := &DeployContractParam{
+		Name:                       deploy.Get("name").String(),
+		Bytecode:                   bytecode,
+		ABI:                        abiData,
+		ConsumeUserResourcePercent: deploy.Get("consumeUserResourcePercent").Int(),
+		OriginEnergyLimit:          deploy.Get("originEnergyLimit").Int(),
+		CallValue:                  callValue.Shift(decimals).IntPart(),
+	}
+
+	args := make([]string, 0)
+	for _, arg := range deploy.Get("args").Array() {
+		args = append(args, arg.String())
+	}
+	if len(args) > 0 {
+		abi, err := ParseABI(abiData, isTestnet)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range abi.Entries {
+			if e.Type == "constructor" {
+				if param.Args, err = e.ParseArguments(args); err != nil {
+					return nil, fmt.Errorf("constructor: %v", err)
+				}
+				break
+			}
+		}
+		if param.Args == nil {
+			return nil, fmt.Errorf("ABI has no constructor for arguments")
+		}
+	}
+	return param, nil
+}
+
+//PredictContractAddress 预测部署合约交易生成的合约地址
+func (wm *WalletManager) PredictContractAddress(txHex string) (string, error) {
+
+	txBytes, err := hex.DecodeString(txHex)
+	if err != nil {
+		return "", err
+	}
+	tx := &core.Transaction{}
+	if err := proto.Unmarshal(txBytes, tx); err != nil {
+		return "", err
+	}
+	contracts := tx.GetRawData().GetContract()
+	if len(contracts) != 1 || contracts[0].GetType() != core.Transaction_Contract_CreateSmartContract {
+		return "", fmt.Errorf("transaction is not a contract deployment")
+	}
+	cc := &core.CreateSmartContract{}
+	if err := proto.Unmarshal(contracts[0].GetParameter().GetValue(), cc); err != nil {
+		return "", err
+	}
+	txHash, err := getTxHash(tx)
+	if err != nil {
+		return "", err
+	}
+	return EncodeAddress(hex.EncodeToString(generateContractAddress(txHash, cc.GetOwnerAddress())), wm.Config.IsTestNet)
+}
+
+//generateContractAddress 合约地址为keccak256(交易ID + 部署者地址)的后20字节
+func generateContractAddress(txHash, ownerAddress []byte) []byte {
+	combined := append(append([]byte{}, txHash...), ownerAddress...)
+	return owcrypt.Hash(combined, 0, owcrypt.HASH_ALG_KECCAK256)[12:32]
+}
+
+//GetDeployedContractAddress 查询部署合约交易的执行结果，部署成功时返回合约地址
+func (wm *WalletManager) GetDeployedContractAddress(txid string) (string, error) {
+	info, err := wm.GetTransactionInfo(txid, false)
+	if err != nil {
+		return "", err
+	}
+	if !info.Get("id").Exists() {
+		return "", fmt.Errorf("transaction: %s is not confirmed", txid)
+	}
+	return wm.deployedContractAddress(info)
+}
+
+//AwaitDeployedContractAddress 等待部署合约交易上链，部署成功时返回合约地址
+func (wm *WalletManager) AwaitDeployedContractAddress(txid string, timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		timeout = DefaultContractAwaitTimeout
+	}
+	info, err := wm.awaitTransactionInfo(txid, timeout)
+	if err != nil {
+		return "", err
+	}
+	return wm.deployedContractAddress(info)
+}
+
+//deployedContractAddress 解析gettransactioninfobyid结果中的合约地址，部署失败时返回失败原因
+func (wm *WalletManager) deployedContractAddress(info *gjson.Result) (string, error) {
+
+	status, result, _ := parseTxTrackerInfo(info)
+	if status == TxStatusFailed {
+		if msg, err := hex.DecodeString(info.Get("resMessage").String()); err == nil && len(msg) > 0 {
+			result = result + ": " + string(msg)
+		}
+		return "", fmt.Errorf("deploy contract failed: %s", result)
+	}
+	contractAddress := info.Get("contract_address").String()
+	if len(contractAddress) == 0 {
+		return "", fmt.Errorf("transaction info has no contract address")
+	}
+	return EncodeAddress(contractAddress, wm.Config.IsTestNet)
+}
@@ -0,0 +1,233 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+	"github.com/golang/protobuf/proto"
+	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
+)
+
+const testDeployABI = `[
+	{"type": "constructor", "inputs": [{"name": "name", "type": "string"}, {"name": "supply", "type": "uint256"}], "stateMutability": "nonpayable"},
+	{"type": "function", "name": "pay", "inputs": [{"name": "order", "type": "tuple", "components": [{"name": "to", "type": "address"}, {"name": "amount", "type": "uint256"}]}], "outputs": [], "stateMutability": "payable"},
+	{"type": "event", "name": "Paid", "inputs": [{"name": "to", "type": "address", "indexed": true}], "anonymous": false},
+	{"type": "error", "name": "Unauthorized", "inputs": []},
+	{"type": "receive", "stateMutability": "payable"}
+]`
+
+func testDeployParam() *DeployContractParam {
+	return &DeployContractParam{
+		Name:                       "Payment",
+		Bytecode:                   []byte{0x60, 0x80, 0x60, 0x40, 0x52},
+		ABI:                        testDeployABI,
+		Args:                       []interface{}{"Payment", 1000000},
+		ConsumeUserResourcePercent: 30,
+		OriginEnergyLimit:          10000000,
+		CallValue:                  1500000,
+	}
+}
+
+func TestCreateDeployContractTransaction(t *testing.T) {
+
+	opts := testStakeTxOptions()
+	opts.FeeLimit = 1000 * TRX
+	txRaw, contractAddress, err := tw.CreateDeployContractTransaction(OWNERADDRESS, testDeployParam(), opts)
+	if err != nil {
+		t.Fatalf("CreateDeployContractTransaction failed: %v", err)
+	}
+
+	txBytes, _ := hex.DecodeString(txRaw)
+	tx := &core.Transaction{}
+	if err := proto.Unmarshal(txBytes, tx); err != nil {
+		t.Fatalf("unmarshal transaction failed: %v", err)
+	}
+	cc := &core.CreateSmartContract{}
+	proto.Unmarshal(tx.GetRawData().GetContract()[0].GetParameter().GetValue(), cc)
+	sc := cc.GetNewContract()
+
+	args, _ := EncodeABI([]*ABIType{{kind: abiKindString}, {kind: abiKindUint, size: 256}}, []interface{}{"Payment", 1000000})
+	if !bytes.Equal(sc.GetBytecode(), append([]byte{0x60, 0x80, 0x60, 0x40, 0x52}, args...)) {
+		t.Errorf("bytecode = %x", sc.GetBytecode())
+	}
+	if !bytes.Equal(sc.GetOriginAddress(), cc.GetOwnerAddress()) || sc.GetCallValue() != 1500000 ||
+		sc.GetConsumeUserResourcePercent() != 30 || sc.GetOriginEnergyLimit() != 10000000 || sc.GetName() != "Payment" {
+		t.Errorf("new contract = %+v", sc)
+	}
+	if tx.GetRawData().GetFeeLimit() != 1000*TRX {
+		t.Errorf("fee limit = %d", tx.GetRawData().GetFeeLimit())
+	}
+
+	//合约地址为keccak256(交易ID + 部署者地址)的后20字节
+	txHash, _ := getTxHash1(txRaw)
+	_, ownerBytes, _ := DecodeAddress(OWNERADDRESS, false)
+	expect, _ := EncodeAddress(hex.EncodeToString(generateContractAddress(txHash, ownerBytes)), false)
+	if contractAddress != expect {
+		t.Errorf("contract address = %s, expected %s", contractAddress, expect)
+	}
+
+	//签名不改变交易ID和合约地址
+	signature, _ := tw.SignTransactionRef(hex.EncodeToString(txHash), PRIVATEKEY)
+	signedHex, err := InsertSignatureIntoRawTransaction(txRaw, signature)
+	if err != nil || tw.ValidSignedTokenTransaction(signedHex) != nil {
+		t.Errorf("signed deployment is not valid: %v", err)
+	}
+	if predicted, err := tw.PredictContractAddress(signedHex); err != nil || predicted != contractAddress {
+		t.Errorf("PredictContractAddress = %s, %v", predicted, err)
+	}
+}
+
+func TestCreateDeployContractTransaction_Invalid(t *testing.T) {
+
+	tests := []func(param *DeployContractParam){
+		func(param *DeployContractParam) { param.Bytecode = nil },
+		func(param *DeployContractParam) { param.ConsumeUserResourcePercent = 101 },
+		func(param *DeployContractParam) { param.OriginEnergyLimit = 0 },
+		func(param *DeployContractParam) { param.CallValue = -1 },
+		func(param *DeployContractParam) { param.Args = []interface{}{"Payment"} },
+		func(param *DeployContractParam) { param.ABI = `[{"type": "function", "name": "f", "inputs": []}]` },
+		func(param *DeployContractParam) { param.ABI = "not json" },
+	}
+	for i, test := range tests {
+		param := testDeployParam()
+		test(param)
+		if _, _, err := tw.CreateDeployContractTransaction(OWNERADDRESS, param, testStakeTxOptions()); err == nil {
+			t.Errorf("invalid param %d is accepted", i)
+		}
+	}
+}
+
+func TestMakeSmartContractABI(t *testing.T) {
+
+	abi, _ := ParseABI(testDeployABI, false)
+	abiProto, err := makeSmartContractABI(abi)
+	if err != nil {
+		t.Fatalf("makeSmartContractABI failed: %v", err)
+	}
+	entrys := abiProto.GetEntrys()
+	if len(entrys) != 5 {
+		t.Fatalf("entry count = %d", len(entrys))
+	}
+	if entrys[0].GetType() != core.SmartContract_ABI_Entry_Constructor ||
+		entrys[0].GetStateMutability() != core.SmartContract_ABI_Entry_Nonpayable {
+		t.Errorf("constructor = %+v", entrys[0])
+	}
+	if entrys[1].GetInputs()[0].GetType() != "(address,uint256)" ||
+		entrys[1].GetStateMutability() != core.SmartContract_ABI_Entry_Payable {
+		t.Errorf("tuple function = %+v", entrys[1])
+	}
+	if entrys[2].GetType() != core.SmartContract_ABI_Entry_Event || !entrys[2].GetInputs()[0].GetIndexed() {
+		t.Errorf("event = %+v", entrys[2])
+	}
+	if entrys[3].GetType() != abiEntryError || entrys[4].GetType() != abiEntryReceive {
+		t.Errorf("error and receive = %v, %v", entrys[3].GetType(), entrys[4].GetType())
+	}
+}
+
+func TestVerifyRawTransactionIntent_DeployContract(t *testing.T) {
+
+	decoder := NewTransactionDecoder(tw)
+	wrapper := &localWalletDAI{
+		wallet:    &openwallet.Wallet{WalletID: "A"},
+		symbol:    "TRX",
+		addresses: []*openwallet.Address{{Address: OWNERADDRESS, AccountID: "A"}},
+	}
+	txRaw, contractAddress, err := tw.CreateDeployContractTransaction(OWNERADDRESS, testDeployParam(), testStakeTxOptions())
+	if err != nil {
+		t.Fatalf("CreateDeployContractTransaction failed: %v", err)
+	}
+
+	newRawTx := func() *openwallet.RawTransaction {
+		rawTx := &openwallet.RawTransaction{
+			Coin:    openwallet.Coin{Symbol: "TRX"},
+			Account: &openwallet.AssetsAccount{AccountID: "A"},
+		}
+		feeInfo := &txFeeInfo{GasPrice: decimal.Zero, Fee: decimal.RequireFromString("0.5")}
+		if err := decoder.createDeployContractRawTransaction(wrapper, rawTx, OWNERADDRESS, txRaw, 1500000, feeInfo); err != nil {
+			t.Fatalf("createDeployContractRawTransaction failed: %v", err)
+		}
+		return rawTx
+	}
+
+	rawTx := newRawTx()
+	if rawTx.To[contractAddress] != "1.5" || rawTx.TxAmount != "-2.000000" ||
+		rawTx.GetExtParam().Get(contractTypeExtKey).String() != core.Transaction_Contract_CreateSmartContract.String() {
+		t.Errorf("deploy raw transaction = %+v", rawTx)
+	}
+	if sig := rawTx.Signatures["A"]; len(sig) != 1 || sig[0].Address.Address != OWNERADDRESS {
+		t.Errorf("deploy key signatures = %+v", sig)
+	}
+	if err := decoder.verifyRawTransactionIntent(rawTx); err != nil {
+		t.Errorf("verifyRawTransactionIntent deploy failed: %v", err)
+	}
+
+	rawTx = newRawTx()
+	rawTx.To = map[string]string{contractAddress: "2"}
+	if err := decoder.verifyRawTransactionIntent(rawTx); err == nil {
+		t.Errorf("tampered call value should be rejected")
+	}
+	rawTx = newRawTx()
+	rawTx.To = map[string]string{TOADDRESS: "1.5"}
+	if err := decoder.verifyRawTransactionIntent(rawTx); err == nil {
+		t.Errorf("unexpected contract address should be rejected")
+	}
+}
+
+func TestDeployContractParamFromExt(t *testing.T) {
+
+	ext := gjson.Parse(fmt.Sprintf(`{"deployContract": {"name": "Payment", "bytecode": "0x6080604052", "abi": %s,
+		"args": ["Payment", "1000000"], "consumeUserResourcePercent": 30, "originEnergyLimit": 10000000, "callValue": "1.5"}}`, testDeployABI))
+	param, err := deployContractParamFromExt(ext, 6, false)
+	if err != nil {
+		t.Fatalf("deployContractParamFromExt failed: %v", err)
+	}
+	expect := testDeployParam()
+	cc, _ := tw.makeCreateSmartContract(OWNERADDRESS, param)
+	ccExpect, _ := tw.makeCreateSmartContract(OWNERADDRESS, expect)
+	if !proto.Equal(cc, ccExpect) {
+		t.Errorf("deploy param = %+v", param)
+	}
+
+	if _, err := deployContractParamFromExt(gjson.Parse(`{}`), 6, false); err == nil {
+		t.Errorf("missing deploy param should be rejected")
+	}
+	bad := gjson.Parse(`{"deployContract": {"bytecode": "6080", "abi": "[]", "args": ["1"]}}`)
+	if _, err := deployContractParamFromExt(bad, 6, false); err == nil {
+		t.Errorf("arguments without constructor should be rejected")
+	}
+}
+
+func TestDeployedContractAddress(t *testing.T) {
+
+	_, toBytes, _ := DecodeAddress(TOADDRESS, false)
+
+	info := gjson.Parse(fmt.Sprintf(`{"id": "abc", "blockNumber": 100, "contract_address": "%x", "receipt": {"result": "SUCCESS"}}`, toBytes))
+	if addr, err := tw.deployedContractAddress(&info); err != nil || addr != TOADDRESS {
+		t.Errorf("deployedContractAddress = %s, %v", addr, err)
+	}
+
+	failed := gjson.Parse(fmt.Sprintf(`{"id": "abc", "result": "FAILED", "contract_address": "%x", "receipt": {"result": "OUT_OF_ENERGY"}, "resMessage": "%x"}`, toBytes, "Not enough energy"))
+	if _, err := tw.deployedContractAddress(&failed); err == nil || err.Error() != "deploy contract failed: OUT_OF_ENERGY: Not enough energy" {
+		t.Errorf("failed deployment error = %v", err)
+	}
+}
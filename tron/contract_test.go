@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"github.com/blocktree/openwallet/v2/log"
 	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/tidwall/gjson"
 	"math/big"
 	"testing"
 )
@@ -175,3 +176,46 @@ func TestParseTransferEvent(t *testing.T) {
 	}
 	log.Infof("%s: %d", to, amount)
 }
+
+func TestDecodeRevertReason(t *testing.T) {
+
+	reason, ok := DecodeRevertReason("08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000001e536166654d6174683a207375627472616374696f6e206f766572666c6f770000")
+	if !ok || reason != "SafeMath: subtraction overflow" {
+		t.Errorf("unexpected revert reason: %s", reason)
+	}
+
+	reason, ok = DecodeRevertReason("4e487b710000000000000000000000000000000000000000000000000000000000000011")
+	if !ok || reason != "panic code 0x11" {
+		t.Errorf("unexpected panic reason: %s", reason)
+	}
+
+	//balanceOf正常返回值
+	if _, ok = DecodeRevertReason("00000000000000000000000000000000000000000000000000000000000f4240"); ok {
+		t.Errorf("normal result should not be revert")
+	}
+
+	//长度越界
+	if _, ok = DecodeRevertReason("08c379a000000000000000000000000000000000000000000000000000000000000000ff0000000000000000000000000000000000000000000000000000000000000001"); ok {
+		t.Errorf("invalid offset should not be decoded")
+	}
+}
+
+func TestTransactionExtention_RevertReason(t *testing.T) {
+
+	json := gjson.Parse(`{"result":{"result":true},"energy_used":13045,"constant_result":["08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000001e536166654d6174683a207375627472616374696f6e206f766572666c6f770000"],"transaction":{"ret":[{"ret":"FAILED"}]}}`)
+	reason, reverted := NewTransactionExtention(&json).RevertReason()
+	if !reverted || reason != "SafeMath: subtraction overflow" {
+		t.Errorf("unexpected revert reason: %s", reason)
+	}
+
+	//USDT黑名单，require没有错误信息
+	json = gjson.Parse(`{"result":{"result":true},"energy_used":1200,"constant_result":[""],"transaction":{"ret":[{"ret":"FAILED"}]}}`)
+	if reason, reverted = NewTransactionExtention(&json).RevertReason(); !reverted {
+		t.Errorf("failed transaction should be reverted")
+	}
+
+	json = gjson.Parse(`{"result":{"result":true},"energy_used":14650,"constant_result":["0000000000000000000000000000000000000000000000000000000000000001"],"transaction":{"ret":[{}]}}`)
+	if reason, reverted = NewTransactionExtention(&json).RevertReason(); reverted {
+		t.Errorf("successful call should not be reverted: %s", reason)
+	}
+}
@@ -0,0 +1,393 @@
+// Hand-maintained, NOT generated by protoc.
+//
+// protoc and protoc-gen-go are not part of this repository's build, so the
+// Stake 2.0 messages below are written by hand in the legacy golang/protobuf
+// v1.3 layout used by Contract.pb.go, and their ContractType values are
+// registered here instead of editing the generated Tron.pb.go. They have no
+// file descriptor; encoding relies on the struct tags only. Field numbers and
+// names follow java-tron's
+// protocol/src/main/protobuf/core/contract/balance_contract.proto and the
+// Transaction.Contract.ContractType enum in core/Tron.proto:
+//
+//	message FreezeBalanceV2Contract {
+//	  bytes owner_address = 1;
+//	  int64 frozen_balance = 2;
+//	  ResourceCode resource = 3;
+//	}
+//	message UnfreezeBalanceV2Contract {
+//	  bytes owner_address = 1;
+//	  int64 unfreeze_balance = 2;
+//	  ResourceCode resource = 3;
+//	}
+//	message WithdrawExpireUnfreezeContract {
+//	  bytes owner_address = 1;
+//	}
+//	message DelegateResourceContract {
+//	  bytes owner_address = 1;
+//	  ResourceCode resource = 2;
+//	  int64 balance = 3;
+//	  bytes receiver_address = 4;
+//	  bool lock = 5;
+//	  int64 lock_period = 6;
+//	}
+//	message UnDelegateResourceContract {
+//	  bytes owner_address = 1;
+//	  ResourceCode resource = 2;
+//	  int64 balance = 3;
+//	  bytes receiver_address = 4;
+//	}
+//	message CancelAllUnfreezeV2Contract {
+//	  bytes owner_address = 1;
+//	}
+//
+// When the core package is regenerated from the updated protos with the same
+// protoc-gen-go version as Tron.pb.go, delete this file.
+
+package core
+
+import (
+	proto "github.com/golang/protobuf/proto"
+)
+
+// Stake 2.0 contract types from Transaction.Contract.ContractType
+const (
+	Transaction_Contract_FreezeBalanceV2Contract        Transaction_Contract_ContractType = 54
+	Transaction_Contract_UnfreezeBalanceV2Contract      Transaction_Contract_ContractType = 55
+	Transaction_Contract_WithdrawExpireUnfreezeContract Transaction_Contract_ContractType = 56
+	Transaction_Contract_DelegateResourceContract       Transaction_Contract_ContractType = 57
+	Transaction_Contract_UnDelegateResourceContract     Transaction_Contract_ContractType = 58
+	Transaction_Contract_CancelAllUnfreezeV2Contract    Transaction_Contract_ContractType = 59
+)
+
+type FreezeBalanceV2Contract struct {
+	OwnerAddress         []byte       `protobuf:"bytes,1,opt,name=owner_address,json=ownerAddress,proto3" json:"owner_address,omitempty"`
+	FrozenBalance        int64        `protobuf:"varint,2,opt,name=frozen_balance,json=frozenBalance,proto3" json:"frozen_balance,omitempty"`
+	Resource             ResourceCode `protobuf:"varint,3,opt,name=resource,proto3,enum=protocol.ResourceCode" json:"resource,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *FreezeBalanceV2Contract) Reset()         { *m = FreezeBalanceV2Contract{} }
+func (m *FreezeBalanceV2Contract) String() string { return proto.CompactTextString(m) }
+func (*FreezeBalanceV2Contract) ProtoMessage()    {}
+
+func (m *FreezeBalanceV2Contract) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FreezeBalanceV2Contract.Unmarshal(m, b)
+}
+func (m *FreezeBalanceV2Contract) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FreezeBalanceV2Contract.Marshal(b, m, deterministic)
+}
+func (m *FreezeBalanceV2Contract) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FreezeBalanceV2Contract.Merge(m, src)
+}
+func (m *FreezeBalanceV2Contract) XXX_Size() int {
+	return xxx_messageInfo_FreezeBalanceV2Contract.Size(m)
+}
+func (m *FreezeBalanceV2Contract) XXX_DiscardUnknown() {
+	xxx_messageInfo_FreezeBalanceV2Contract.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FreezeBalanceV2Contract proto.InternalMessageInfo
+
+func (m *FreezeBalanceV2Contract) GetOwnerAddress() []byte {
+	if m != nil {
+		return m.OwnerAddress
+	}
+	return nil
+}
+
+func (m *FreezeBalanceV2Contract) GetFrozenBalance() int64 {
+	if m != nil {
+		return m.FrozenBalance
+	}
+	return 0
+}
+
+func (m *FreezeBalanceV2Contract) GetResource() ResourceCode {
+	if m != nil {
+		return m.Resource
+	}
+	return ResourceCode_BANDWIDTH
+}
+
+type UnfreezeBalanceV2Contract struct {
+	OwnerAddress         []byte       `protobuf:"bytes,1,opt,name=owner_address,json=ownerAddress,proto3" json:"owner_address,omitempty"`
+	UnfreezeBalance      int64        `protobuf:"varint,2,opt,name=unfreeze_balance,json=unfreezeBalance,proto3" json:"unfreeze_balance,omitempty"`
+	Resource             ResourceCode `protobuf:"varint,3,opt,name=resource,proto3,enum=protocol.ResourceCode" json:"resource,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *UnfreezeBalanceV2Contract) Reset()         { *m = UnfreezeBalanceV2Contract{} }
+func (m *UnfreezeBalanceV2Contract) String() string { return proto.CompactTextString(m) }
+func (*UnfreezeBalanceV2Contract) ProtoMessage()    {}
+
+func (m *UnfreezeBalanceV2Contract) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UnfreezeBalanceV2Contract.Unmarshal(m, b)
+}
+func (m *UnfreezeBalanceV2Contract) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UnfreezeBalanceV2Contract.Marshal(b, m, deterministic)
+}
+func (m *UnfreezeBalanceV2Contract) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UnfreezeBalanceV2Contract.Merge(m, src)
+}
+func (m *UnfreezeBalanceV2Contract) XXX_Size() int {
+	return xxx_messageInfo_UnfreezeBalanceV2Contract.Size(m)
+}
+func (m *UnfreezeBalanceV2Contract) XXX_DiscardUnknown() {
+	xxx_messageInfo_UnfreezeBalanceV2Contract.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UnfreezeBalanceV2Contract proto.InternalMessageInfo
+
+func (m *UnfreezeBalanceV2Contract) GetOwnerAddress() []byte {
+	if m != nil {
+		return m.OwnerAddress
+	}
+	return nil
+}
+
+func (m *UnfreezeBalanceV2Contract) GetUnfreezeBalance() int64 {
+	if m != nil {
+		return m.UnfreezeBalance
+	}
+	return 0
+}
+
+func (m *UnfreezeBalanceV2Contract) GetResource() ResourceCode {
+	if m != nil {
+		return m.Resource
+	}
+	return ResourceCode_BANDWIDTH
+}
+
+type WithdrawExpireUnfreezeContract struct {
+	OwnerAddress         []byte   `protobuf:"bytes,1,opt,name=owner_address,json=ownerAddress,proto3" json:"owner_address,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WithdrawExpireUnfreezeContract) Reset()         { *m = WithdrawExpireUnfreezeContract{} }
+func (m *WithdrawExpireUnfreezeContract) String() string { return proto.CompactTextString(m) }
+func (*WithdrawExpireUnfreezeContract) ProtoMessage()    {}
+
+func (m *WithdrawExpireUnfreezeContract) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WithdrawExpireUnfreezeContract.Unmarshal(m, b)
+}
+func (m *WithdrawExpireUnfreezeContract) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WithdrawExpireUnfreezeContract.Marshal(b, m, deterministic)
+}
+func (m *WithdrawExpireUnfreezeContract) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WithdrawExpireUnfreezeContract.Merge(m, src)
+}
+func (m *WithdrawExpireUnfreezeContract) XXX_Size() int {
+	return xxx_messageInfo_WithdrawExpireUnfreezeContract.Size(m)
+}
+func (m *WithdrawExpireUnfreezeContract) XXX_DiscardUnknown() {
+	xxx_messageInfo_WithdrawExpireUnfreezeContract.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WithdrawExpireUnfreezeContract proto.InternalMessageInfo
+
+func (m *WithdrawExpireUnfreezeContract) GetOwnerAddress() []byte {
+	if m != nil {
+		return m.OwnerAddress
+	}
+	return nil
+}
+
+type CancelAllUnfreezeV2Contract struct {
+	OwnerAddress         []byte   `protobuf:"bytes,1,opt,name=owner_address,json=ownerAddress,proto3" json:"owner_address,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CancelAllUnfreezeV2Contract) Reset()         { *m = CancelAllUnfreezeV2Contract{} }
+func (m *CancelAllUnfreezeV2Contract) String() string { return proto.CompactTextString(m) }
+func (*CancelAllUnfreezeV2Contract) ProtoMessage()    {}
+
+func (m *CancelAllUnfreezeV2Contract) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CancelAllUnfreezeV2Contract.Unmarshal(m, b)
+}
+func (m *CancelAllUnfreezeV2Contract) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CancelAllUnfreezeV2Contract.Marshal(b, m, deterministic)
+}
+func (m *CancelAllUnfreezeV2Contract) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CancelAllUnfreezeV2Contract.Merge(m, src)
+}
+func (m *CancelAllUnfreezeV2Contract) XXX_Size() int {
+	return xxx_messageInfo_CancelAllUnfreezeV2Contract.Size(m)
+}
+func (m *CancelAllUnfreezeV2Contract) XXX_DiscardUnknown() {
+	xxx_messageInfo_CancelAllUnfreezeV2Contract.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CancelAllUnfreezeV2Contract proto.InternalMessageInfo
+
+func (m *CancelAllUnfreezeV2Contract) GetOwnerAddress() []byte {
+	if m != nil {
+		return m.OwnerAddress
+	}
+	return nil
+}
+
+type DelegateResourceContract struct {
+	OwnerAddress         []byte       `protobuf:"bytes,1,opt,name=owner_address,json=ownerAddress,proto3" json:"owner_address,omitempty"`
+	Resource             ResourceCode `protobuf:"varint,2,opt,name=resource,proto3,enum=protocol.ResourceCode" json:"resource,omitempty"`
+	Balance              int64        `protobuf:"varint,3,opt,name=balance,proto3" json:"balance,omitempty"`
+	ReceiverAddress      []byte       `protobuf:"bytes,4,opt,name=receiver_address,json=receiverAddress,proto3" json:"receiver_address,omitempty"`
+	Lock                 bool         `protobuf:"varint,5,opt,name=lock,proto3" json:"lock,omitempty"`
+	LockPeriod           int64        `protobuf:"varint,6,opt,name=lock_period,json=lockPeriod,proto3" json:"lock_period,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *DelegateResourceContract) Reset()         { *m = DelegateResourceContract{} }
+func (m *DelegateResourceContract) String() string { return proto.CompactTextString(m) }
+func (*DelegateResourceContract) ProtoMessage()    {}
+
+func (m *DelegateResourceContract) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DelegateResourceContract.Unmarshal(m, b)
+}
+func (m *DelegateResourceContract) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DelegateResourceContract.Marshal(b, m, deterministic)
+}
+func (m *DelegateResourceContract) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DelegateResourceContract.Merge(m, src)
+}
+func (m *DelegateResourceContract) XXX_Size() int {
+	return xxx_messageInfo_DelegateResourceContract.Size(m)
+}
+func (m *DelegateResourceContract) XXX_DiscardUnknown() {
+	xxx_messageInfo_DelegateResourceContract.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DelegateResourceContract proto.InternalMessageInfo
+
+func (m *DelegateResourceContract) GetOwnerAddress() []byte {
+	if m != nil {
+		return m.OwnerAddress
+	}
+	return nil
+}
+
+func (m *DelegateResourceContract) GetResource() ResourceCode {
+	if m != nil {
+		return m.Resource
+	}
+	return ResourceCode_BANDWIDTH
+}
+
+func (m *DelegateResourceContract) GetBalance() int64 {
+	if m != nil {
+		return m.Balance
+	}
+	return 0
+}
+
+func (m *DelegateResourceContract) GetReceiverAddress() []byte {
+	if m != nil {
+		return m.ReceiverAddress
+	}
+	return nil
+}
+
+func (m *DelegateResourceContract) GetLock() bool {
+	if m != nil {
+		return m.Lock
+	}
+	return false
+}
+
+func (m *DelegateResourceContract) GetLockPeriod() int64 {
+	if m != nil {
+		return m.LockPeriod
+	}
+	return 0
+}
+
+type UnDelegateResourceContract struct {
+	OwnerAddress         []byte       `protobuf:"bytes,1,opt,name=owner_address,json=ownerAddress,proto3" json:"owner_address,omitempty"`
+	Resource             ResourceCode `protobuf:"varint,2,opt,name=resource,proto3,enum=protocol.ResourceCode" json:"resource,omitempty"`
+	Balance              int64        `protobuf:"varint,3,opt,name=balance,proto3" json:"balance,omitempty"`
+	ReceiverAddress      []byte       `protobuf:"bytes,4,opt,name=receiver_address,json=receiverAddress,proto3" json:"receiver_address,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *UnDelegateResourceContract) Reset()         { *m = UnDelegateResourceContract{} }
+func (m *UnDelegateResourceContract) String() string { return proto.CompactTextString(m) }
+func (*UnDelegateResourceContract) ProtoMessage()    {}
+
+func (m *UnDelegateResourceContract) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_UnDelegateResourceContract.Unmarshal(m, b)
+}
+func (m *UnDelegateResourceContract) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_UnDelegateResourceContract.Marshal(b, m, deterministic)
+}
+func (m *UnDelegateResourceContract) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_UnDelegateResourceContract.Merge(m, src)
+}
+func (m *UnDelegateResourceContract) XXX_Size() int {
+	return xxx_messageInfo_UnDelegateResourceContract.Size(m)
+}
+func (m *UnDelegateResourceContract) XXX_DiscardUnknown() {
+	xxx_messageInfo_UnDelegateResourceContract.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_UnDelegateResourceContract proto.InternalMessageInfo
+
+func (m *UnDelegateResourceContract) GetOwnerAddress() []byte {
+	if m != nil {
+		return m.OwnerAddress
+	}
+	return nil
+}
+
+func (m *UnDelegateResourceContract) GetResource() ResourceCode {
+	if m != nil {
+		return m.Resource
+	}
+	return ResourceCode_BANDWIDTH
+}
+
+func (m *UnDelegateResourceContract) GetBalance() int64 {
+	if m != nil {
+		return m.Balance
+	}
+	return 0
+}
+
+func (m *UnDelegateResourceContract) GetReceiverAddress() []byte {
+	if m != nil {
+		return m.ReceiverAddress
+	}
+	return nil
+}
+
+func init() {
+	Transaction_Contract_ContractType_name[int32(Transaction_Contract_FreezeBalanceV2Contract)] = "FreezeBalanceV2Contract"
+	Transaction_Contract_ContractType_value["FreezeBalanceV2Contract"] = int32(Transaction_Contract_FreezeBalanceV2Contract)
+	Transaction_Contract_ContractType_name[int32(Transaction_Contract_UnfreezeBalanceV2Contract)] = "UnfreezeBalanceV2Contract"
+	Transaction_Contract_ContractType_value["UnfreezeBalanceV2Contract"] = int32(Transaction_Contract_UnfreezeBalanceV2Contract)
+	Transaction_Contract_ContractType_name[int32(Transaction_Contract_WithdrawExpireUnfreezeContract)] = "WithdrawExpireUnfreezeContract"
+	Transaction_Contract_ContractType_value["WithdrawExpireUnfreezeContract"] = int32(Transaction_Contract_WithdrawExpireUnfreezeContract)
+	Transaction_Contract_ContractType_name[int32(Transaction_Contract_DelegateResourceContract)] = "DelegateResourceContract"
+	Transaction_Contract_ContractType_value["DelegateResourceContract"] = int32(Transaction_Contract_DelegateResourceContract)
+	Transaction_Contract_ContractType_name[int32(Transaction_Contract_UnDelegateResourceContract)] = "UnDelegateResourceContract"
+	Transaction_Contract_ContractType_value["UnDelegateResourceContract"] = int32(Transaction_Contract_UnDelegateResourceContract)
+	Transaction_Contract_ContractType_name[int32(Transaction_Contract_CancelAllUnfreezeV2Contract)] = "CancelAllUnfreezeV2Contract"
+	Transaction_Contract_ContractType_value["CancelAllUnfreezeV2Contract"] = int32(Transaction_Contract_CancelAllUnfreezeV2Contract)
+	proto.RegisterType((*FreezeBalanceV2Contract)(nil), "protocol.FreezeBalanceV2Contract")
+	proto.RegisterType((*UnfreezeBalanceV2Contract)(nil), "protocol.UnfreezeBalanceV2Contract")
+	proto.RegisterType((*WithdrawExpireUnfreezeContract)(nil), "protocol.WithdrawExpireUnfreezeContract")
+	proto.RegisterType((*DelegateResourceContract)(nil), "protocol.DelegateResourceContract")
+	proto.RegisterType((*UnDelegateResourceContract)(nil), "protocol.UnDelegateResourceContract")
+	proto.RegisterType((*CancelAllUnfreezeV2Contract)(nil), "protocol.CancelAllUnfreezeV2Contract")
+}
@@ -16,6 +16,8 @@
 package tron
 
 import (
+	"sync"
+
 	"github.com/blocktree/openwallet/v2/hdkeystore"
 	"github.com/blocktree/openwallet/v2/log"
 	"github.com/blocktree/openwallet/v2/openwallet"
@@ -47,6 +49,15 @@ type WalletManager struct {
 	AddrDecoder     openwallet.AddressDecoder       //地址编码器
 	TxDecoder       openwallet.TransactionDecoder   //交易单编码器
 	ContractDecoder openwallet.SmartContractDecoder //
+	RefBlockCache   *RefBlockCache                  //交易参考区块缓存
+	TxTracker       *TxTracker                      //广播后交易跟踪器
+
+	AddressSelectors map[string]AddressSelector //发送地址选择策略
+	AddressLocker    *AddressLocker             //发送地址锁
+	Signer           Signer                     //外部签名器，非空时代替本地私钥签名
+
+	senderMu     sync.Mutex    //创建交易单时选择和锁定发送地址的互斥锁，避免并发创建选中同一地址
+	broadcastLog *broadcastLog //本进程广播过的交易
 }
 
 // NewWalletManager create instance
@@ -61,6 +72,11 @@ func NewWalletManager() *WalletManager {
 	wm.TxDecoder = NewTransactionDecoder(&wm)
 	wm.Log = log.NewOWLogger(wm.Symbol())
 	wm.ContractDecoder = NewContractDecoder(&wm)
+	wm.RefBlockCache = NewRefBlockCache(wm.Config.RefBlockCacheTime)
+	wm.TxTracker = NewTxTracker(&wm)
+	wm.AddressSelectors = NewAddressSelectors(&wm)
+	wm.AddressLocker = NewAddressLocker()
+	wm.broadcastLog = newBroadcastLog()
 	//wm.WalletClient = NewClient("http://192.168.27.124:18090", "", true)
 	return &wm
 }
@@ -22,7 +22,9 @@ import (
 
 	"github.com/blocktree/go-owcdrivers/addressEncoder"
 	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
 	"github.com/imroc/req"
+	"github.com/shopspring/decimal"
 	"github.com/tidwall/gjson"
 )
 
@@ -173,6 +175,7 @@ func (wm *WalletManager) GetTRXAccount(address string) (account *Account, exist
 // 	Owner_address is an activated account，converted to a hex String;
 // 	account_address is the address of the new account, converted to a hex string, this address needs to be calculated in advance
 // Return value：Create account Transaction raw data
+// 	Unsigned JSON is returned, use CreateAccountCreateTransaction to build a transaction for TransactionDecoder
 func (wm *WalletManager) CreateAccount(ownerAddress, accountAddress string) (txRaw *gjson.Result, err error) {
 
 	ownerAddress = convertAddrToHex(ownerAddress)
@@ -190,6 +193,70 @@ func (wm *WalletManager) CreateAccount(ownerAddress, accountAddress string) (txR
 	return r, nil
 }
 
+//CreateAccountCreateTransaction 离线创建激活账户的交易单，由已激活的ownerAddress签名并支付激活费用
+func (wm *WalletManager) CreateAccountCreateTransaction(ownerAddress, accountAddress string, opts *TxOptions) (txRawHex string, err error) {
+
+	_, ownerAddressBytes, err := DecodeAddress(ownerAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return "", err
+	}
+	_, accountAddressBytes, err := DecodeAddress(accountAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return "", err
+	}
+
+	tc := &core.AccountCreateContract{
+		OwnerAddress:   ownerAddressBytes,
+		AccountAddress: accountAddressBytes,
+		Type:           core.AccountType_Normal,
+	}
+	return wm.createAssetsTransaction(tc, core.Transaction_Contract_AccountCreateContract, opts)
+}
+
+//GetChainParameters 查询链参数，如getCreateAccountFee、getCreateNewAccountFeeInSystemContract
+func (wm *WalletManager) GetChainParameters() (map[string]int64, error) {
+
+	r, err := wm.WalletClient.Call("/wallet/getchainparameters", nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewChainParameters(r), nil
+}
+
+//NewChainParameters 解析getchainparameters结果，未设置的参数值为0
+func NewChainParameters(json *gjson.Result) map[string]int64 {
+	params := make(map[string]int64)
+	for _, p := range json.Get("chainParameter").Array() {
+		params[p.Get("key").String()] = p.Get("value").Int()
+	}
+	return params
+}
+
+//GetAccountCreateFeeEstimated 预计激活账户的费用，单位TRX。
+//系统合约费用getCreateNewAccountFeeInSystemContract必须燃烧，
+//发起地址质押的带宽不足时，再燃烧getCreateAccountFee代替带宽，激活交易不使用免费带宽
+func (wm *WalletManager) GetAccountCreateFeeEstimated(from string, data string, params map[string]int64) (*txFeeInfo, error) {
+
+	accountNet, err := wm.GetAccountNet(from)
+	if err != nil {
+		return nil, err
+	}
+
+	fee := chainParameter(params, "getCreateNewAccountFeeInSystemContract", CreateAccountCost-100000)
+	bandwidth := EstimateBandwidth(data)
+	if accountNet.NetLimit-accountNet.NetUsed < bandwidth {
+		fee += chainParameter(params, "getCreateAccountFee", 100000)
+	}
+
+	feeInfo := &txFeeInfo{
+		GasUsed:  1,
+		GasPrice: decimal.New(fee, -wm.Decimal()),
+	}
+	feeInfo.CalcFee()
+	addMemoFee(feeInfo, params, data)
+	return feeInfo, nil
+}
+
 // UpdateAccount Done!
 // Function：Modify account name
 // demo：curl -X POSThttp://127.0.0.1:8090/wallet/updateaccount -d ‘
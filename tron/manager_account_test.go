@@ -17,6 +17,9 @@ package tron
 
 import (
 	"github.com/blocktree/openwallet/v2/log"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+	"github.com/tidwall/gjson"
 	"testing"
 )
 
@@ -95,3 +98,67 @@ func TestGetTRXAccount(t *testing.T) {
 		t.Logf("GetAccount return: \n\t%+v\n", r)
 	}
 }
+
+func TestNewChainParameters(t *testing.T) {
+	json := gjson.Parse(`{"chainParameter":[{"key":"getCreateAccountFee","value":100000},{"key":"getCreateNewAccountFeeInSystemContract","value":1000000},{"key":"getAllowCreationOfContracts"}]}`)
+	params := NewChainParameters(&json)
+	if params["getCreateAccountFee"] != 100000 || params["getCreateNewAccountFeeInSystemContract"] != 1000000 || params["getAllowCreationOfContracts"] != 0 {
+		t.Errorf("unexpected chain parameters: %v", params)
+	}
+}
+
+func TestWalletManager_CreateAccountCreateTransaction(t *testing.T) {
+
+	txRaw, err := tw.CreateAccountCreateTransaction(OWNERADDRESS, TOADDRESS, testStakeTxOptions())
+	if err != nil {
+		t.Errorf("CreateAccountCreateTransaction failed: %v\n", err)
+		return
+	}
+	decoded, err := tw.DecodeRawTransaction(txRaw)
+	if err != nil {
+		t.Errorf("DecodeRawTransaction failed: %v\n", err)
+		return
+	}
+	if c := decoded.Contracts[0]; c.Type != "AccountCreateContract" || c.Owner != OWNERADDRESS || c.Parameter["account_address"] != TOADDRESS {
+		t.Errorf("unexpected contract: %+v", c)
+	}
+
+	rawTx := &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: "TRX"},
+		Account: &openwallet.AssetsAccount{AccountID: "A"},
+		To:      map[string]string{TOADDRESS: "0"},
+		RawHex:  txRaw,
+		Signatures: map[string][]*openwallet.KeySignature{
+			"A": {{Address: &openwallet.Address{Address: OWNERADDRESS}, Message: decoded.TxID}},
+		},
+	}
+	rawTx.SetExtParam(contractTypeExtKey, core.Transaction_Contract_AccountCreateContract.String())
+	if err := NewTransactionDecoder(tw).verifyRawTransactionIntent(rawTx); err != nil {
+		t.Errorf("verifyRawTransactionIntent failed: %v\n", err)
+	}
+
+	//激活交易不能作为转账
+	rawTx.SetExtParam(contractTypeExtKey, "")
+	if err := NewTransactionDecoder(tw).verifyRawTransactionIntent(rawTx); err == nil {
+		t.Errorf("account create should not be accepted as transfer")
+	}
+}
+
+func TestGetAccountCreateFeeEstimated_DefaultParameters(t *testing.T) {
+
+	wm, closeNode := newStubNodeWalletManager(t, 10*TRX)
+	defer closeNode()
+
+	txRaw, err := wm.CreateAccountCreateTransaction(OWNERADDRESS, TOADDRESS, testStakeTxOptions())
+	if err != nil {
+		t.Fatalf("CreateAccountCreateTransaction failed: %v", err)
+	}
+	//链参数未查询到时使用默认值，没有质押带宽时燃烧1 TRX系统合约费用和0.1 TRX带宽费用
+	feeInfo, err := wm.GetAccountCreateFeeEstimated(OWNERADDRESS, txRaw, map[string]int64{})
+	if err != nil {
+		t.Fatalf("GetAccountCreateFeeEstimated failed: %v", err)
+	}
+	if feeInfo.Fee.String() != "1.1" {
+		t.Errorf("unexpected account create fee: %s", feeInfo.Fee.String())
+	}
+}
@@ -70,6 +70,26 @@ func (wm *WalletManager) GetNowBlock() (block *Block, err error) {
 	return block, nil
 }
 
+// GetNowSolidifiedBlock Done!
+// Function：Query the latest solidified block
+// 	demo: curl -X POST http://127.0.0.1:8091/walletsolidity/getnowblock
+// Parameters：None
+// Return value：Latest solidified block on full node
+func (wm *WalletManager) GetNowSolidifiedBlock() (block *Block, err error) {
+
+	r, err := wm.WalletClient.Call("/walletsolidity/getnowblock", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	block = NewBlock(r, wm.Config.IsTestNet)
+	if block.GetBlockHashID() == "" || block.GetHeight() <= 0 {
+		return nil, errors.New("GetNowSolidifiedBlock failed: No found <block>")
+	}
+
+	return block, nil
+}
+
 // GetBlockByNum Done!
 // Function：Query block by height
 // 	demo: curl -X POST http://127.0.0.1:8090/wallet/getblockbynum -d ‘
@@ -0,0 +1,135 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	owcrypt "github.com/blocktree/go-owcrypt"
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+//消息签名格式，与TronWeb一致
+const (
+	//MessageSignV1 TronWeb signMessage，消息为hex，头部固定为"\x19TRON Signed Message:\n32"
+	MessageSignV1 = 1
+	//MessageSignV2 TronWeb signMessageV2（TIP-191），消息为UTF-8文本，头部为消息字节长度
+	MessageSignV2 = 2
+
+	tronMessagePrefix = "\x19TRON Signed Message:\n"
+)
+
+//TronMessageHash 计算消息签名的Keccak-256摘要
+func TronMessageHash(message string, version int) ([]byte, error) {
+
+	var data []byte
+	switch version {
+	case MessageSignV1:
+		msg, err := hex.DecodeString(strings.TrimPrefix(message, "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("v1 message should be hex: %v", err)
+		}
+		data = append([]byte(tronMessagePrefix+"32"), msg...)
+	case MessageSignV2:
+		data = []byte(tronMessagePrefix + strconv.Itoa(len(message)) + message)
+	default:
+		return nil, fmt.Errorf("unsupported message sign version: %d", version)
+	}
+	return owcrypt.Hash(data, 0, owcrypt.HASH_ALG_KECCAK256), nil
+}
+
+//SignMessage 用私钥签名消息，返回0x开头的r+s+v签名，v为27/28
+func (wm *WalletManager) SignMessage(message string, privateKey []byte, version int) (string, error) {
+
+	hash, err := TronMessageHash(message, version)
+	if err != nil {
+		return "", err
+	}
+	signature, v, ret := owcrypt.Signature(privateKey, nil, hash, wm.CurveType())
+	if ret != owcrypt.SUCCESS {
+		return "", fmt.Errorf("message sign failed")
+	}
+	signature = append(signature, v+27)
+	return "0x" + hex.EncodeToString(signature), nil
+}
+
+//SignMessageWithHDKey 用钱包钥匙按派生路径签名消息
+func (wm *WalletManager) SignMessageWithHDKey(key *hdkeystore.HDKey, hdPath string, message string, version int) (string, error) {
+
+	childKey, err := key.DerivedKeyWithPath(hdPath, wm.CurveType())
+	if err != nil {
+		return "", err
+	}
+	priKeyBytes, err := childKey.GetPrivateKeyBytes()
+	if err != nil {
+		return "", err
+	}
+	return wm.SignMessage(message, priKeyBytes, version)
+}
+
+//SignMessageWithWallet 用openwallet钱包中地址对应的派生私钥签名消息
+func (wm *WalletManager) SignMessageWithWallet(wrapper openwallet.WalletDAI, address string, message string, version int, password ...string) (string, error) {
+
+	addr, err := wrapper.GetAddress(address)
+	if err != nil {
+		return "", err
+	}
+	key, err := wrapper.HDKey(password...)
+	if err != nil {
+		return "", err
+	}
+	return wm.SignMessageWithHDKey(key, addr.HDPath, message, version)
+}
+
+//VerifyMessage 验证消息签名，返回签名者的base58地址，调用方与预期地址比较
+func (wm *WalletManager) VerifyMessage(message string, signature string, version int) (string, error) {
+
+	hash, err := TronMessageHash(message, version)
+	if err != nil {
+		return "", err
+	}
+	return wm.recoverSigner(hash, signature)
+}
+
+//recoverSigner 从r+s+v签名恢复签名者的base58地址，v兼容0/1和27/28
+func (wm *WalletManager) recoverSigner(hash []byte, signature string) (string, error) {
+
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil {
+		return "", fmt.Errorf("signature should be hex: %v", err)
+	}
+	if len(sig) != SignatureLength {
+		return "", fmt.Errorf("signature length is %d, expected %d", len(sig), SignatureLength)
+	}
+	sig = append([]byte{}, sig...)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	if sig[64] > 1 {
+		return "", fmt.Errorf("invalid signature recovery id: %d", sig[64])
+	}
+
+	pkBytes, ret := owcrypt.RecoverPubkey(sig, hash, wm.CurveType())
+	if ret != owcrypt.SUCCESS {
+		return "", fmt.Errorf("recover signer public key failed")
+	}
+	pkHash := owcrypt.Hash(pkBytes, 0, owcrypt.HASH_ALG_KECCAK256)[12:32]
+	return EncodeAddress(hex.EncodeToString(pkHash), wm.Config.IsTestNet)
+}
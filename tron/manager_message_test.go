@@ -0,0 +1,116 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	owcrypt "github.com/blocktree/go-owcrypt"
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+)
+
+func TestTronMessageHash(t *testing.T) {
+
+	//v2：头部 + UTF-8字节长度 + 消息
+	hash, err := TronMessageHash("你好", MessageSignV2)
+	if err != nil {
+		t.Fatalf("TronMessageHash failed: %v", err)
+	}
+	expect := owcrypt.Hash([]byte("\x19TRON Signed Message:\n6你好"), 0, owcrypt.HASH_ALG_KECCAK256)
+	if !bytes.Equal(hash, expect) {
+		t.Errorf("v2 hash = %x, expected %x", hash, expect)
+	}
+
+	//v1：头部固定32 + hex解码后的消息
+	hash, err = TronMessageHash("0x0102", MessageSignV1)
+	if err != nil {
+		t.Fatalf("TronMessageHash failed: %v", err)
+	}
+	expect = owcrypt.Hash([]byte("\x19TRON Signed Message:\n32\x01\x02"), 0, owcrypt.HASH_ALG_KECCAK256)
+	if !bytes.Equal(hash, expect) {
+		t.Errorf("v1 hash = %x, expected %x", hash, expect)
+	}
+
+	if _, err := TronMessageHash("hello", MessageSignV1); err == nil {
+		t.Errorf("v1 message should be hex")
+	}
+}
+
+func TestSignMessage(t *testing.T) {
+
+	priKey, _ := hex.DecodeString(PRIVATEKEY)
+	tests := []struct {
+		message string
+		version int
+	}{
+		{"a7e7f3dc44b8e8c4c98dbba98a8e9e8a4fd6b0d1b6da04b0bd7b9d6ff2b0b3c1", MessageSignV1},
+		{"login nonce: 8f2b1c", MessageSignV2},
+	}
+
+	for i, test := range tests {
+		signature, err := tw.SignMessage(test.message, priKey, test.version)
+		if err != nil {
+			t.Errorf("case %d: SignMessage failed: %v", i, err)
+			continue
+		}
+		if len(signature) != 2+SignatureLength*2 || (signature[130:] != "1b" && signature[130:] != "1c") {
+			t.Errorf("case %d: signature = %s", i, signature)
+		}
+		address, err := tw.VerifyMessage(test.message, signature, test.version)
+		if err != nil || address != OWNERADDRESS {
+			t.Errorf("case %d: VerifyMessage = %s, %v", i, address, err)
+		}
+
+		//同一签名按另一种格式验证，得到的不是签名地址
+		other := MessageSignV1 + MessageSignV2 - test.version
+		if address, err := tw.VerifyMessage(test.message, signature, other); err == nil && address == OWNERADDRESS {
+			t.Errorf("case %d: signature is valid for version %d", i, other)
+		}
+	}
+
+	signature, _ := tw.SignMessage("login nonce: 8f2b1c", priKey, MessageSignV2)
+	if address, _ := tw.VerifyMessage("login nonce: 8f2b1d", signature, MessageSignV2); address == OWNERADDRESS {
+		t.Errorf("tampered message is verified")
+	}
+}
+
+func TestSignMessageWithHDKey(t *testing.T) {
+
+	key, err := hdkeystore.NewHDKey(bytes.Repeat([]byte{1}, 32), "message", hdkeystore.OpenwCoinTypePath)
+	if err != nil {
+		t.Fatalf("NewHDKey failed: %v", err)
+	}
+	hdPath := key.RootPath + "/0/1"
+	childKey, err := key.DerivedKeyWithPath(hdPath, tw.CurveType())
+	if err != nil {
+		t.Fatalf("DerivedKeyWithPath failed: %v", err)
+	}
+	expect, err := tw.AddrDecoder.PublicKeyToAddress(childKey.GetPublicKeyBytes(), false)
+	if err != nil {
+		t.Fatalf("PublicKeyToAddress failed: %v", err)
+	}
+
+	signature, err := tw.SignMessageWithHDKey(key, hdPath, "proof of ownership", MessageSignV2)
+	if err != nil {
+		t.Fatalf("SignMessageWithHDKey failed: %v", err)
+	}
+	address, err := tw.VerifyMessage("proof of ownership", signature, MessageSignV2)
+	if err != nil || address != expect {
+		t.Errorf("VerifyMessage = %s, %v, expected %s", address, err, expect)
+	}
+}
@@ -0,0 +1,287 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"fmt"
+
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+//maxFeeDeductRounds 从转账数量扣除手续费时重新构建交易的最大次数
+const maxFeeDeductRounds = 3
+
+//SendOptions SendTransaction的可选参数
+type SendOptions struct {
+	Contract *openwallet.SmartContract //转账的TRC10/TRC20代币，为空时转账TRX
+	DryRun   bool                      //只构建、签名和验证，不广播，返回签名后的交易数据
+	From     string                    //指定发送地址，为空时按地址选择策略选择余额足够的地址
+}
+
+//SendTransaction 发送交易：解锁钱包，选择余额足够的地址，构建、签名、验证并广播，返回交易ID。
+//feesInSender为false时从转账数量中扣除预估手续费和激活费用，代币手续费以TRX支付，不从代币数量扣除
+func (wm *WalletManager) SendTransaction(walletID, to string, amount decimal.Decimal, password string, feesInSender bool, opts ...*SendOptions) ([]string, error) {
+
+	options := &SendOptions{}
+	if len(opts) > 0 && opts[0] != nil {
+		options = opts[0]
+	}
+
+	wrapper, err := wm.newLocalWalletDAI(walletID, password)
+	if err != nil {
+		return nil, err
+	}
+	return wm.sendTransaction(wrapper, to, amount, feesInSender, options)
+}
+
+//sendTransaction 使用已解锁的钱包构建、签名、验证并广播交易
+func (wm *WalletManager) sendTransaction(wrapper *localWalletDAI, to string, amount decimal.Decimal, feesInSender bool, options *SendOptions) ([]string, error) {
+
+	rawTx, err := wm.BuildSendTransaction(wrapper, to, amount, feesInSender, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.DryRun {
+		//不广播，解锁创建时锁定的发送地址
+		defer wm.releaseRawTransaction(rawTx)
+		return signedRawHex(rawTx)
+	}
+
+	if _, err := wm.TxDecoder.SubmitRawTransaction(wrapper, rawTx); err != nil {
+		return nil, err
+	}
+	parts, err := GetRawTxParts(rawTx)
+	if err != nil {
+		return nil, err
+	}
+	txIDs := make([]string, 0, len(parts))
+	for _, part := range parts {
+		txIDs = append(txIDs, part.TxID)
+	}
+	return txIDs, nil
+}
+
+//BuildSendTransaction 构建、签名并验证转账交易单，options可指定转账的代币和发送地址
+func (wm *WalletManager) BuildSendTransaction(wrapper openwallet.WalletDAI, to string, amount decimal.Decimal, feesInSender bool, options *SendOptions) (*openwallet.RawTransaction, error) {
+
+	if !amount.IsPositive() {
+		return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "amount should be positive")
+	}
+	if options == nil {
+		options = &SendOptions{}
+	}
+	contract := options.Contract
+
+	coin := openwallet.Coin{Symbol: wm.Symbol()}
+	if contract != nil {
+		coin.IsContract = true
+		coin.ContractID = contract.ContractID
+		coin.Contract = *contract
+	}
+	account, err := wrapper.GetAssetsAccountInfo(wrapper.GetWallet().WalletID)
+	if err != nil {
+		return nil, err
+	}
+
+	build := func(amount decimal.Decimal) (*openwallet.RawTransaction, error) {
+		rawTx := &openwallet.RawTransaction{
+			Coin:    coin,
+			Account: account,
+			To:      map[string]string{to: amount.String()},
+		}
+		if len(options.From) > 0 {
+			rawTx.SetExtParam(fromExtKey, options.From)
+		}
+		if err := wm.TxDecoder.CreateRawTransaction(wrapper, rawTx); err != nil {
+			return nil, err
+		}
+		return rawTx, nil
+	}
+
+	rawTx, err := build(amount)
+	if err != nil {
+		return nil, err
+	}
+
+	//手续费从转账数量扣除，扣除后交易大小可能变化，重新估算直到手续费不超过已扣除的数量
+	if !feesInSender && contract == nil {
+		_, exist, err := wm.GetTRXAccount(to)
+		if err != nil {
+			wm.releaseRawTransaction(rawTx)
+			return nil, err
+		}
+		deducted := decimal.Zero
+		for i := 0; ; i++ {
+			cost := wm.sendCost(rawTx, exist)
+			if cost.LessThanOrEqual(deducted) {
+				break
+			}
+			if i == maxFeeDeductRounds {
+				wm.releaseRawTransaction(rawTx)
+				return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "fees can not be deducted from amount")
+			}
+			deducted = cost
+			if !amount.Sub(deducted).IsPositive() {
+				wm.releaseRawTransaction(rawTx)
+				return nil, openwallet.Errorf(openwallet.ErrInsufficientFees, "amount: %s is not enough to pay fees: %s", amount.String(), deducted.String())
+			}
+			//重新构建前解锁上一次构建锁定的发送地址
+			wm.releaseRawTransaction(rawTx)
+			if rawTx, err = build(amount.Sub(deducted)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := wm.TxDecoder.SignRawTransaction(wrapper, rawTx); err != nil {
+		wm.releaseRawTransaction(rawTx)
+		return nil, err
+	}
+	if err := wm.TxDecoder.VerifyRawTransaction(wrapper, rawTx); err != nil {
+		wm.releaseRawTransaction(rawTx)
+		return nil, err
+	}
+	return rawTx, nil
+}
+
+//sendCost TRX转账除转账数量外的消耗，手续费加上激活新账户的费用
+func (wm *WalletManager) sendCost(rawTx *openwallet.RawTransaction, toExist bool) decimal.Decimal {
+	cost, _ := decimal.NewFromString(rawTx.Fees)
+	if !toExist {
+		cost = cost.Add(decimal.New(CreateAccountCost, -wm.Decimal()))
+	}
+	return cost
+}
+
+//signedRawHex 合并签名后的交易数据，每笔TRON交易一个
+func signedRawHex(rawTx *openwallet.RawTransaction) ([]string, error) {
+	sig := rawTx.Signatures[rawTx.Account.AccountID]
+	txHexArray := splitRawHex(rawTx.RawHex)
+	if len(sig) != len(txHexArray) {
+		return nil, fmt.Errorf("transaction signature count: %d is not equal to transaction count: %d", len(sig), len(txHexArray))
+	}
+	signed := make([]string, 0, len(txHexArray))
+	for i, txHex := range txHexArray {
+		signedHex, err := InsertSignatureIntoRawTransaction(txHex, sig[i].Signature)
+		if err != nil {
+			return nil, err
+		}
+		signed = append(signed, signedHex)
+	}
+	return signed, nil
+}
+
+//localWalletDAI 本地钱包的WalletDAI，钱包ID即资产账户ID，供SendTransaction复用交易单编码器
+type localWalletDAI struct {
+	openwallet.WalletDAIBase
+	wallet    *openwallet.Wallet
+	key       *hdkeystore.HDKey
+	symbol    string
+	addresses []*openwallet.Address
+}
+
+//newLocalWalletDAI 解锁本地钱包并读取地址
+func (wm *WalletManager) newLocalWalletDAI(walletID, password string) (*localWalletDAI, error) {
+
+	wallet, err := wm.GetWalletInfo(walletID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := wallet.HDKey(password)
+	if err != nil {
+		return nil, fmt.Errorf("unlock wallet failed: %v", err)
+	}
+	addresses, err := wm.GetAddressesFromLocalDB(walletID, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	return &localWalletDAI{
+		wallet:    wallet,
+		key:       key,
+		symbol:    wm.Symbol(),
+		addresses: addresses,
+	}, nil
+}
+
+func (w *localWalletDAI) GetWallet() *openwallet.Wallet {
+	return w.wallet
+}
+
+func (w *localWalletDAI) GetAssetsAccountInfo(accountID string) (*openwallet.AssetsAccount, error) {
+	if accountID != w.wallet.WalletID {
+		return nil, fmt.Errorf("account: %s is not found", accountID)
+	}
+	return &openwallet.AssetsAccount{
+		WalletID:  w.wallet.WalletID,
+		Alias:     w.wallet.Alias,
+		AccountID: w.wallet.WalletID,
+		Symbol:    w.symbol,
+	}, nil
+}
+
+func (w *localWalletDAI) GetAddress(address string) (*openwallet.Address, error) {
+	for _, a := range w.addresses {
+		if a.Address == address {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("address: %s is not found", address)
+}
+
+//GetAddressList 按查询条件过滤地址后分页，cols为字段名和值交替的查询条件，支持AccountID和Address
+func (w *localWalletDAI) GetAddressList(offset, limit int, cols ...interface{}) ([]*openwallet.Address, error) {
+	if len(cols)%2 != 0 {
+		return nil, fmt.Errorf("query columns should be name and value pairs")
+	}
+	addresses := make([]*openwallet.Address, 0, len(w.addresses))
+	for _, a := range w.addresses {
+		matched := true
+		for i := 0; i < len(cols); i += 2 {
+			var field string
+			switch cols[i] {
+			case "AccountID":
+				field = a.AccountID
+			case "Address":
+				field = a.Address
+			default:
+				return nil, fmt.Errorf("query column: %v is not supported", cols[i])
+			}
+			if field != fmt.Sprint(cols[i+1]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			addresses = append(addresses, a)
+		}
+	}
+
+	if offset >= len(addresses) {
+		return []*openwallet.Address{}, nil
+	}
+	end := len(addresses)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	return addresses[offset:end], nil
+}
+
+func (w *localWalletDAI) HDKey(password ...string) (*hdkeystore.HDKey, error) {
+	return w.key, nil
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"testing"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/shopspring/decimal"
+)
+
+func TestLocalWalletDAI(t *testing.T) {
+
+	w := &localWalletDAI{
+		wallet: &openwallet.Wallet{WalletID: "W", Alias: "payout"},
+		symbol: "TRX",
+		addresses: []*openwallet.Address{
+			{Address: OWNERADDRESS, AccountID: "W"},
+			{Address: TOADDRESS, AccountID: "W"},
+		},
+	}
+
+	account, err := w.GetAssetsAccountInfo("W")
+	if err != nil || account.AccountID != "W" || account.WalletID != "W" {
+		t.Errorf("GetAssetsAccountInfo = %+v, %v", account, err)
+	}
+	if _, err := w.GetAssetsAccountInfo("X"); err == nil {
+		t.Errorf("unknown account is found")
+	}
+
+	if list, _ := w.GetAddressList(0, -1, "AccountID", "W"); len(list) != 2 {
+		t.Errorf("GetAddressList all = %d", len(list))
+	}
+	if list, _ := w.GetAddressList(1, 5); len(list) != 1 || list[0].Address != TOADDRESS {
+		t.Errorf("GetAddressList page = %+v", list)
+	}
+	if list, _ := w.GetAddressList(2, 5); len(list) != 0 {
+		t.Errorf("GetAddressList beyond = %+v", list)
+	}
+	if list, _ := w.GetAddressList(0, -1, "AccountID", "W", "Address", TOADDRESS); len(list) != 1 || list[0].Address != TOADDRESS {
+		t.Errorf("GetAddressList by address = %+v", list)
+	}
+	if list, _ := w.GetAddressList(0, -1, "AccountID", "X"); len(list) != 0 {
+		t.Errorf("GetAddressList of other account = %+v", list)
+	}
+	if _, err := w.GetAddressList(0, -1, "Alias"); err == nil {
+		t.Errorf("invalid query columns are accepted")
+	}
+	if _, err := w.GetAddress("TNotExist"); err == nil {
+		t.Errorf("unknown address is found")
+	}
+}
+
+func TestSendCost(t *testing.T) {
+
+	rawTx := &openwallet.RawTransaction{Fees: "0.268"}
+	if cost := tw.sendCost(rawTx, true); !cost.Equal(decimal.RequireFromString("0.268")) {
+		t.Errorf("sendCost = %s", cost.String())
+	}
+	if cost := tw.sendCost(rawTx, false); !cost.Equal(decimal.RequireFromString("0.268").Add(decimal.New(CreateAccountCost, -tw.Decimal()))) {
+		t.Errorf("sendCost to new account = %s", cost.String())
+	}
+}
+
+func TestSendTransaction_WalletNotFound(t *testing.T) {
+	if _, err := tw.SendTransaction("not-exist", TOADDRESS, decimal.New(1, 0), "password", true, &SendOptions{DryRun: true}); err == nil {
+		t.Errorf("SendTransaction with unknown wallet succeeded")
+	}
+}
+
+func TestBuildSendTransaction_From(t *testing.T) {
+
+	server := newStubSigner(t, false, false)
+	defer server.Close()
+	wrapper := &localWalletDAI{
+		wallet: &openwallet.Wallet{WalletID: "W"},
+		symbol: "TRX",
+		addresses: []*openwallet.Address{
+			{Address: TOADDRESS, AccountID: "W"},
+			{Address: OWNERADDRESS, AccountID: "W"},
+		},
+	}
+
+	//指定发送地址时只使用该地址，汇总时每个地址发送自己的余额
+	for _, from := range []string{OWNERADDRESS, TOADDRESS} {
+		wm, closeNode := newStubNodeWalletManager(t, 100*TRX)
+		wm.Signer = NewHTTPSigner(server.URL, "token")
+		rawTx, err := wm.BuildSendTransaction(wrapper, "TEkxiTehnzSmSe2XqrBj4w32RUN966rdz8", decimal.New(10, 0), true, &SendOptions{From: from})
+		closeNode()
+		if from == TOADDRESS {
+			//签名器只持有OWNERADDRESS的私钥
+			if err == nil {
+				t.Errorf("transaction from %s should not be signed", from)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("BuildSendTransaction from %s failed: %v", from, err)
+		}
+		if parts, _ := GetRawTxParts(rawTx); len(parts) != 1 || parts[0].From != from {
+			t.Errorf("transaction should be sent from %s: %+v", from, rawTx.TxFrom)
+		}
+	}
+}
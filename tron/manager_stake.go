@@ -0,0 +1,233 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/common"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+	"github.com/imroc/req"
+)
+
+//MinDelegateBalance Stake 2.0质押和代理资源的最小数量，1 TRX
+const MinDelegateBalance = SUN * 1000000
+
+//CreateFreezeBalanceV2Transaction 创建质押交易单（Stake 2.0），amount为质押的TRX数量，质押获得带宽或能量
+func (wm *WalletManager) CreateFreezeBalanceV2Transaction(ownerAddress string, amount string, resource core.ResourceCode, opts *TxOptions) (txRawHex string, err error) {
+
+	_, ownerAddressBytes, err := DecodeAddress(ownerAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return "", err
+	}
+
+	balance := common.StringNumToBigIntWithExp(amount, wm.Decimal()).Int64()
+	if balance < MinDelegateBalance {
+		return "", fmt.Errorf("freeze balance: %s is less than 1 TRX", amount)
+	}
+
+	tc := &core.FreezeBalanceV2Contract{
+		OwnerAddress:  ownerAddressBytes,
+		FrozenBalance: balance,
+		Resource:      resource,
+	}
+	return wm.createAssetsTransaction(tc, core.Transaction_Contract_FreezeBalanceV2Contract, opts)
+}
+
+//CreateUnfreezeBalanceV2Transaction 创建解除质押交易单（Stake 2.0），解除后需等待锁定期结束才能提取
+func (wm *WalletManager) CreateUnfreezeBalanceV2Transaction(ownerAddress string, amount string, resource core.ResourceCode, opts *TxOptions) (txRawHex string, err error) {
+
+	_, ownerAddressBytes, err := DecodeAddress(ownerAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return "", err
+	}
+
+	balance := common.StringNumToBigIntWithExp(amount, wm.Decimal()).Int64()
+	if balance <= 0 {
+		return "", fmt.Errorf("unfreeze balance: %s should be greater than zero", amount)
+	}
+
+	tc := &core.UnfreezeBalanceV2Contract{
+		OwnerAddress:    ownerAddressBytes,
+		UnfreezeBalance: balance,
+		Resource:        resource,
+	}
+	return wm.createAssetsTransaction(tc, core.Transaction_Contract_UnfreezeBalanceV2Contract, opts)
+}
+
+//CreateWithdrawExpireUnfreezeTransaction 创建提取已到期解除质押TRX的交易单
+func (wm *WalletManager) CreateWithdrawExpireUnfreezeTransaction(ownerAddress string, opts *TxOptions) (txRawHex string, err error) {
+
+	_, ownerAddressBytes, err := DecodeAddress(ownerAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return "", err
+	}
+
+	tc := &core.WithdrawExpireUnfreezeContract{
+		OwnerAddress: ownerAddressBytes,
+	}
+	return wm.createAssetsTransaction(tc, core.Transaction_Contract_WithdrawExpireUnfreezeContract, opts)
+}
+
+//CreateCancelAllUnfreezeV2Transaction 创建取消全部解除质押的交易单，未到期的重新质押，已到期的提取到余额
+func (wm *WalletManager) CreateCancelAllUnfreezeV2Transaction(ownerAddress string, opts *TxOptions) (txRawHex string, err error) {
+
+	_, ownerAddressBytes, err := DecodeAddress(ownerAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return "", err
+	}
+
+	tc := &core.CancelAllUnfreezeV2Contract{
+		OwnerAddress: ownerAddressBytes,
+	}
+	return wm.createAssetsTransaction(tc, core.Transaction_Contract_CancelAllUnfreezeV2Contract, opts)
+}
+
+//CreateDelegateResourceTransaction 创建代理资源交易单（Stake 2.0），amount为代理的质押TRX数量
+func (wm *WalletManager) CreateDelegateResourceTransaction(ownerAddress, receiverAddress string, amount string, resource core.ResourceCode, lock bool, lockPeriod int64, opts *TxOptions) (txRawHex string, err error) {
+
+	_, ownerAddressBytes, err := DecodeAddress(ownerAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return "", err
+	}
+	_, receiverAddressBytes, err := DecodeAddress(receiverAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return "", err
+	}
+
+	balance := common.StringNumToBigIntWithExp(amount, wm.Decimal()).Int64()
+	if balance < MinDelegateBalance {
+		return "", fmt.Errorf("delegate balance: %s is less than 1 TRX", amount)
+	}
+
+	tc := &core.DelegateResourceContract{
+		OwnerAddress:    ownerAddressBytes,
+		Resource:        resource,
+		Balance:         balance,
+		ReceiverAddress: receiverAddressBytes,
+		Lock:            lock,
+		LockPeriod:      lockPeriod,
+	}
+	return wm.createAssetsTransaction(tc, core.Transaction_Contract_DelegateResourceContract, opts)
+}
+
+//CreateUnDelegateResourceTransaction 创建取消代理资源交易单（Stake 2.0），amount为取回的质押TRX数量
+func (wm *WalletManager) CreateUnDelegateResourceTransaction(ownerAddress, receiverAddress string, amount string, resource core.ResourceCode, opts *TxOptions) (txRawHex string, err error) {
+
+	_, ownerAddressBytes, err := DecodeAddress(ownerAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return "", err
+	}
+	_, receiverAddressBytes, err := DecodeAddress(receiverAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return "", err
+	}
+
+	balance := common.StringNumToBigIntWithExp(amount, wm.Decimal()).Int64()
+	if balance <= 0 {
+		return "", fmt.Errorf("undelegate balance: %s should be greater than zero", amount)
+	}
+
+	tc := &core.UnDelegateResourceContract{
+		OwnerAddress:    ownerAddressBytes,
+		Resource:        resource,
+		Balance:         balance,
+		ReceiverAddress: receiverAddressBytes,
+	}
+	return wm.createAssetsTransaction(tc, core.Transaction_Contract_UnDelegateResourceContract, opts)
+}
+
+//GetCanDelegatedMaxSize 查询地址可代理的最大质押数量，单位SUN
+func (wm *WalletManager) GetCanDelegatedMaxSize(address string, resource core.ResourceCode) (int64, error) {
+
+	params := req.Param{
+		"owner_address": convertAddrToHex(address),
+		"type":          int32(resource),
+	}
+	r, err := wm.WalletClient.Call("/wallet/getcandelegatedmaxsize", params)
+	if err != nil {
+		return 0, err
+	}
+	return r.Get("max_size").Int(), nil
+}
+
+//EnergyDelegateBalance 计算获得指定能量需要代理的质押数量，单位SUN，不少于1 TRX。
+//能量按全网质押比例分配：energy = balance / TotalEnergyWeight * TotalEnergyLimit
+func EnergyDelegateBalance(energy int64, res *AccountResource) (int64, error) {
+	if res == nil || res.TotalEnergyLimit <= 0 || res.TotalEnergyWeight <= 0 {
+		return 0, fmt.Errorf("total energy limit or weight is unknown")
+	}
+
+	//TotalEnergyWeight以TRX为单位
+	balance := new(big.Int).Mul(big.NewInt(energy), big.NewInt(res.TotalEnergyWeight))
+	balance.Mul(balance, big.NewInt(MinDelegateBalance))
+	limit := big.NewInt(res.TotalEnergyLimit)
+	//向上取整，避免代理的能量不足
+	balance.Add(balance, new(big.Int).Sub(limit, big.NewInt(1)))
+	balance.Div(balance, limit)
+
+	if balance.Int64() < MinDelegateBalance {
+		return MinDelegateBalance, nil
+	}
+	return balance.Int64(), nil
+}
+
+//GetUnfreezeSchedule 查询地址的解除质押计划和当前可提取的数量，单位SUN
+func (wm *WalletManager) GetUnfreezeSchedule(address string) (schedule []*UnfrozenV2, withdrawable int64, err error) {
+
+	account, exist, err := wm.GetTRXAccount(address)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !exist {
+		return nil, 0, fmt.Errorf("account: %s is not activated", address)
+	}
+
+	withdrawable, err = wm.GetCanWithdrawUnfreezeAmount(address, time.Now().UnixNano()/1000000)
+	if err != nil {
+		return nil, 0, err
+	}
+	return account.UnfrozenV2, withdrawable, nil
+}
+
+//GetCanWithdrawUnfreezeAmount 查询指定时间（毫秒）可提取的解除质押数量，单位SUN
+func (wm *WalletManager) GetCanWithdrawUnfreezeAmount(address string, timestamp int64) (int64, error) {
+
+	params := req.Param{
+		"owner_address": convertAddrToHex(address),
+		"timestamp":     timestamp,
+	}
+	r, err := wm.WalletClient.Call("/wallet/getcanwithdrawunfreezeamount", params)
+	if err != nil {
+		return 0, err
+	}
+	return r.Get("amount").Int(), nil
+}
+
+//GetAvailableUnfreezeCount 查询地址剩余可发起解除质押的次数，同时处于解除中的质押最多32笔
+func (wm *WalletManager) GetAvailableUnfreezeCount(address string) (int64, error) {
+
+	params := req.Param{
+		"owner_address": convertAddrToHex(address),
+	}
+	r, err := wm.WalletClient.Call("/wallet/getavailableunfreezecount", params)
+	if err != nil {
+		return 0, err
+	}
+	return r.Get("count").Int(), nil
+}
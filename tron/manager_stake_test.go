@@ -0,0 +1,396 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+	"github.com/golang/protobuf/proto"
+	"github.com/tidwall/gjson"
+)
+
+func testStakeTxOptions() *TxOptions {
+	return &TxOptions{
+		RefBlock: &Block{
+			Hash:   "000000000035e1c0f60afaa8387fd17fd9b84fe4381265ff084d739f814558ea",
+			Height: 3531200,
+		},
+	}
+}
+
+func TestWalletManager_CreateDelegateResourceTransaction(t *testing.T) {
+
+	txRaw, err := tw.CreateDelegateResourceTransaction(OWNERADDRESS, TOADDRESS, "12.5", core.ResourceCode_ENERGY, false, 0, testStakeTxOptions())
+	if err != nil {
+		t.Errorf("CreateDelegateResourceTransaction failed: %v\n", err)
+		return
+	}
+
+	decoded, err := tw.DecodeRawTransaction(txRaw)
+	if err != nil {
+		t.Errorf("DecodeRawTransaction failed: %v\n", err)
+		return
+	}
+	c := decoded.Contracts[0]
+	if c.Type != "DelegateResourceContract" || c.Owner != OWNERADDRESS || c.To != TOADDRESS || c.Amount != "12.5" || c.Parameter["resource"] != "ENERGY" {
+		t.Errorf("unexpected delegate contract: %+v", c)
+	}
+
+	if _, err := tw.CreateDelegateResourceTransaction(OWNERADDRESS, TOADDRESS, "0.5", core.ResourceCode_ENERGY, false, 0, testStakeTxOptions()); err == nil {
+		t.Errorf("delegate balance less than 1 TRX should be rejected")
+	}
+}
+
+func TestEnergyDelegateBalance(t *testing.T) {
+
+	res := &AccountResource{
+		TotalEnergyLimit:  90000000000,
+		TotalEnergyWeight: 9000000000,
+	}
+
+	//1 TRX = 10 Energy
+	tests := []struct {
+		energy  int64
+		balance int64
+	}{
+		{65000, 6500 * 1000000},
+		{65001, 6500*1000000 + 100000},
+		{5, 1000000},
+	}
+	for i, test := range tests {
+		balance, err := EnergyDelegateBalance(test.energy, res)
+		if err != nil || balance != test.balance {
+			t.Errorf("case %d: got balance: %d, err: %v", i, balance, err)
+		}
+	}
+
+	if _, err := EnergyDelegateBalance(65000, &AccountResource{}); err == nil {
+		t.Errorf("unknown total energy should be rejected")
+	}
+}
+
+func TestVerifyRawTransactionIntent_DelegateResource(t *testing.T) {
+
+	decoder := NewTransactionDecoder(tw)
+
+	newRawTx := func(build func() (string, error), contractType core.Transaction_Contract_ContractType) *openwallet.RawTransaction {
+		txRaw, err := build()
+		if err != nil {
+			t.Fatalf("build transaction failed: %v\n", err)
+		}
+		txHash, _ := getTxHash1(txRaw)
+		rawTx := &openwallet.RawTransaction{
+			Coin:    openwallet.Coin{Symbol: "TRX"},
+			Account: &openwallet.AssetsAccount{AccountID: "A"},
+			To:      map[string]string{TOADDRESS: "12.5"},
+			RawHex:  txRaw,
+			Signatures: map[string][]*openwallet.KeySignature{
+				"A": {{Address: &openwallet.Address{Address: OWNERADDRESS}, Message: hex.EncodeToString(txHash)}},
+			},
+		}
+		rawTx.SetExtParam(contractTypeExtKey, contractType.String())
+		rawTx.SetExtParam(resourceExtKey, core.ResourceCode_ENERGY.String())
+		return rawTx
+	}
+
+	delegate := func() (string, error) {
+		return tw.CreateDelegateResourceTransaction(OWNERADDRESS, TOADDRESS, "12.5", core.ResourceCode_ENERGY, false, 0, testStakeTxOptions())
+	}
+	undelegate := func() (string, error) {
+		return tw.CreateUnDelegateResourceTransaction(OWNERADDRESS, TOADDRESS, "12.5", core.ResourceCode_ENERGY, testStakeTxOptions())
+	}
+
+	if err := decoder.verifyRawTransactionIntent(newRawTx(delegate, core.Transaction_Contract_DelegateResourceContract)); err != nil {
+		t.Errorf("verifyRawTransactionIntent delegate failed: %v\n", err)
+	}
+	if err := decoder.verifyRawTransactionIntent(newRawTx(undelegate, core.Transaction_Contract_UnDelegateResourceContract)); err != nil {
+		t.Errorf("verifyRawTransactionIntent undelegate failed: %v\n", err)
+	}
+
+	//合约类型不一致
+	if err := decoder.verifyRawTransactionIntent(newRawTx(delegate, core.Transaction_Contract_UnDelegateResourceContract)); err == nil {
+		t.Errorf("tampered contract type should be rejected")
+	}
+
+	//未声明合约类型，不能作为转账
+	rawTx := newRawTx(delegate, core.Transaction_Contract_DelegateResourceContract)
+	rawTx.SetExtParam(contractTypeExtKey, "")
+	rawTx.SetExtParam(resourceExtKey, "")
+	if err := decoder.verifyRawTransactionIntent(rawTx); err == nil {
+		t.Errorf("delegate should not be accepted as transfer")
+	}
+
+	//资源类型不一致
+	rawTx = newRawTx(delegate, core.Transaction_Contract_DelegateResourceContract)
+	rawTx.SetExtParam(resourceExtKey, core.ResourceCode_BANDWIDTH.String())
+	if err := decoder.verifyRawTransactionIntent(rawTx); err == nil {
+		t.Errorf("tampered resource should be rejected")
+	}
+
+	//锁定代理
+	locked := func() (string, error) {
+		return tw.CreateDelegateResourceTransaction(OWNERADDRESS, TOADDRESS, "12.5", core.ResourceCode_ENERGY, true, 28800, testStakeTxOptions())
+	}
+	if err := decoder.verifyRawTransactionIntent(newRawTx(locked, core.Transaction_Contract_DelegateResourceContract)); err == nil {
+		t.Errorf("locked delegation should be rejected")
+	}
+}
+
+func TestWalletManager_CreateFreezeBalanceV2Transaction(t *testing.T) {
+
+	decoder := NewTransactionDecoder(tw)
+
+	tests := []struct {
+		contractType core.Transaction_Contract_ContractType
+		amount       string
+		resource     string
+		build        func() (string, error)
+	}{
+		{core.Transaction_Contract_FreezeBalanceV2Contract, "100", "ENERGY", func() (string, error) {
+			return tw.CreateFreezeBalanceV2Transaction(OWNERADDRESS, "100", core.ResourceCode_ENERGY, testStakeTxOptions())
+		}},
+		{core.Transaction_Contract_UnfreezeBalanceV2Contract, "20.5", "BANDWIDTH", func() (string, error) {
+			return tw.CreateUnfreezeBalanceV2Transaction(OWNERADDRESS, "20.5", core.ResourceCode_BANDWIDTH, testStakeTxOptions())
+		}},
+		{core.Transaction_Contract_WithdrawExpireUnfreezeContract, "0", "", func() (string, error) {
+			return tw.CreateWithdrawExpireUnfreezeTransaction(OWNERADDRESS, testStakeTxOptions())
+		}},
+		{core.Transaction_Contract_CancelAllUnfreezeV2Contract, "0", "", func() (string, error) {
+			return tw.CreateCancelAllUnfreezeV2Transaction(OWNERADDRESS, testStakeTxOptions())
+		}},
+	}
+
+	for i, test := range tests {
+		txRaw, err := test.build()
+		if err != nil {
+			t.Errorf("case %d: build failed: %v\n", i, err)
+			continue
+		}
+
+		decoded, err := tw.DecodeRawTransaction(txRaw)
+		if err != nil {
+			t.Errorf("case %d: DecodeRawTransaction failed: %v\n", i, err)
+			continue
+		}
+		if c := decoded.Contracts[0]; c.Type != test.contractType.String() || c.Owner != OWNERADDRESS {
+			t.Errorf("case %d: unexpected contract: %+v", i, c)
+		}
+
+		rawTx := &openwallet.RawTransaction{
+			Coin:    openwallet.Coin{Symbol: "TRX"},
+			Account: &openwallet.AssetsAccount{AccountID: "A"},
+			To:      map[string]string{OWNERADDRESS: test.amount},
+			RawHex:  txRaw,
+			Signatures: map[string][]*openwallet.KeySignature{
+				"A": {{Address: &openwallet.Address{Address: OWNERADDRESS}, Message: decoded.TxID}},
+			},
+		}
+		rawTx.SetExtParam(contractTypeExtKey, test.contractType.String())
+		rawTx.SetExtParam(resourceExtKey, test.resource)
+		if err := decoder.verifyRawTransactionIntent(rawTx); err != nil {
+			t.Errorf("case %d: verifyRawTransactionIntent failed: %v\n", i, err)
+		}
+	}
+}
+
+func TestNewAccount_UnfrozenV2(t *testing.T) {
+
+	json := gjson.Parse(`{
+		"address": "41efb6d8a02f4b639605d71ff8dc78c97329759d70",
+		"balance": 1000000,
+		"frozenV2": [{"amount": 5000000}, {"type": "ENERGY", "amount": 100000000}, {"type": "TRON_POWER"}],
+		"unfrozenV2": [
+			{"unfreeze_amount": 2000000, "unfreeze_expire_time": 1000},
+			{"type": "ENERGY", "unfreeze_amount": 3000000, "unfreeze_expire_time": 3000}
+		]
+	}`)
+	account := NewAccount(&json)
+
+	if account.FrozenV2["BANDWIDTH"] != 5000000 || account.FrozenV2["ENERGY"] != 100000000 {
+		t.Errorf("unexpected frozenV2: %v", account.FrozenV2)
+	}
+	if len(account.UnfrozenV2) != 2 || account.UnfrozenV2[0].Resource != "BANDWIDTH" || account.UnfrozenV2[1].Resource != "ENERGY" {
+		t.Errorf("unexpected unfrozenV2: %+v", account.UnfrozenV2)
+		return
+	}
+	if amount := account.WithdrawableAmount(2000); amount != 2000000 {
+		t.Errorf("unexpected withdrawable amount: %d", amount)
+	}
+	if amount := account.WithdrawableAmount(3000); amount != 5000000 {
+		t.Errorf("unexpected withdrawable amount: %d", amount)
+	}
+}
+
+//newStubNodeWalletManager 连接模拟节点的钱包管理器，所有地址已激活，余额为balance（SUN），免费带宽足够
+func newStubNodeWalletManager(t *testing.T, balance int64) (*WalletManager, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var param map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&param)
+		switch r.URL.Path {
+		case "/wallet/getaccount":
+			json.NewEncoder(w).Encode(map[string]interface{}{"address": param["address"], "balance": balance})
+		case "/wallet/getaccountnet":
+			json.NewEncoder(w).Encode(map[string]interface{}{"freeNetLimit": 1500})
+		default:
+			t.Errorf("unexpected node api: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	wm := NewWalletManager()
+	wm.Config.IsTestNet = false
+	wm.WalletClient = NewClient(server.URL, "", false)
+	wm.RefBlockCache = NewRefBlockCache(time.Hour)
+	wm.RefBlockCache.Get(RefBlockHead, func() (*Block, error) {
+		return testStakeTxOptions().RefBlock, nil
+	})
+	return wm, server.Close
+}
+
+//createSignVerifyRawTransaction 通过CreateRawTransaction创建交易单，用PRIVATEKEY签名后验证
+func createSignVerifyRawTransaction(wm *WalletManager, rawTx *openwallet.RawTransaction) error {
+	decoder := NewTransactionDecoder(wm)
+	wrapper := &localWalletDAI{
+		wallet:    &openwallet.Wallet{WalletID: "A"},
+		symbol:    "TRX",
+		addresses: []*openwallet.Address{{Address: OWNERADDRESS, AccountID: "A"}},
+	}
+	if err := decoder.CreateRawTransaction(wrapper, rawTx); err != nil {
+		return err
+	}
+	for _, sig := range rawTx.Signatures["A"] {
+		signature, err := wm.SignTransactionRef(sig.Message, PRIVATEKEY)
+		if err != nil {
+			return err
+		}
+		sig.Signature = signature
+	}
+	return decoder.VerifyRawTransaction(wrapper, rawTx)
+}
+
+func TestCreateRawTransaction_Stake(t *testing.T) {
+
+	tests := []struct {
+		contractType core.Transaction_Contract_ContractType
+		amount       string
+		resource     string
+	}{
+		{core.Transaction_Contract_FreezeBalanceV2Contract, "100", "ENERGY"},
+		{core.Transaction_Contract_UnfreezeBalanceV2Contract, "20.5", "BANDWIDTH"},
+		{core.Transaction_Contract_WithdrawExpireUnfreezeContract, "0", ""},
+		{core.Transaction_Contract_CancelAllUnfreezeV2Contract, "0", ""},
+	}
+
+	for i, test := range tests {
+		wm, closeNode := newStubNodeWalletManager(t, 200*TRX)
+		rawTx := &openwallet.RawTransaction{
+			Coin:    openwallet.Coin{Symbol: "TRX"},
+			Account: &openwallet.AssetsAccount{AccountID: "A"},
+			To:      map[string]string{OWNERADDRESS: test.amount},
+		}
+		rawTx.SetExtParam(contractTypeExtKey, test.contractType.String())
+		rawTx.SetExtParam(resourceExtKey, test.resource)
+		if err := createSignVerifyRawTransaction(wm, rawTx); err != nil {
+			t.Errorf("case %d: create and verify %s failed: %v", i, test.contractType.String(), err)
+		} else if rawTx.TxAmount != "0.000000" || !wm.AddressLocker.IsLocked(OWNERADDRESS) {
+			t.Errorf("case %d: unexpected raw transaction: %+v", i, rawTx)
+		}
+		closeNode()
+	}
+
+	wm, closeNode := newStubNodeWalletManager(t, 50*TRX)
+	defer closeNode()
+	invalid := []map[string]string{
+		//余额不足以质押
+		{contractTypeExtKey: core.Transaction_Contract_FreezeBalanceV2Contract.String(), resourceExtKey: "ENERGY"},
+		//缺少资源类型
+		{contractTypeExtKey: core.Transaction_Contract_UnfreezeBalanceV2Contract.String()},
+		//未支持的合约类型
+		{contractTypeExtKey: core.Transaction_Contract_ExchangeCreateContract.String()},
+	}
+	for i, ext := range invalid {
+		rawTx := &openwallet.RawTransaction{
+			Coin:    openwallet.Coin{Symbol: "TRX"},
+			Account: &openwallet.AssetsAccount{AccountID: "A"},
+			To:      map[string]string{OWNERADDRESS: "100"},
+		}
+		for key, value := range ext {
+			rawTx.SetExtParam(key, value)
+		}
+		if err := createSignVerifyRawTransaction(wm, rawTx); err == nil {
+			t.Errorf("invalid case %d should be rejected", i)
+		}
+	}
+}
+
+func TestCreateUndelegateRawTransaction(t *testing.T) {
+
+	wm, closeNode := newStubNodeWalletManager(t, 200*TRX)
+	defer closeNode()
+	decoder := NewTransactionDecoder(wm)
+	wrapper := &localWalletDAI{
+		wallet:    &openwallet.Wallet{WalletID: "A"},
+		symbol:    "TRX",
+		addresses: []*openwallet.Address{{Address: OWNERADDRESS, AccountID: "A"}},
+	}
+	account := &openwallet.AssetsAccount{AccountID: "A"}
+
+	delegateHex, err := wm.CreateDelegateResourceTransaction(OWNERADDRESS, TOADDRESS, "100", core.ResourceCode_ENERGY, false, 0, testStakeTxOptions())
+	if err != nil {
+		t.Fatalf("CreateDelegateResourceTransaction failed: %v", err)
+	}
+	delegateRawTx, err := decoder.createResourceRawTransaction(wrapper, account, OWNERADDRESS, TOADDRESS, "100",
+		core.ResourceCode_ENERGY, core.Transaction_Contract_DelegateResourceContract, delegateHex)
+	if err != nil {
+		t.Fatalf("create delegate raw transaction failed: %v", err)
+	}
+
+	//汇总确认后创建取回交易单，使用新的有效期
+	undelegateRawTx, err := decoder.CreateUndelegateRawTransaction(wrapper, delegateRawTx)
+	if err != nil {
+		t.Fatalf("CreateUndelegateRawTransaction failed: %v", err)
+	}
+	ext := undelegateRawTx.GetExtParam()
+	if ext.Get(contractTypeExtKey).String() != core.Transaction_Contract_UnDelegateResourceContract.String() ||
+		ext.Get(summaryStepExtKey).String() != SummaryStepUndelegate || ext.Get(resourceExtKey).String() != "ENERGY" ||
+		undelegateRawTx.To[TOADDRESS] != "100" {
+		t.Errorf("undelegate raw transaction = %+v", undelegateRawTx)
+	}
+	if err := decoder.verifyRawTransactionIntent(undelegateRawTx); err != nil {
+		t.Errorf("verifyRawTransactionIntent undelegate failed: %v", err)
+	}
+	expiration := func(txHex string) int64 {
+		txBytes, _ := hex.DecodeString(txHex)
+		tx := &core.Transaction{}
+		proto.Unmarshal(txBytes, tx)
+		return tx.GetRawData().GetExpiration()
+	}
+	if expiration(undelegateRawTx.RawHex) <= expiration(delegateHex) {
+		t.Errorf("undelegate should expire later than delegate")
+	}
+
+	//只接受汇总中代理步骤的交易单
+	if _, err := decoder.CreateUndelegateRawTransaction(wrapper, undelegateRawTx); err == nil {
+		t.Errorf("undelegate raw transaction should be rejected")
+	}
+}
@@ -21,17 +21,21 @@ import (
 	"github.com/blocktree/openwallet/v2/common"
 	"github.com/blocktree/openwallet/v2/openwallet"
 	"math/big"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/blocktree/go-owcdrivers/addressEncoder"
 	"github.com/blocktree/go-owcrypt"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/api"
 	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
 	"github.com/golang/protobuf/proto"
 	"github.com/golang/protobuf/ptypes/any"
 	"github.com/imroc/req"
 	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
 )
 
 type AddrBalance struct {
@@ -162,19 +166,71 @@ func (wm *WalletManager) CreateTransactionRef(toAddress, ownerAddress string, am
 	return txRawHex, nil
 }
 
+//TxOptions 构建交易单的可选参数，零值使用配置
+type TxOptions struct {
+	//Expiration 交易有效期，不能超过MaxTxExpiration
+	Expiration time.Duration
+	//RefBlockType 参考区块类型：RefBlockHead，RefBlockSolidified
+	RefBlockType string
+	//RefBlock 调用方指定的参考区块，设置后忽略RefBlockType
+	RefBlock *Block
+	//Memo 交易备注，UTF-8字符串，写入raw_data.data
+	Memo string
+	//DryRun 合约调用先模拟执行，预计失败则不创建交易单
+	DryRun bool
+	//FeeLimit 合约调用最多燃烧的TRX，单位sun，0使用配置
+	FeeLimit int64
+}
+
+//NewTxOptions 从交易单扩展参数读取构建参数
+//	{
+//		"expiration": 60,         //交易有效期，单位秒
+//		"refBlock": "solidified", //参考区块类型
+//		"memo": "deposit id",     //交易备注
+//		"dryRun": true,           //模拟执行合约调用
+//		"feeLimit": 100000000     //合约调用最多燃烧的sun
+//	}
+func NewTxOptions(ext gjson.Result) *TxOptions {
+	opts := &TxOptions{}
+	if expiration := ext.Get("expiration").Int(); expiration > 0 {
+		opts.Expiration = time.Duration(expiration) * time.Second
+	}
+	opts.RefBlockType = ext.Get("refBlock").String()
+	opts.Memo = ext.Get("memo").String()
+	opts.DryRun = ext.Get("dryRun").Bool()
+	opts.FeeLimit = ext.Get("feeLimit").Int()
+	return opts
+}
+
 func (wm *WalletManager) CreateTokenTransaction(toAddress, ownerAddress string, amount string, contract openwallet.SmartContract) (txRawHex string, err error) {
+	return wm.CreateTokenTransactionWithOptions(toAddress, ownerAddress, amount, contract, nil)
+}
+
+//CreateTokenTransactionWithOptions 根据构建参数创建主币或代币交易单
+func (wm *WalletManager) CreateTokenTransactionWithOptions(toAddress, ownerAddress string, amount string, contract openwallet.SmartContract, opts *TxOptions) (txRawHex string, err error) {
+
+	message, contractType, err := wm.makeTransferContract(toAddress, ownerAddress, amount, contract)
+	if err != nil {
+		return "", err
+	}
+
+	return wm.createAssetsTransaction(message, contractType, opts)
+}
+
+//makeTransferContract 创建主币或代币转账合约
+func (wm *WalletManager) makeTransferContract(toAddress, ownerAddress string, amount string, contract openwallet.SmartContract) (proto.Message, core.Transaction_Contract_ContractType, error) {
 
 	// addressEncoder.AddressDecode return 20 bytes of the center of Address
-	toAddrHex, toAddressBytes, err := DecodeAddress(toAddress, wm.Config.IsTestNet)
+	_, toAddressBytes, err := DecodeAddress(toAddress, wm.Config.IsTestNet)
 	if err != nil {
 		wm.Log.Info("toAddress decode failed failed;unexpected error:%v", err)
-		return "", err
+		return nil, 0, err
 	}
 
 	_, ownerAddressBytes, err := DecodeAddress(ownerAddress, wm.Config.IsTestNet)
 	if err != nil {
 		wm.Log.Info("ownerAddress decode failed failed;unexpected error:%v", err)
-		return "", err
+		return nil, 0, err
 	}
 
 	if contract.Address == "" {
@@ -185,7 +241,7 @@ func (wm *WalletManager) CreateTokenTransaction(toAddress, ownerAddress string,
 			ToAddress:    toAddressBytes,
 			Amount:       amountDec.Int64(),
 		}
-		return wm.createAssetsTransaction(tc, core.Transaction_Contract_TransferContract, TransferContract)
+		return tc, core.Transaction_Contract_TransferContract, nil
 
 	} else if strings.EqualFold(contract.Protocol, TRC10) {
 		amountDec := common.StringNumToBigIntWithExp(amount, int32(contract.Decimals))
@@ -196,105 +252,171 @@ func (wm *WalletManager) CreateTokenTransaction(toAddress, ownerAddress string,
 			AssetName:    []byte(contract.Address),
 		}
 
-		return wm.createAssetsTransaction(tc, core.Transaction_Contract_TransferAssetContract, TransferAssetContract)
+		return tc, core.Transaction_Contract_TransferAssetContract, nil
 	} else if strings.EqualFold(contract.Protocol, TRC20) {
 		amountDec := common.StringNumToBigIntWithExp(amount, int32(contract.Decimals))
-		_, contractAddressBytes, err := DecodeAddress(contract.Address, wm.Config.IsTestNet)
+		tc, err := wm.makeTRC20CallContract(ownerAddress, contract, TRC20_TRANSFER_METHOD_ID, toAddress, amountDec)
 		if err != nil {
-			return "", err
+			return nil, 0, err
 		}
+		return tc, core.Transaction_Contract_TriggerSmartContract, nil
+	}
 
-		var funcParams []SolidityParam
-		funcParams = append(funcParams, SolidityParam{
-			ParamType:  SOLIDITY_TYPE_ADDRESS,
-			ParamValue: toAddrHex,
-		})
+	return nil, 0, fmt.Errorf("%s is not supported", contract.Protocol)
+}
 
-		funcParams = append(funcParams, SolidityParam{
-			ParamType:  SOLIDITY_TYPE_UINT256,
-			ParamValue: amountDec,
-		})
+//makeTRC20CallContract 创建TRC20合约调用，参数为base58地址或*big.Int数量
+func (wm *WalletManager) makeTRC20CallContract(ownerAddress string, contract openwallet.SmartContract, methodID string, args ...interface{}) (*core.TriggerSmartContract, error) {
 
-		//fmt.Println("make token transfer data, amount:", amount.String())
-		dataHex, err := makeTransactionParameter(TRC20_TRANSFER_METHOD_ID, funcParams)
-		if err != nil {
-			return "", err
-		}
+	_, ownerAddressBytes, err := DecodeAddress(ownerAddress, wm.Config.IsTestNet)
+	if err != nil {
+		wm.Log.Info("ownerAddress decode failed failed;unexpected error:%v", err)
+		return nil, err
+	}
 
-		data, err := hex.DecodeString(dataHex)
-		if err != nil {
-			return "", err
-		}
+	_, contractAddressBytes, err := DecodeAddress(contract.Address, wm.Config.IsTestNet)
+	if err != nil {
+		return nil, err
+	}
 
-		tc := &core.TriggerSmartContract{
-			OwnerAddress:    ownerAddressBytes,
-			ContractAddress: contractAddressBytes,
-			Data:            data,
+	var funcParams []SolidityParam
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			addrHex, _, err := DecodeAddress(v, wm.Config.IsTestNet)
+			if err != nil {
+				return nil, err
+			}
+			funcParams = append(funcParams, SolidityParam{
+				ParamType:  SOLIDITY_TYPE_ADDRESS,
+				ParamValue: addrHex,
+			})
+		case *big.Int:
+			funcParams = append(funcParams, SolidityParam{
+				ParamType:  SOLIDITY_TYPE_UINT256,
+				ParamValue: v,
+			})
+		default:
+			return nil, fmt.Errorf("unsupported TRC20 call argument: %v", arg)
 		}
-		return wm.createAssetsTransaction(tc, core.Transaction_Contract_TriggerSmartContract, TriggerSmartContract)
 	}
 
-	return "", fmt.Errorf("%s is not supported", contract.Protocol)
+	dataHex, err := makeTransactionParameter(methodID, funcParams)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := hex.DecodeString(dataHex)
+	if err != nil {
+		return nil, err
+	}
+
+	return &core.TriggerSmartContract{
+		OwnerAddress:    ownerAddressBytes,
+		ContractAddress: contractAddressBytes,
+		Data:            data,
+	}, nil
+}
+
+//trc20Allowance 授权数量，TRC20UnlimitedAllowance为uint256最大值
+func trc20Allowance(amount string, decimals int32) *big.Int {
+	if amount == TRC20UnlimitedAllowance {
+		return new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	}
+	return common.StringNumToBigIntWithExp(amount, decimals)
 }
 
-func (wm *WalletManager) createAssetsTransaction(message proto.Message, contractType core.Transaction_Contract_ContractType, typeName string) (string, error) {
+//CreateTRC20ApproveTransaction 创建approve交易单，授权spender从owner转出代币
+func (wm *WalletManager) CreateTRC20ApproveTransaction(ownerAddress, spenderAddress string, amount string, contract openwallet.SmartContract, opts *TxOptions) (txRawHex string, err error) {
+	return wm.createTRC20CallTransaction(ownerAddress, contract, TRC20_APPROVE_METHOD_ID, opts,
+		spenderAddress, trc20Allowance(amount, int32(contract.Decimals)))
+}
+
+//CreateTRC20IncreaseAllowanceTransaction 创建increaseAllowance交易单
+func (wm *WalletManager) CreateTRC20IncreaseAllowanceTransaction(ownerAddress, spenderAddress string, amount string, contract openwallet.SmartContract, opts *TxOptions) (txRawHex string, err error) {
+	return wm.createTRC20CallTransaction(ownerAddress, contract, TRC20_INCREASE_ALLOWANCE_METHOD_ID, opts,
+		spenderAddress, common.StringNumToBigIntWithExp(amount, int32(contract.Decimals)))
+}
 
-	msgBytes, err := proto.Marshal(message)
+//CreateTRC20DecreaseAllowanceTransaction 创建decreaseAllowance交易单
+func (wm *WalletManager) CreateTRC20DecreaseAllowanceTransaction(ownerAddress, spenderAddress string, amount string, contract openwallet.SmartContract, opts *TxOptions) (txRawHex string, err error) {
+	return wm.createTRC20CallTransaction(ownerAddress, contract, TRC20_DECREASE_ALLOWANCE_METHOD_ID, opts,
+		spenderAddress, common.StringNumToBigIntWithExp(amount, int32(contract.Decimals)))
+}
+
+//CreateTRC20TransferFromTransaction 创建transferFrom交易单，由spender签名，从fromAddress转出已授权的代币
+func (wm *WalletManager) CreateTRC20TransferFromTransaction(spenderAddress, fromAddress, toAddress string, amount string, contract openwallet.SmartContract, opts *TxOptions) (txRawHex string, err error) {
+	return wm.createTRC20CallTransaction(spenderAddress, contract, TRC20_TRANSFER_FROM_METHOD_ID, opts,
+		fromAddress, toAddress, common.StringNumToBigIntWithExp(amount, int32(contract.Decimals)))
+}
+
+func (wm *WalletManager) createTRC20CallTransaction(ownerAddress string, contract openwallet.SmartContract, methodID string, opts *TxOptions, args ...interface{}) (string, error) {
+	tc, err := wm.makeTRC20CallContract(ownerAddress, contract, methodID, args...)
 	if err != nil {
 		return "", err
 	}
+	return wm.createAssetsTransaction(tc, core.Transaction_Contract_TriggerSmartContract, opts)
+}
 
-	txContact := &core.Transaction_Contract{
-		Type:         contractType,
-		Parameter:    &any.Any{Value: msgBytes, TypeUrl: "type.googleapis.com/protocol." + typeName},
-		Provider:     nil,
-		ContractName: nil,
+func (wm *WalletManager) createAssetsTransaction(message proto.Message, contractType core.Transaction_Contract_ContractType, opts *TxOptions) (string, error) {
+
+	if opts == nil {
+		opts = &TxOptions{}
 	}
 
 	// ******** Get Reference Block ********
-	block, err := wm.GetNowBlock()
+	block, err := wm.getRefBlock(opts)
 	if err != nil {
-		wm.Log.Info("get current block failed;unexpected error:%v", err)
+		wm.Log.Info("get reference block failed;unexpected error:%v", err)
 		return "", err
 	}
-	blockID, err := hex.DecodeString(block.GetBlockHashID())
+	refBlock, err := NewRefBlock(block)
 	if err != nil {
 		return "", err
 	}
-	refBlockBytes, refBlockHash := blockID[6:8], blockID[8:16]
-
-	// ********Set timestamp ********
-	/*
-	 According to RFC-3339 date strings
-	 Timestamp timestamp = Timestamp.newBuilder().setSeconds(millis / 1000).setNanos((int) ((millis % 1000) * 1000000)).build();
-	*/
-	timestamp := time.Now().UnixNano() / 1000000 // <int64
 
-	// ******** Create Traction ********
-	txRaw := &core.TransactionRaw{
-		RefBlockBytes: refBlockBytes,
-		RefBlockHash:  refBlockHash,
-		Contract:      []*core.Transaction_Contract{txContact},
-		Expiration:    timestamp + 10*60*60*60,
-		//FeeLimit:      FeeLimit,
+	txRawHex, _, err := wm.BuildContractTransaction(refBlock, message, contractType, opts)
+	if err != nil {
+		return "", err
 	}
+	return txRawHex, nil
+
+}
 
-	if wm.Config.FeeLimit > 0 {
-		txRaw.FeeLimit = wm.Config.FeeLimit
+//txExpiration 交易有效期，优先使用构建参数，不超过协议最大值
+func (wm *WalletManager) txExpiration(opts *TxOptions) time.Duration {
+	expiration := wm.Config.TxExpiration
+	if opts != nil && opts.Expiration > 0 {
+		expiration = opts.Expiration
+	}
+	if expiration <= 0 {
+		expiration = DefaultTxExpiration
+	}
+	if expiration > MaxTxExpiration {
+		expiration = MaxTxExpiration
 	}
+	return expiration
+}
 
-	tx := &core.Transaction{
-		RawData: txRaw,
+//getRefBlock 获取交易参考区块
+func (wm *WalletManager) getRefBlock(opts *TxOptions) (*Block, error) {
+	if opts.RefBlock != nil {
+		return opts.RefBlock, nil
 	}
 
-	x, err := proto.Marshal(tx)
-	if err != nil {
-		wm.Log.Info("marshal tx failed;unexpected error:%v", err)
-		return "", err
+	refBlockType := wm.Config.RefBlock
+	if len(opts.RefBlockType) > 0 {
+		refBlockType = opts.RefBlockType
 	}
-	txRawHex := hex.EncodeToString(x)
-	return txRawHex, nil
 
+	switch refBlockType {
+	case RefBlockSolidified:
+		return wm.RefBlockCache.Get(RefBlockSolidified, wm.GetNowSolidifiedBlock)
+	case RefBlockHead, "":
+		return wm.RefBlockCache.Get(RefBlockHead, wm.GetNowBlock)
+	default:
+		return nil, fmt.Errorf("reference block type: %s is not supported", refBlockType)
+	}
 }
 
 func (wm *WalletManager) SignTransactionRef(hash string, privateKey string) (signedTxRaw string, err error) {
@@ -389,6 +511,21 @@ func (wm *WalletManager) validSignedTokenTransaction(txHash []byte, signature []
 			return err
 		}
 		ownerAddressHex = hex.EncodeToString(tc.GetOwnerAddress())
+	default:
+		//质押、投票等其他合约通过protobuf注册的类型读取owner_address
+		msgType := proto.MessageType(strings.TrimPrefix(contract.Parameter.GetTypeUrl(), "type.googleapis.com/"))
+		if msgType == nil {
+			return fmt.Errorf("unsupported contract type: %s", contract.Type.String())
+		}
+		msg := reflect.New(msgType.Elem()).Interface().(proto.Message)
+		if err := proto.Unmarshal(contract.Parameter.GetValue(), msg); err != nil {
+			return err
+		}
+		tc, ok := msg.(interface{ GetOwnerAddress() []byte })
+		if !ok {
+			return fmt.Errorf("contract type: %s has no owner address", contract.Type.String())
+		}
+		ownerAddressHex = hex.EncodeToString(tc.GetOwnerAddress())
 	}
 
 	pkBytes, ret := owcrypt.RecoverPubkey(signature, txHash, wm.CurveType())
@@ -462,6 +599,71 @@ func (wm *WalletManager) ValidSignedTransactionRef(txHex string) error {
 	return nil
 }
 
+//BroadcastError 节点拒绝广播交易时返回的错误，Code为节点返回的错误码
+type BroadcastError struct {
+	Code        api.ReturnResponseCode
+	Message     string
+	TxID        string
+	Resubmitted bool //本进程之前已广播过该交易
+}
+
+func (e *BroadcastError) Error() string {
+	return fmt.Sprintf("BroadcastTransaction error code: %s, message: %s", e.Code.String(), e.Message)
+}
+
+//IsDuplicate 节点中已有相同交易ID的交易
+func (e *BroadcastError) IsDuplicate() bool {
+	return e.Code == api.Return_DUP_TRANSACTION_ERROR
+}
+
+//IsResubmitted 本进程重复广播的交易已在节点中，可视为广播成功。
+//未广播过的交易返回重复，说明已有其他相同交易ID的交易，本次广播不能视为成功
+func (e *BroadcastError) IsResubmitted() bool {
+	return e.IsDuplicate() && e.Resubmitted
+}
+
+//IsExpired 交易已过期或参考区块无效，需要重新创建交易单
+func (e *BroadcastError) IsExpired() bool {
+	return e.Code == api.Return_TAPOS_ERROR || e.Code == api.Return_TRANSACTION_EXPIRATION_ERROR
+}
+
+//IsRetryable 节点繁忙等临时错误，可稍后重新广播
+func (e *BroadcastError) IsRetryable() bool {
+	return e.Code == api.Return_SERVER_BUSY
+}
+
+//OWError 转换为openwallet错误
+func (e *BroadcastError) OWError() *openwallet.Error {
+	switch e.Code {
+	case api.Return_SIGERROR:
+		return openwallet.Errorf(openwallet.ErrVerifyRawTransactionFailed, "%s", e.Error())
+	case api.Return_BANDWITH_ERROR:
+		return openwallet.Errorf(openwallet.ErrInsufficientFees, "%s", e.Error())
+	default:
+		return openwallet.Errorf(openwallet.ErrSubmitRawTransactionFailed, "%s", e.Error())
+	}
+}
+
+//NewBroadcastError 解析节点广播结果，广播成功返回nil
+func NewBroadcastError(r *gjson.Result, txID string) *BroadcastError {
+	if r.Get("result").Bool() {
+		return nil
+	}
+	code := api.Return_OTHER_ERROR
+	if v, ok := api.ReturnResponseCode_value[r.Get("code").String()]; ok {
+		code = api.ReturnResponseCode(v)
+	}
+	msg := r.Get("message").String()
+	if b, err := hex.DecodeString(msg); err == nil {
+		msg = string(b)
+	}
+	if msg == "" {
+		msg = r.Raw
+	}
+	return &BroadcastError{Code: code, Message: msg, TxID: txID}
+}
+
+//BroadcastTransaction 广播已签名的交易单，原样提交签名后的protobuf字节
 func (wm *WalletManager) BroadcastTransaction(raw string) (string, error) {
 	tx := &core.Transaction{}
 	if txBytes, err := hex.DecodeString(raw); err != nil {
@@ -474,131 +676,57 @@ func (wm *WalletManager) BroadcastTransaction(raw string) (string, error) {
 		}
 	}
 
-	/* Generate Params */
-	var (
-		signature []string
-		txID      string
-		contracts []map[string]interface{}
-		raw_data  map[string]interface{}
-	)
-	for _, x := range tx.GetSignature() {
-		signature = append(signature, hex.EncodeToString(x)) // base64
-	}
-	if txHash, err := getTxHash1(raw); err != nil {
+	txHash, err := getTxHash(tx)
+	if err != nil {
 		wm.Log.Info("get raw hash failed;unexpected error:%v", err)
 		return "", err
-	} else {
-		txID = hex.EncodeToString(txHash)
-	}
-	rawData := tx.GetRawData()
-	contracts = []map[string]interface{}{}
-	for _, c := range rawData.GetContract() {
-		any := c.GetParameter().GetValue()
-		var contract map[string]interface{}
-		switch c.Type {
-		case core.Transaction_Contract_TransferContract:
-			tc := &core.TransferContract{}
-			if err := proto.Unmarshal(any, tc); err != nil {
-				wm.Log.Info("unmarshal contract value failed;unexpected error:%v", err)
-				return "", err
-			}
-
-			contract = map[string]interface{}{
-				"type": c.GetType().String(),
-				"parameter": map[string]interface{}{
-					"type_url": c.GetParameter().GetTypeUrl(),
-					"value": map[string]interface{}{
-						"amount":        tc.Amount,
-						"owner_address": hex.EncodeToString(tc.GetOwnerAddress()),
-						"to_address":    hex.EncodeToString(tc.GetToAddress()),
-					},
-				},
-			}
-
-		case core.Transaction_Contract_TransferAssetContract:
-			tc := &core.TransferAssetContract{}
-			if err := proto.Unmarshal(any, tc); err != nil {
-				wm.Log.Info("unmarshal contract value failed;unexpected error:%v", err)
-				return "", err
-			}
-
-			contract = map[string]interface{}{
-				"type": c.GetType().String(),
-				"parameter": map[string]interface{}{
-					"type_url": c.GetParameter().GetTypeUrl(),
-					"value": map[string]interface{}{
-						"amount":        tc.Amount,
-						"owner_address": hex.EncodeToString(tc.GetOwnerAddress()),
-						"to_address":    hex.EncodeToString(tc.GetToAddress()),
-						"asset_name":    hex.EncodeToString(tc.AssetName),
-					},
-				},
-			}
-
-		case core.Transaction_Contract_TriggerSmartContract:
-			tc := &core.TriggerSmartContract{}
-			if err := proto.Unmarshal(any, tc); err != nil {
-				wm.Log.Info("unmarshal contract value failed;unexpected error:%v", err)
-				return "", err
-			}
-
-			contract = map[string]interface{}{
-				"type": c.GetType().String(),
-				"parameter": map[string]interface{}{
-					"type_url": c.GetParameter().GetTypeUrl(),
-					"value": map[string]interface{}{
-						"data":             hex.EncodeToString(tc.Data),
-						"owner_address":    hex.EncodeToString(tc.GetOwnerAddress()),
-						"contract_address": hex.EncodeToString(tc.GetContractAddress()),
-					},
-				},
-			}
-		}
-
-		contracts = append(contracts, contract)
-	}
-	raw_data = map[string]interface{}{
-		"ref_block_bytes": hex.EncodeToString(rawData.GetRefBlockBytes()),
-		"ref_block_hash":  hex.EncodeToString(rawData.GetRefBlockHash()),
-		"expiration":      rawData.GetExpiration(),
-		"timestamp":       rawData.GetTimestamp(),
-		"contract":        contracts,
 	}
+	txID := hex.EncodeToString(txHash)
 
-	if wm.Config.FeeLimit > 0 {
-		raw_data["fee_limit"] = wm.Config.FeeLimit
-	}
+	//广播前记录，请求超时后重新广播返回重复时仍可确认是本方的交易
+	resubmitted := wm.broadcastLog.add(txID, tx.GetRawData().GetExpiration())
 
 	params := req.Param{
-		"signature": signature,
-		"txID":      txID,
-		"raw_data":  raw_data,
+		"transaction": raw,
 	}
 	// Call api to broadcast transaction
-	r, err := wm.WalletClient.Call("/wallet/broadcasttransaction", params)
-
+	r, err := wm.WalletClient.Call("/wallet/broadcasthex", params)
 	if err != nil {
 		wm.Log.Info("broadcast transaction failed;unexpected error:%v", err)
 		return "", err
-	} else {
-		if r.Get("result").Bool() != true {
-			var err error
-			if r.Get("message").String() != "" {
-				msg, _ := hex.DecodeString(r.Get("message").String())
-				err = fmt.Errorf("BroadcastTransaction error message: %+v", string(msg))
-			} else {
-				err = fmt.Errorf("BroadcastTransaction return error: %+v", r)
-			}
-			return "", err
-		}
+	}
+
+	if broadcastErr := NewBroadcastError(r, txID); broadcastErr != nil {
+		broadcastErr.Resubmitted = resubmitted
+		return "", broadcastErr
 	}
 	return txID, nil
 }
 
-//SendTransaction 发送交易
-func (wm *WalletManager) SendTransaction(walletID, to string, amount decimal.Decimal, password string, feesInSender bool) ([]string, error) {
+//broadcastLog 本进程广播过的交易ID和有效期，过期后清除
+type broadcastLog struct {
+	mu  sync.Mutex
+	txs map[string]int64
+}
+
+func newBroadcastLog() *broadcastLog {
+	return &broadcastLog{txs: make(map[string]int64)}
+}
 
-	return nil, nil
+//add 记录广播的交易，返回该交易之前是否已广播过
+func (l *broadcastLog) add(txID string, expiration int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now().UnixNano() / 1000000
+	for id, exp := range l.txs {
+		if exp <= now {
+			delete(l.txs, id)
+		}
+	}
+	_, exist := l.txs[txID]
+	l.txs[txID] = expiration
+	return exist
 }
 
 //deprecated
@@ -690,24 +818,140 @@ func (wm *WalletManager) GetTransactionFeeEstimated(from string, data string) (*
 	}
 
 	//:检查地址账户可用带宽是否足够
-	/*
-		accountNet, err := wm.GetAccountNet(from)
-		if err != nil {
-			return nil, err
+	accountNet, err := wm.GetAccountNet(from)
+	if err != nil {
+		return nil, err
+	}
+
+	//带宽消耗 = 交易数据长度（包括备注） + 签名长度 + 交易结果长度
+	bandwidth := EstimateBandwidth(data)
+
+	//带宽不足或带备注时，按链参数计算燃烧的TRX
+	burnBandwidth := accountNet.NetLimit-accountNet.NetUsed < bandwidth && accountNet.FreeNetLimit-accountNet.FreeNetUsed < bandwidth
+	if !burnBandwidth && !hasMemo(data) {
+		return feeInfo, nil
+	}
+	params, err := wm.GetChainParameters()
+	if err != nil {
+		return nil, err
+	}
+
+	//先判断冻结的带宽是否可用，再判断免费的带宽是否可用
+	if burnBandwidth {
+		//:矿工费 = 字节长度 * 带宽单价
+		feeInfo.GasPrice = decimal.New(chainParameter(params, "getTransactionFee", BandwidthPrice), -Decimals)
+		feeInfo.GasUsed = bandwidth
+		feeInfo.CalcFee()
+	}
+
+	//带备注的交易额外燃烧备注费用
+	addMemoFee(feeInfo, params, data)
+
+	return feeInfo, nil
+}
+
+//hasMemo 交易的raw_data.data不为空
+func hasMemo(txHex string) bool {
+	txBytes, err := hex.DecodeString(txHex)
+	if err != nil {
+		return false
+	}
+	tx := &core.Transaction{}
+	if err := proto.Unmarshal(txBytes, tx); err != nil {
+		return false
+	}
+	return len(tx.GetRawData().GetData()) > 0
+}
+
+//addMemoFee 带备注的交易按链参数getMemoFee额外燃烧TRX，加到已计算的手续费上
+func addMemoFee(feeInfo *txFeeInfo, params map[string]int64, txHex string) {
+	if hasMemo(txHex) {
+		feeInfo.Fee = feeInfo.Fee.Add(decimal.New(chainParameter(params, "getMemoFee", 0), -Decimals))
+	}
+}
+
+//EstimateTransferBurn 计算TRX转账燃烧的手续费，单位SUN。
+//接收地址已激活时，质押和免费带宽都不足则按字节数燃烧getTransactionFee；
+//接收地址未激活时不消耗普通带宽，燃烧getCreateNewAccountFeeInSystemContract，质押带宽不足再燃烧getCreateAccountFee
+func EstimateTransferBurn(accountNet *AccountNet, params map[string]int64, txHex string, toExist bool) *txFeeInfo {
+
+	bandwidth := EstimateBandwidth(txHex)
+	feeInfo := &txFeeInfo{
+		GasUsed:  bandwidth,
+		GasPrice: decimal.Zero,
+		Fee:      decimal.Zero,
+	}
+
+	if !toExist {
+		burn := chainParameter(params, "getCreateNewAccountFeeInSystemContract", CreateAccountCost-100000)
+		if accountNet.NetLimit-accountNet.NetUsed < bandwidth {
+			burn += chainParameter(params, "getCreateAccountFee", 100000)
 		}
-		log.Debugf("length of data: %d", len(data)/2)
-		//先判断冻结的带宽是否可用
-		if accountNet.NetUsed >= accountNet.NetLimit {
-			//再判断免费的带宽是否可用
-			if accountNet.FreeNetUsed >= accountNet.FreeNetLimit {
-				//:矿工费 = 字节长度 * 10 SUN
-				feeInfo.Fee = decimal.New(GasPrice, 0).Shift(-Decimals)
-				feeInfo.GasUsed = int64(len(data) / 2)
-				feeInfo.CalcFee()
-			}
+		feeInfo.Fee = decimal.New(burn, -Decimals)
+		addMemoFee(feeInfo, params, txHex)
+		return feeInfo
+	}
+
+	if accountNet.NetLimit-accountNet.NetUsed < bandwidth && accountNet.FreeNetLimit-accountNet.FreeNetUsed < bandwidth {
+		feeInfo.GasPrice = decimal.New(chainParameter(params, "getTransactionFee", BandwidthPrice), -Decimals)
+		feeInfo.CalcFee()
+	}
+	addMemoFee(feeInfo, params, txHex)
+	return feeInfo
+}
+
+//EstimateTRC10Burn 计算TRC10转账燃烧的手续费，单位SUN。接收地址已激活时带宽按顺序使用：
+//资产免费带宽（账户额度、公共额度和发行者质押的带宽都足够，发送者不是发行者），发送者质押的带宽，免费带宽，
+//都不足时按字节数燃烧。资产公共带宽的已用量不计算恢复，结果偏保守
+func EstimateTRC10Burn(owner string, accountNet *AccountNet, asset *AssetIssue, issuerNet *AccountNet, params map[string]int64, txHex string, toExist bool) *txFeeInfo {
+
+	if !toExist {
+		return EstimateTransferBurn(accountNet, params, txHex, toExist)
+	}
+
+	bandwidth := EstimateBandwidth(txHex)
+	if asset != nil && issuerNet != nil && owner != asset.OwnerAddress {
+		if asset.FreeAssetNetLimit-accountNet.AssetNetUsed[asset.ID] >= bandwidth &&
+			asset.PublicFreeAssetNetLimit-asset.PublicFreeAssetNetUsage >= bandwidth &&
+			issuerNet.NetLimit-issuerNet.NetUsed >= bandwidth {
+			feeInfo := &txFeeInfo{GasUsed: bandwidth, GasPrice: decimal.Zero, Fee: decimal.Zero}
+			addMemoFee(feeInfo, params, txHex)
+			return feeInfo
 		}
-	*/
-	return feeInfo, nil
+	}
+
+	return EstimateTransferBurn(accountNet, params, txHex, toExist)
+}
+
+//GetTRC10TransactionFeeEstimated 预计TRC10转账燃烧的手续费
+func (wm *WalletManager) GetTRC10TransactionFeeEstimated(from string, tokenID string, txHex string, toExist bool, params map[string]int64) (*txFeeInfo, error) {
+
+	accountNet, err := wm.GetAccountNet(from)
+	if err != nil {
+		return nil, err
+	}
+	asset, err := wm.GetAssetIssueByID(tokenID)
+	if err != nil {
+		return nil, err
+	}
+	issuerNet, err := wm.GetAccountNet(asset.OwnerAddress)
+	if err != nil {
+		return nil, err
+	}
+	return EstimateTRC10Burn(from, accountNet, asset, issuerNet, params, txHex, toExist), nil
+}
+
+//chainParameter 读取链参数，未查询到时使用默认值
+func chainParameter(params map[string]int64, key string, defaultValue int64) int64 {
+	if v, ok := params[key]; ok && v > 0 {
+		return v
+	}
+	return defaultValue
+}
+
+//EstimateBandwidth 预估交易消耗的带宽字节数
+func EstimateBandwidth(txHex string) int64 {
+	return int64(len(txHex)/2) + SignatureBandwidth + ResultBandwidth
 }
 
 //IsEnoughEnergyToTransferTRC20 是否足够能量转账TRC20
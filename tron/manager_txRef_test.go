@@ -18,7 +18,18 @@ package tron
 import (
 	"encoding/hex"
 	"fmt"
+	"github.com/astaxie/beego/config"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/api"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+	"github.com/golang/protobuf/proto"
+	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 var (
@@ -175,3 +186,235 @@ func TestSuiteTx(t *testing.T) {
 	//}
 
 }
+
+func TestCreateTokenTransactionWithOptions(t *testing.T) {
+
+	refBlock := &Block{
+		Hash:   "000000000035e1c0f60afaa8387fd17fd9b84fe4381265ff084d739f814558ea",
+		Height: 3531200,
+	}
+
+	txRaw, err := tw.CreateTokenTransactionWithOptions(TOADDRESS, OWNERADDRESS, AMOUNT, openwallet.SmartContract{}, &TxOptions{
+		Expiration: time.Minute,
+		RefBlock:   refBlock,
+	})
+	if err != nil {
+		t.Errorf("CreateTokenTransactionWithOptions failed: %v\n", err)
+		return
+	}
+
+	tx := &core.Transaction{}
+	txBytes, _ := hex.DecodeString(txRaw)
+	if err := proto.Unmarshal(txBytes, tx); err != nil {
+		t.Errorf("unmarshal tx failed: %v\n", err)
+		return
+	}
+
+	rawData := tx.GetRawData()
+	if hex.EncodeToString(rawData.RefBlockBytes) != "e1c0" || hex.EncodeToString(rawData.RefBlockHash) != "f60afaa8387fd17f" {
+		t.Errorf("unexpected reference block: %x %x", rawData.RefBlockBytes, rawData.RefBlockHash)
+	}
+	if rawData.Timestamp == 0 || rawData.Expiration-rawData.Timestamp != 60*1000 {
+		t.Errorf("unexpected expiration: %d, timestamp: %d", rawData.Expiration, rawData.Timestamp)
+	}
+
+	if expiration := tw.txExpiration(&TxOptions{Expiration: 48 * time.Hour}); expiration != MaxTxExpiration {
+		t.Errorf("expiration should not exceed %v, got %v", MaxTxExpiration, expiration)
+	}
+}
+
+func TestLoadTxConfig(t *testing.T) {
+
+	wc := &WalletConfig{}
+	c, _ := config.NewConfigData("ini", []byte("txExpiration = 5m\nrefBlock = solidified\nrefBlockCacheTime = 0s"))
+	if err := wc.loadTxConfig(c); err != nil {
+		t.Fatalf("loadTxConfig failed: %v", err)
+	}
+	if wc.TxExpiration != 5*time.Minute || wc.RefBlock != RefBlockSolidified || wc.RefBlockCacheTime != 0 {
+		t.Errorf("unexpected tx config: %v, %s, %v", wc.TxExpiration, wc.RefBlock, wc.RefBlockCacheTime)
+	}
+
+	//配置错误不能静默使用默认值
+	for _, data := range []string{"txExpiration = 10", "txExpiration = -1m", "refBlock = solidifed", "refBlockCacheTime = 3"} {
+		c, _ := config.NewConfigData("ini", []byte(data))
+		if err := wc.loadTxConfig(c); err == nil {
+			t.Errorf("invalid config: %s is accepted", data)
+		}
+	}
+}
+
+func TestNewBroadcastError(t *testing.T) {
+
+	r := gjson.Parse(`{"result":true,"txid":"77ddfa7093cc5f745c0d3a54abb89ef070f983343c05e0f89e5a52f3e5401299"}`)
+	if err := NewBroadcastError(&r, ""); err != nil {
+		t.Errorf("successful broadcast should not return error: %v", err)
+	}
+
+	//message: "Validate signature error"
+	r = gjson.Parse(`{"result":false,"code":"SIGERROR","txid":"77dd","message":"56616c6964617465207369676e6174757265206572726f72"}`)
+	err := NewBroadcastError(&r, "77dd")
+	if err == nil || err.Code != api.Return_SIGERROR || err.Message != "Validate signature error" {
+		t.Errorf("unexpected broadcast error: %v", err)
+		return
+	}
+	if err.OWError().Code() != openwallet.ErrVerifyRawTransactionFailed {
+		t.Errorf("SIGERROR should map to ErrVerifyRawTransactionFailed")
+	}
+
+	//节点消息中的%不能被当作格式化参数
+	r = gjson.Parse(`{"result":false,"code":"BANDWITH_ERROR","txid":"77dd","message":"` + hex.EncodeToString([]byte("used 100% bandwidth")) + `"}`)
+	if owErr := NewBroadcastError(&r, "77dd").OWError(); !strings.Contains(owErr.Error(), "used 100% bandwidth") {
+		t.Errorf("unexpected openwallet error: %v", owErr)
+	}
+
+	r = gjson.Parse(`{"result":false,"code":"DUP_TRANSACTION_ERROR","message":"647570207472616e73616374696f6e"}`)
+	if err = NewBroadcastError(&r, "77dd"); err == nil || !err.IsDuplicate() {
+		t.Errorf("DUP_TRANSACTION_ERROR should be duplicate")
+	}
+
+	r = gjson.Parse(`{"result":false,"code":"TRANSACTION_EXPIRATION_ERROR"}`)
+	if err = NewBroadcastError(&r, "77dd"); err == nil || !err.IsExpired() {
+		t.Errorf("TRANSACTION_EXPIRATION_ERROR should be expired")
+	}
+
+	r = gjson.Parse(`{"result":false,"code":"UNKNOWN_CODE"}`)
+	if err = NewBroadcastError(&r, "77dd"); err == nil || err.Code != api.Return_OTHER_ERROR {
+		t.Errorf("unknown code should map to OTHER_ERROR")
+	}
+}
+
+func TestBroadcastTransaction_Duplicate(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result":false,"code":"DUP_TRANSACTION_ERROR","message":"647570207472616e73616374696f6e"}`))
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.IsTestNet = false
+	wm.WalletClient = NewClient(server.URL, "", false)
+
+	txRaw, err := wm.CreateWithdrawBalanceTransaction(OWNERADDRESS, testStakeTxOptions())
+	if err != nil {
+		t.Fatalf("CreateWithdrawBalanceTransaction failed: %v", err)
+	}
+
+	//未广播过的交易返回重复，不能视为广播成功
+	_, err = wm.BroadcastTransaction(txRaw)
+	if broadcastErr, ok := err.(*BroadcastError); !ok || !broadcastErr.IsDuplicate() || broadcastErr.IsResubmitted() {
+		t.Errorf("first broadcast should not be resubmitted: %v", err)
+	}
+	//重新广播返回重复，交易已在节点中
+	_, err = wm.BroadcastTransaction(txRaw)
+	if broadcastErr, ok := err.(*BroadcastError); !ok || !broadcastErr.IsResubmitted() {
+		t.Errorf("second broadcast should be resubmitted: %v", err)
+	}
+}
+
+func TestGetTransactionFeeEstimated_ChainParameter(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/wallet/getaccountnet":
+			w.Write([]byte(`{}`))
+		case "/wallet/getchainparameters":
+			w.Write([]byte(`{"chainParameter":[{"key":"getTransactionFee","value":210}]}`))
+		default:
+			t.Errorf("unexpected node api: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	wm := NewWalletManager()
+	wm.Config.IsTestNet = false
+	wm.WalletClient = NewClient(server.URL, "", false)
+
+	txRaw, err := wm.CreateWithdrawBalanceTransaction(OWNERADDRESS, testStakeTxOptions())
+	if err != nil {
+		t.Fatalf("CreateWithdrawBalanceTransaction failed: %v", err)
+	}
+	feeInfo, err := wm.GetTransactionFeeEstimated(OWNERADDRESS, txRaw)
+	if err != nil {
+		t.Fatalf("GetTransactionFeeEstimated failed: %v", err)
+	}
+	//没有可用带宽，按链参数getTransactionFee燃烧TRX
+	bandwidth := EstimateBandwidth(txRaw)
+	if feeInfo.GasUsed != bandwidth || !feeInfo.Fee.Equal(decimal.New(bandwidth*210, -Decimals)) {
+		t.Errorf("unexpected fee info: %+v", feeInfo)
+	}
+}
+
+func TestEstimateTRC10Burn(t *testing.T) {
+
+	netJSON := gjson.Parse(`{"freeNetUsed":600,"freeNetLimit":600,"assetNetUsed":[{"key":"1002000","value":100}],"assetNetLimit":[{"key":"1002000","value":5000}]}`)
+	accountNet := NewAccountNet(&netJSON)
+	if accountNet.AssetNetUsed["1002000"] != 100 || accountNet.AssetNetLimit["1002000"] != 5000 {
+		t.Errorf("unexpected asset net: %v, %v", accountNet.AssetNetUsed, accountNet.AssetNetLimit)
+		return
+	}
+
+	assetJSON := gjson.Parse(`{"id":"1002000","owner_address":"41efb6d8a02f4b639605d71ff8dc78c97329759d70","free_asset_net_limit":1000,"public_free_asset_net_limit":100000,"public_free_asset_net_usage":0}`)
+	asset, err := NewAssetIssue(&assetJSON, false)
+	if err != nil {
+		t.Errorf("NewAssetIssue failed: %v\n", err)
+		return
+	}
+	params := map[string]int64{"getTransactionFee": 1000}
+	bandwidth := EstimateBandwidth(pTxRaw)
+
+	//资产免费带宽足够
+	issuerNet := &AccountNet{NetLimit: 10000}
+	if fee := EstimateTRC10Burn(OWNERADDRESS, accountNet, asset, issuerNet, params, pTxRaw, true); !fee.Fee.IsZero() {
+		t.Errorf("asset free bandwidth should be used, fee: %s", fee.Fee)
+	}
+
+	//发行者质押的带宽不足，按字节燃烧
+	fee := EstimateTRC10Burn(OWNERADDRESS, accountNet, asset, &AccountNet{}, params, pTxRaw, true)
+	if fee.Fee.Shift(Decimals).IntPart() != bandwidth*1000 {
+		t.Errorf("unexpected fee: %s", fee.Fee)
+	}
+
+	//公共免费带宽已用完
+	asset.PublicFreeAssetNetUsage = asset.PublicFreeAssetNetLimit
+	if fee := EstimateTRC10Burn(OWNERADDRESS, accountNet, asset, issuerNet, params, pTxRaw, true); fee.Fee.IsZero() {
+		t.Errorf("public free asset bandwidth is used up, fee should not be zero")
+	}
+
+	//发送者质押的带宽足够
+	accountNet.NetLimit = 1000
+	if fee := EstimateTRC10Burn(OWNERADDRESS, accountNet, asset, issuerNet, params, pTxRaw, true); !fee.Fee.IsZero() {
+		t.Errorf("account bandwidth should be used, fee: %s", fee.Fee)
+	}
+}
+
+func TestEstimateTransferBurn_Memo(t *testing.T) {
+
+	refBlock, _ := NewRefBlockFromHex("e1c0", "f60afaa8387fd17f")
+	txRaw, _, err := tw.BuildTokenTransaction(refBlock, TOADDRESS, OWNERADDRESS, "1", openwallet.SmartContract{}, nil)
+	if err != nil {
+		t.Fatalf("BuildTokenTransaction failed: %v", err)
+	}
+	memoTxRaw, _, err := tw.BuildTokenTransaction(refBlock, TOADDRESS, OWNERADDRESS, "1", openwallet.SmartContract{}, &TxOptions{Memo: "deposit"})
+	if err != nil {
+		t.Fatalf("BuildTokenTransaction failed: %v", err)
+	}
+	if hasMemo(txRaw) || !hasMemo(memoTxRaw) {
+		t.Errorf("hasMemo = %v, %v", hasMemo(txRaw), hasMemo(memoTxRaw))
+	}
+
+	//带宽足够时只燃烧备注费用
+	params := map[string]int64{"getTransactionFee": 1000, "getMemoFee": 1000000}
+	accountNet := &AccountNet{NetLimit: 1000}
+	if fee := EstimateTransferBurn(accountNet, params, txRaw, true); !fee.Fee.IsZero() {
+		t.Errorf("fee without memo = %s", fee.Fee)
+	}
+	if fee := EstimateTransferBurn(accountNet, params, memoTxRaw, true); fee.Fee.Shift(Decimals).IntPart() != 1000000 {
+		t.Errorf("fee with memo = %s", fee.Fee)
+	}
+
+	//带宽不足时备注费用加上带宽燃烧
+	fee := EstimateTransferBurn(&AccountNet{}, params, memoTxRaw, true)
+	if fee.Fee.Shift(Decimals).IntPart() != EstimateBandwidth(memoTxRaw)*1000+1000000 {
+		t.Errorf("fee with memo and bandwidth = %s", fee.Fee)
+	}
+}
@@ -59,7 +59,7 @@ func (wm *WalletManager) GetWalletBalance(accountID string) string {
 // 	return nil
 // }
 
-//SummaryWallets 执行汇总流程
+//SummaryWallets 执行汇总流程，钱包总余额超过阀值时，每个地址分别把自己的余额发送到汇总地址
 func (wm *WalletManager) SummaryWallets() {
 
 	wm.Log.Info("[Summary Wallet Start]------%s", common.TimeFormat("2006-01-02 15:04:05"))
@@ -67,22 +67,45 @@ func (wm *WalletManager) SummaryWallets() {
 	//读取参与汇总的钱包
 	for wid, wallet := range wm.WalletsInSum {
 
+		wrapper, err := wm.newLocalWalletDAI(wid, wallet.Password)
+		if err != nil {
+			wm.Log.Info("Summary account[%s]unexpected error: %v", wallet.WalletID, err)
+			continue
+		}
+
 		//统计钱包最新余额
-		wb := wm.GetWalletBalance(wid)
+		balance := decimal.Zero
+		addrBalances := make(map[string]decimal.Decimal)
+		for _, address := range wrapper.addresses {
+			if address.Address == wm.Config.SumAddress {
+				continue
+			}
+			b, err := wm.getBalance(address.Address)
+			if err != nil {
+				wm.Log.Info("Summary account[%s]get balance of address[%s] unexpected error: %v", wallet.WalletID, address.Address, err)
+				continue
+			}
+			addrBalance, _ := decimal.NewFromString(b.Balance)
+			if addrBalance.IsPositive() {
+				addrBalances[address.Address] = addrBalance
+				balance = balance.Add(addrBalance)
+			}
+		}
 
-		balance, _ := decimal.NewFromString(wb)
 		//如果余额大于阀值，汇总的地址
 		if balance.GreaterThan(wm.Config.Threshold) {
 
 			wm.Log.Info("Summary account[%s]balance = %v ", wallet.WalletID, balance)
 			wm.Log.Info("Summary account[%s]Start Send Transaction", wallet.WalletID)
 
-			txID, err := wm.SendTransaction(wallet.WalletID, wm.Config.SumAddress, balance, wallet.Password, false)
-			if err != nil {
-				wm.Log.Info("Summary account[%s]unexpected error: %v", wallet.WalletID, err)
-				continue
-			} else {
-				wm.Log.Info("Summary account[%s]successfully，Received Address[%s], TXID：%s", wallet.WalletID, wm.Config.SumAddress, txID)
+			//一笔交易只能由一个地址发送，每个地址汇总自己的余额，手续费从汇总数量扣除
+			for address, addrBalance := range addrBalances {
+				txID, err := wm.sendTransaction(wrapper, wm.Config.SumAddress, addrBalance, false, &SendOptions{From: address})
+				if err != nil {
+					wm.Log.Info("Summary account[%s]address[%s]unexpected error: %v", wallet.WalletID, address, err)
+					continue
+				}
+				wm.Log.Info("Summary account[%s]address[%s]successfully，Received Address[%s], TXID：%s", wallet.WalletID, address, wm.Config.SumAddress, txID)
 			}
 		} else {
 			wm.Log.Info("Wallet Account[%s]-[%s]Current Balance: %v，below threshold: %v", wallet.Alias, wallet.WalletID, balance, wm.Config.Threshold)
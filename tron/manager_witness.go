@@ -15,49 +15,125 @@
 
 package tron
 
-// ListWitnesses Writing!
+import (
+	"fmt"
+	"sort"
+
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+	"github.com/imroc/req"
+)
+
+// ListWitnesses Done!
 // Function：
 // 	Query the list of Super Representatives
 // demo:
 // 	curl -X POSThttp://127.0.0.1:8090/wallet/listwitnesses
-// Parameters：None
-// Return value：List of all Super Representatives
-func (wm *WalletManager) ListWitnesses() (witnesses string, err error) {
+// Parameters：
+// 	brokerage: query the brokerage of each witness, one request per witness
+// Return value：List of all Super Representatives, sorted by vote count
+func (wm *WalletManager) ListWitnesses(brokerage bool) (witnesses []*Witness, err error) {
 
 	r, err := wm.WalletClient.Call("/wallet/listwitnesses", nil)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	_ = r
 
-	// // type WitnessList struct {
-	// // 	Witnesses            []*core.Witness `protobuf:"bytes,1,rep,name=witnesses,proto3" json:"witnesses,omitempty"`
-	// // 	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
-	// // 	XXX_unrecognized     []byte          `json:"-"`
-	// // 	XXX_sizecache        int32           `json:"-"`
-	// // }
-	// // type Witness struct {
-	// // 	Address              []byte   `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
-	// // 	VoteCount            int64    `protobuf:"varint,2,opt,name=voteCount,proto3" json:"voteCount,omitempty"`
-	// // 	PubKey               []byte   `protobuf:"bytes,3,opt,name=pubKey,proto3" json:"pubKey,omitempty"`
-	// // 	Url                  string   `protobuf:"bytes,4,opt,name=url,proto3" json:"url,omitempty"`
-	// // 	TotalProduced        int64    `protobuf:"varint,5,opt,name=totalProduced,proto3" json:"totalProduced,omitempty"`
-	// // 	TotalMissed          int64    `protobuf:"varint,6,opt,name=totalMissed,proto3" json:"totalMissed,omitempty"`
-	// // 	LatestBlockNum       int64    `protobuf:"varint,7,opt,name=latestBlockNum,proto3" json:"latestBlockNum,omitempty"`
-	// // 	LatestSlotNum        int64    `protobuf:"varint,8,opt,name=latestSlotNum,proto3" json:"latestSlotNum,omitempty"`
-	// // 	IsJobs               bool     `protobuf:"varint,9,opt,name=isJobs,proto3" json:"isJobs,omitempty"`
-	// // 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	// // 	XXX_unrecognized     []byte   `json:"-"`
-	// // 	XXX_sizecache        int32    `json:"-"`
-	// // }
-	// witnesses = &api.WitnessList{}
-	// if err := gjson.Unmarshal([]byte(r.Raw), witnesses); err != nil {
-	// 	return nil, err
-	// }
+	witnesses = make([]*Witness, 0)
+	for _, w := range r.Get("witnesses").Array() {
+		witness, err := NewWitness(&w, wm.Config.IsTestNet)
+		if err != nil {
+			return nil, err
+		}
+		if brokerage {
+			if witness.Brokerage, err = wm.GetBrokerage(witness.Address); err != nil {
+				return nil, err
+			}
+		}
+		witnesses = append(witnesses, witness)
+	}
+
+	sort.SliceStable(witnesses, func(i, j int) bool {
+		return witnesses[i].VoteCount > witnesses[j].VoteCount
+	})
 
 	return witnesses, nil
 }
 
+//GetBrokerage 查询超级代表的分成比例，单位%，投票者获得 100 - brokerage 的奖励
+func (wm *WalletManager) GetBrokerage(address string) (int64, error) {
+
+	params := req.Param{"address": convertAddrToHex(address)}
+	r, err := wm.WalletClient.Call("/wallet/getBrokerage", params)
+	if err != nil {
+		return 0, err
+	}
+	return r.Get("brokerage").Int(), nil
+}
+
+//GetReward 查询地址未领取的投票奖励，单位SUN
+func (wm *WalletManager) GetReward(address string) (int64, error) {
+
+	params := req.Param{"address": convertAddrToHex(address)}
+	r, err := wm.WalletClient.Call("/wallet/getReward", params)
+	if err != nil {
+		return 0, err
+	}
+	return r.Get("reward").Int(), nil
+}
+
+//CreateVoteWitnessTransaction 创建投票交易单，votes为超级代表地址和票数，1票需要1 TRX的质押。
+//新的投票会覆盖地址之前的全部投票
+func (wm *WalletManager) CreateVoteWitnessTransaction(ownerAddress string, votes map[string]int64, opts *TxOptions) (txRawHex string, err error) {
+
+	_, ownerAddressBytes, err := DecodeAddress(ownerAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return "", err
+	}
+
+	if len(votes) == 0 {
+		return "", fmt.Errorf("votes is empty")
+	}
+
+	witnesses := make([]string, 0, len(votes))
+	for witness := range votes {
+		witnesses = append(witnesses, witness)
+	}
+	sort.Strings(witnesses)
+
+	tc := &core.VoteWitnessContract{
+		OwnerAddress: ownerAddressBytes,
+	}
+	for _, witness := range witnesses {
+		if votes[witness] <= 0 {
+			return "", fmt.Errorf("vote count of witness: %s should be greater than zero", witness)
+		}
+		_, witnessAddressBytes, err := DecodeAddress(witness, wm.Config.IsTestNet)
+		if err != nil {
+			return "", err
+		}
+		tc.Votes = append(tc.Votes, &core.VoteWitnessContract_Vote{
+			VoteAddress: witnessAddressBytes,
+			VoteCount:   votes[witness],
+		})
+	}
+
+	return wm.createAssetsTransaction(tc, core.Transaction_Contract_VoteWitnessContract, opts)
+}
+
+//CreateWithdrawBalanceTransaction 创建领取投票奖励的交易单，每24小时只能领取一次
+func (wm *WalletManager) CreateWithdrawBalanceTransaction(ownerAddress string, opts *TxOptions) (txRawHex string, err error) {
+
+	_, ownerAddressBytes, err := DecodeAddress(ownerAddress, wm.Config.IsTestNet)
+	if err != nil {
+		return "", err
+	}
+
+	tc := &core.WithdrawBalanceContract{
+		OwnerAddress: ownerAddressBytes,
+	}
+	return wm.createAssetsTransaction(tc, core.Transaction_Contract_WithdrawBalanceContract, opts)
+}
+
 // ListNodes Writing!
 // Function：
 // 	List the nodes which the api fullnode is connecting on the network
@@ -17,10 +17,14 @@ package tron
 
 import (
 	"testing"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+	"github.com/tidwall/gjson"
 )
 
 func TestListWitnesses(t *testing.T) {
-	if r, err := tw.ListWitnesses(); err != nil {
+	if r, err := tw.ListWitnesses(false); err != nil {
 		t.Errorf("ListWitnesses failed: %v\n", err)
 	} else {
 		t.Logf("ListWitnesses return: \n\t%+v\n", r)
@@ -34,3 +38,106 @@ func TestListNodes(t *testing.T) {
 		t.Logf("ListNodes return: \n\t%+v\n", r)
 	}
 }
+
+func TestNewWitness(t *testing.T) {
+	json := gjson.Parse(`{"address":"41efb6d8a02f4b639605d71ff8dc78c97329759d70","voteCount":1024,"url":"https://example.org","totalProduced":100,"totalMissed":2,"latestBlockNum":3531200,"isJobs":true}`)
+	witness, err := NewWitness(&json, false)
+	if err != nil {
+		t.Errorf("NewWitness failed: %v\n", err)
+		return
+	}
+	if witness.Address[0] != 'T' || witness.VoteCount != 1024 || witness.URL != "https://example.org" || witness.TotalMissed != 2 || !witness.IsJobs {
+		t.Errorf("unexpected witness: %+v", witness)
+	}
+}
+
+func TestWalletManager_CreateVoteWitnessTransaction(t *testing.T) {
+
+	decoder := NewTransactionDecoder(tw)
+	votes := map[string]int64{TOADDRESS: 100, "TEkxiTehnzSmSe2XqrBj4w32RUN966rdz8": 50}
+
+	txRaw, err := tw.CreateVoteWitnessTransaction(OWNERADDRESS, votes, testStakeTxOptions())
+	if err != nil {
+		t.Errorf("CreateVoteWitnessTransaction failed: %v\n", err)
+		return
+	}
+	decoded, err := tw.DecodeRawTransaction(txRaw)
+	if err != nil {
+		t.Errorf("DecodeRawTransaction failed: %v\n", err)
+		return
+	}
+
+	newRawTx := func(votes map[string]int64) *openwallet.RawTransaction {
+		rawTx := &openwallet.RawTransaction{
+			Coin:    openwallet.Coin{Symbol: "TRX"},
+			Account: &openwallet.AssetsAccount{AccountID: "A"},
+			To:      map[string]string{OWNERADDRESS: "150"},
+			RawHex:  txRaw,
+			Signatures: map[string][]*openwallet.KeySignature{
+				"A": {{Address: &openwallet.Address{Address: OWNERADDRESS}, Message: decoded.TxID}},
+			},
+		}
+		rawTx.SetExtParam(contractTypeExtKey, core.Transaction_Contract_VoteWitnessContract.String())
+		rawTx.SetExtParam(votesExtKey, votes)
+		return rawTx
+	}
+
+	if err := decoder.verifyRawTransactionIntent(newRawTx(votes)); err != nil {
+		t.Errorf("verifyRawTransactionIntent failed: %v\n", err)
+	}
+	if err := decoder.verifyRawTransactionIntent(newRawTx(map[string]int64{TOADDRESS: 150})); err == nil {
+		t.Errorf("tampered votes should be rejected")
+	}
+
+	if _, err := tw.CreateVoteWitnessTransaction(OWNERADDRESS, map[string]int64{TOADDRESS: 0}, testStakeTxOptions()); err == nil {
+		t.Errorf("zero vote count should be rejected")
+	}
+}
+
+func TestCreateRawTransaction_VoteWitness(t *testing.T) {
+
+	votes := map[string]int64{TOADDRESS: 100, "TEkxiTehnzSmSe2XqrBj4w32RUN966rdz8": 50}
+
+	wm, closeNode := newStubNodeWalletManager(t, 10*TRX)
+	defer closeNode()
+	rawTx := &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: "TRX"},
+		Account: &openwallet.AssetsAccount{AccountID: "A"},
+		To:      map[string]string{OWNERADDRESS: "0"},
+	}
+	rawTx.SetExtParam(contractTypeExtKey, core.Transaction_Contract_VoteWitnessContract.String())
+	rawTx.SetExtParam(votesExtKey, votes)
+	if err := createSignVerifyRawTransaction(wm, rawTx); err != nil {
+		t.Fatalf("create and verify vote failed: %v", err)
+	}
+	//数量为总票数对应的TRX，投票不转出余额
+	if rawTx.To[OWNERADDRESS] != "150" || rawTx.TxAmount != "0.000000" {
+		t.Errorf("unexpected vote raw transaction: %+v", rawTx)
+	}
+
+	wm, closeNode = newStubNodeWalletManager(t, 10*TRX)
+	defer closeNode()
+	rawTx = &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: "TRX"},
+		Account: &openwallet.AssetsAccount{AccountID: "A"},
+		To:      map[string]string{OWNERADDRESS: "0"},
+	}
+	rawTx.SetExtParam(contractTypeExtKey, core.Transaction_Contract_WithdrawBalanceContract.String())
+	if err := createSignVerifyRawTransaction(wm, rawTx); err != nil {
+		t.Errorf("create and verify withdraw balance failed: %v", err)
+	}
+
+	//数量与总票数不一致
+	wm, closeNode = newStubNodeWalletManager(t, 10*TRX)
+	defer closeNode()
+	rawTx = &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: "TRX"},
+		Account: &openwallet.AssetsAccount{AccountID: "A"},
+		To:      map[string]string{OWNERADDRESS: "100"},
+	}
+	rawTx.SetExtParam(contractTypeExtKey, core.Transaction_Contract_VoteWitnessContract.String())
+	rawTx.SetExtParam(votesExtKey, votes)
+	if err := createSignVerifyRawTransaction(wm, rawTx); err == nil {
+		t.Errorf("vote amount not equal to total votes should be rejected")
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/blocktree/openwallet/v2/common"
 	"github.com/blocktree/openwallet/v2/crypto"
 	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
 	"github.com/shopspring/decimal"
 	"github.com/tidwall/gjson"
 	"math/big"
@@ -144,6 +145,7 @@ type Contract struct {
 	SourceKey       string
 	ContractRet     string
 	Protocol        string
+	Memo            string
 }
 
 func NewContract(json gjson.Result, isTestnet bool) *Contract {
@@ -283,6 +285,7 @@ type Transaction struct {
 	IsCoinBase  bool
 	Ret         []*Result
 	Contract    []*Contract
+	Memo        string //raw_data.data，交易备注
 }
 
 func NewTransaction(json *gjson.Result, blockHash string, blockHeight uint64, blocktime int64, isTestnet bool) *Transaction {
@@ -295,6 +298,10 @@ func NewTransaction(json *gjson.Result, blockHash string, blockHeight uint64, bl
 	b.BlockHeight = blockHeight
 	b.BlockTime = blocktime
 
+	if data, err := hex.DecodeString(rawData.Get("data").String()); err == nil {
+		b.Memo = string(data)
+	}
+
 	b.Ret = make([]*Result, 0)
 	if rets := gjson.Get(json.Raw, "ret"); rets.IsArray() {
 		for _, r := range rets.Array() {
@@ -311,6 +318,7 @@ func NewTransaction(json *gjson.Result, blockHash string, blockHeight uint64, bl
 			contract.BlockHash = blockHash
 			contract.BlockHeight = blockHeight
 			contract.BlockTime = blocktime
+			contract.Memo = b.Memo
 			if len(b.Ret) > i {
 				contract.ContractRet = b.Ret[i].ContractRet
 			}
@@ -326,6 +334,8 @@ type TransactionExtention struct {
 	Txid           string       `json:"txid"`
 	ConstantResult []string     `json:"constant_result"`
 	Result         *Return      `json:"result"`
+	EnergyUsed     int64        `json:"energy_used"`
+	Ret            string       `json:"ret"` //模拟执行结果，transaction.ret[0].ret
 }
 
 type Return struct {
@@ -343,6 +353,25 @@ func NewReturn(json *gjson.Result) *Return {
 	return b
 }
 
+//RevertReason 模拟执行是否失败及失败原因
+func (b *TransactionExtention) RevertReason() (string, bool) {
+	for _, c := range b.ConstantResult {
+		if reason, ok := DecodeRevertReason(c); ok {
+			return reason, true
+		}
+	}
+	if b.Result != nil && !b.Result.Result {
+		return b.Result.Message, true
+	}
+	if b.Ret == "FAILED" {
+		if b.Result != nil && len(b.Result.Message) > 0 {
+			return b.Result.Message, true
+		}
+		return "REVERT opcode executed", true
+	}
+	return "", false
+}
+
 func NewTransactionExtention(json *gjson.Result) *TransactionExtention {
 
 	// 解析json
@@ -351,6 +380,8 @@ func NewTransactionExtention(json *gjson.Result) *TransactionExtention {
 	result := json.Get("result")
 	b.Result = NewReturn(&result)
 	b.Txid = json.Get("txid").String()
+	b.EnergyUsed = json.Get("energy_used").Int()
+	b.Ret = json.Get("transaction.ret.0.ret").String()
 
 	b.ConstantResult = make([]string, 0)
 	if constant_result := json.Get("constant_result"); constant_result.IsArray() {
@@ -409,20 +440,55 @@ func NewAccountNet(json *gjson.Result) *AccountNet {
 	obj.FreeNetUsed = json.Get("freeNetUsed").Int()
 	obj.FreeNetLimit = json.Get("freeNetLimit").Int()
 	obj.NetUsed = json.Get("NetUsed").Int()
+	obj.NetLimit = json.Get("NetLimit").Int()
 	obj.TotalNetLimit = json.Get("TotalNetLimit").Int()
 	obj.TotalNetWeight = json.Get("TotalNetWeight").Int()
+
+	obj.AssetNetUsed = make(map[string]int64, 0)
+	for _, as := range json.Get("assetNetUsed").Array() {
+		obj.AssetNetUsed[as.Get("key").String()] = as.Get("value").Int()
+	}
+	obj.AssetNetLimit = make(map[string]int64, 0)
+	for _, as := range json.Get("assetNetLimit").Array() {
+		obj.AssetNetLimit[as.Get("key").String()] = as.Get("value").Int()
+	}
 	return obj
 }
 
+//AssetIssue TRC10资产发行信息
+type AssetIssue struct {
+	ID                      string
+	OwnerAddress            string //发行者地址
+	FreeAssetNetLimit       int64  //每个账户转账该资产可用的免费带宽
+	PublicFreeAssetNetLimit int64  //所有账户共享的免费带宽，由发行者质押的带宽支付
+	PublicFreeAssetNetUsage int64
+}
+
+func NewAssetIssue(json *gjson.Result, isTestnet bool) (*AssetIssue, error) {
+	obj := &AssetIssue{}
+	obj.ID = json.Get("id").String()
+	owner, err := EncodeAddress(json.Get("owner_address").String(), isTestnet)
+	if err != nil {
+		return nil, err
+	}
+	obj.OwnerAddress = owner
+	obj.FreeAssetNetLimit = json.Get("free_asset_net_limit").Int()
+	obj.PublicFreeAssetNetLimit = json.Get("public_free_asset_net_limit").Int()
+	obj.PublicFreeAssetNetUsage = json.Get("public_free_asset_net_usage").Int()
+	return obj, nil
+}
+
 type AccountResource struct {
-	FreeNetUsed  int64
-	FreeNetLimit int64
-	NetUsed      int64
-	NetLimit     int64
-	EnergyUsed   int64
-	EnergyLimit  int64
-	StorageUsed  int64
-	StorageLimit int64
+	FreeNetUsed       int64
+	FreeNetLimit      int64
+	NetUsed           int64
+	NetLimit          int64
+	EnergyUsed        int64
+	EnergyLimit       int64
+	StorageUsed       int64
+	StorageLimit      int64
+	TotalEnergyLimit  int64 //全网能量上限
+	TotalEnergyWeight int64 //全网质押获取能量的TRX数量
 }
 
 func NewAccountResource(json *gjson.Result) *AccountResource {
@@ -435,9 +501,39 @@ func NewAccountResource(json *gjson.Result) *AccountResource {
 	obj.EnergyLimit = json.Get("EnergyLimit").Int()
 	obj.StorageUsed = json.Get("StorageUsed").Int()
 	obj.StorageLimit = json.Get("StorageLimit").Int()
+	obj.TotalEnergyLimit = json.Get("TotalEnergyLimit").Int()
+	obj.TotalEnergyWeight = json.Get("TotalEnergyWeight").Int()
 	return obj
 }
 
+//Witness 超级代表
+type Witness struct {
+	Address        string
+	URL            string
+	VoteCount      int64
+	TotalProduced  int64
+	TotalMissed    int64
+	LatestBlockNum int64
+	IsJobs         bool  //是否为出块的超级代表
+	Brokerage      int64 //分成比例，单位%
+}
+
+func NewWitness(json *gjson.Result, isTestnet bool) (*Witness, error) {
+	obj := &Witness{}
+	address, err := EncodeAddress(json.Get("address").String(), isTestnet)
+	if err != nil {
+		return nil, err
+	}
+	obj.Address = address
+	obj.URL = json.Get("url").String()
+	obj.VoteCount = json.Get("voteCount").Int()
+	obj.TotalProduced = json.Get("totalProduced").Int()
+	obj.TotalMissed = json.Get("totalMissed").Int()
+	obj.LatestBlockNum = json.Get("latestBlockNum").Int()
+	obj.IsJobs = json.Get("isJobs").Bool()
+	return obj, nil
+}
+
 type txFeeInfo struct {
 	GasUsed  int64
 	GasPrice decimal.Decimal
@@ -474,6 +570,8 @@ type Account struct {
 	FreeNetUsage        int64
 	AssetV2             map[string]*big.Int
 	FreeAssetNetUsageV2 map[string]int64
+	FrozenV2            map[string]int64 //Stake 2.0质押数量，key为资源类型
+	UnfrozenV2          []*UnfrozenV2    //Stake 2.0解除质押中的数量
 
 	/*
 		{
@@ -524,5 +622,50 @@ func NewAccount(json *gjson.Result) *Account {
 			obj.FreeAssetNetUsageV2[as.Get("key").String()] = as.Get("value").Int()
 		}
 	}
+
+	//type为空时是带宽
+	obj.FrozenV2 = make(map[string]int64, 0)
+	for _, frozen := range json.Get("frozenV2").Array() {
+		obj.FrozenV2[resourceType(frozen.Get("type").String())] += frozen.Get("amount").Int()
+	}
+
+	obj.UnfrozenV2 = make([]*UnfrozenV2, 0)
+	for _, unfrozen := range json.Get("unfrozenV2").Array() {
+		obj.UnfrozenV2 = append(obj.UnfrozenV2, &UnfrozenV2{
+			Resource:   resourceType(unfrozen.Get("type").String()),
+			Amount:     unfrozen.Get("unfreeze_amount").Int(),
+			ExpireTime: unfrozen.Get("unfreeze_expire_time").Int(),
+		})
+	}
 	return obj
 }
+
+//WithdrawableAmount 指定时间（毫秒）已到期可提取的解除质押数量
+func (a *Account) WithdrawableAmount(now int64) int64 {
+	amount := int64(0)
+	for _, unfrozen := range a.UnfrozenV2 {
+		if unfrozen.IsExpired(now) {
+			amount += unfrozen.Amount
+		}
+	}
+	return amount
+}
+
+//UnfrozenV2 解除质押中的TRX，到期后通过WithdrawExpireUnfreeze提取
+type UnfrozenV2 struct {
+	Resource   string //BANDWIDTH、ENERGY
+	Amount     int64  //单位SUN
+	ExpireTime int64  //到期时间，毫秒
+}
+
+//IsExpired 指定时间（毫秒）是否已到期
+func (u *UnfrozenV2) IsExpired(now int64) bool {
+	return u.ExpireTime <= now
+}
+
+func resourceType(t string) string {
+	if len(t) == 0 {
+		return core.ResourceCode_BANDWIDTH.String()
+	}
+	return t
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/imroc/req"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	//SignatureLength RSV签名长度，r(32) + s(32) + v(1)
+	SignatureLength = 65
+	//DefaultSignerTimeout 远程签名服务请求超时
+	DefaultSignerTimeout = 30 * time.Second
+)
+
+//Signer 外部签名器，私钥不在适配器内时由它对交易哈希签名，返回65字节RSV签名
+type Signer interface {
+	Sign(keySignature *openwallet.KeySignature) ([]byte, error)
+}
+
+//HTTPSigner 远程签名服务的参考实现
+//请求：POST URL {"address", "publicKey", "hdPath", "eccType", "message"}，message为交易哈希hex
+//响应：{"signature": "<65字节RSV签名hex>"}
+type HTTPSigner struct {
+	URL   string //签名服务地址
+	Token string //非空时以Bearer方式放入Authorization头
+	api   *req.Req
+}
+
+//NewHTTPSigner 创建远程签名器
+func NewHTTPSigner(url, token string) *HTTPSigner {
+	api := req.New()
+	api.SetClient(&http.Client{Timeout: DefaultSignerTimeout})
+	return &HTTPSigner{
+		URL:   url,
+		Token: token,
+		api:   api,
+	}
+}
+
+//Sign 请求远程签名服务签名
+func (s *HTTPSigner) Sign(keySignature *openwallet.KeySignature) ([]byte, error) {
+
+	if keySignature == nil || keySignature.Address == nil {
+		return nil, fmt.Errorf("key signature address is empty")
+	}
+
+	param := map[string]interface{}{
+		"address":   keySignature.Address.Address,
+		"publicKey": keySignature.Address.PublicKey,
+		"hdPath":    keySignature.Address.HDPath,
+		"eccType":   keySignature.EccType,
+		"message":   keySignature.Message,
+	}
+	header := req.Header{"Accept": "application/json"}
+	if len(s.Token) > 0 {
+		header["Authorization"] = "Bearer " + s.Token
+	}
+
+	r, err := s.api.Post(s.URL, req.BodyJSON(&param), header)
+	if err != nil {
+		return nil, err
+	}
+	if r.Response().StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("[%s]%s", r.Response().Status, r.String())
+	}
+
+	result := gjson.ParseBytes(r.Bytes())
+	if msg := result.Get("error").String(); len(msg) > 0 {
+		return nil, fmt.Errorf("remote signer error: %s", msg)
+	}
+	return hex.DecodeString(result.Get("signature").String())
+}
+
+//signKeySignatures 签名交易哈希，配置了外部签名器时使用签名器，否则用钱包派生私钥
+func (wm *WalletManager) signKeySignatures(wrapper openwallet.WalletDAI, keySignatures []*openwallet.KeySignature) error {
+
+	//配置了外部签名器时，私钥不经过适配器
+	if signer := wm.Signer; signer != nil {
+		for _, keySignature := range keySignatures {
+			signature, err := wm.signWithSigner(signer, keySignature)
+			if err != nil {
+				wm.Log.Info("external signer failed;unexpected error:%v", err)
+				return openwallet.Errorf(openwallet.ErrSignRawTransactionFailed, "%v", err)
+			}
+			keySignature.Signature = signature
+		}
+		return nil
+	}
+
+	key, err := wrapper.HDKey()
+	if err != nil {
+		wm.Log.Info("wrapper HDkey failed;unexpected error:%v", err)
+		return err
+	}
+	for _, keySignature := range keySignatures {
+		childKey, err := key.DerivedKeyWithPath(keySignature.Address.HDPath, wm.CurveType())
+		if err != nil {
+			wm.Log.Info("derived key with path failed;unexpected error:%v", err)
+			return err
+		}
+		priKeyBytes, err := childKey.GetPrivateKeyBytes()
+		if err != nil {
+			wm.Log.Info("get privatekey bytes failed;unexpected error:%v", err)
+			return err
+		}
+		signature, err := wm.SignTransactionRef(keySignature.Message, hex.EncodeToString(priKeyBytes))
+		if err != nil {
+			wm.Log.Info("sign Tx failed;unexpected error:%v", err)
+			return err
+		}
+		keySignature.Signature = signature
+	}
+	return nil
+}
+
+//signWithSigner 通过外部签名器签名，校验签名恢复的地址与签名地址一致后返回签名hex。
+//签名器返回的v为27/28时转为0/1，与本地签名一致
+func (wm *WalletManager) signWithSigner(signer Signer, keySignature *openwallet.KeySignature) (string, error) {
+
+	signature, err := signer.Sign(keySignature)
+	if err != nil {
+		return "", err
+	}
+	if err := wm.verifyKeySignature(keySignature, signature); err != nil {
+		return "", err
+	}
+	signature = append([]byte{}, signature...)
+	if signature[64] >= 27 {
+		signature[64] -= 27
+	}
+	return hex.EncodeToString(signature), nil
+}
+
+//verifyKeySignature 校验RSV签名由keySignature地址的私钥对Message签出，v兼容0/1和27/28
+func (wm *WalletManager) verifyKeySignature(keySignature *openwallet.KeySignature, signature []byte) error {
+
+	txHash, err := hex.DecodeString(keySignature.Message)
+	if err != nil {
+		return fmt.Errorf("message is not hex: %v", err)
+	}
+	signer, err := wm.recoverSigner(txHash, hex.EncodeToString(signature))
+	if err != nil {
+		return err
+	}
+	if signer != keySignature.Address.Address {
+		return fmt.Errorf("signature is signed by %s, expected %s", signer, keySignature.Address.Address)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+//newStubSigner 本地签名服务桩，使用PRIVATEKEY签名，truncate截短返回的签名，legacyV返回27/28的v
+func newStubSigner(t *testing.T, truncate, legacyV bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var param map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&param); err != nil {
+			t.Errorf("decode request failed: %v", err)
+			return
+		}
+		signature, err := tw.SignTransactionRef(param["message"].(string), PRIVATEKEY)
+		if err != nil {
+			t.Errorf("SignTransactionRef failed: %v", err)
+			return
+		}
+		if truncate {
+			signature = signature[:128]
+		}
+		if legacyV {
+			sig, _ := hex.DecodeString(signature)
+			sig[64] += 27
+			signature = hex.EncodeToString(sig)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"signature": signature})
+	}))
+}
+
+func testKeySignature(address string) *openwallet.KeySignature {
+	return &openwallet.KeySignature{
+		EccType: tw.CurveType(),
+		Address: &openwallet.Address{Address: address, HDPath: "m/44'/195'/0'/0/0"},
+		Message: "a7e7f3dc44b8e8c4c98dbba98a8e9e8a4fd6b0d1b6da04b0bd7b9d6ff2b0b3c1",
+	}
+}
+
+func TestHTTPSigner(t *testing.T) {
+
+	server := newStubSigner(t, false, false)
+	defer server.Close()
+
+	keySignature := testKeySignature(OWNERADDRESS)
+	signature, err := tw.signWithSigner(NewHTTPSigner(server.URL, "token"), keySignature)
+	if err != nil {
+		t.Fatalf("signWithSigner failed: %v", err)
+	}
+	if len(signature) != SignatureLength*2 {
+		t.Errorf("signature = %s", signature)
+	}
+
+	//签名地址与签名请求地址不一致
+	if _, err := tw.signWithSigner(NewHTTPSigner(server.URL, "token"), testKeySignature(TOADDRESS)); err == nil {
+		t.Errorf("signature of another address is accepted")
+	}
+
+	//访问令牌错误
+	if _, err := tw.signWithSigner(NewHTTPSigner(server.URL, "wrong"), keySignature); err == nil {
+		t.Errorf("unauthorized response is accepted")
+	}
+}
+
+func TestHTTPSignerLegacyV(t *testing.T) {
+
+	server := newStubSigner(t, false, true)
+	defer server.Close()
+
+	keySignature := testKeySignature(OWNERADDRESS)
+	signature, err := tw.signWithSigner(NewHTTPSigner(server.URL, "token"), keySignature)
+	if err != nil {
+		t.Fatalf("signature with v 27/28 is rejected: %v", err)
+	}
+	//返回的v与本地签名一致为0/1
+	sig, _ := hex.DecodeString(signature)
+	if sig[64] > 1 || tw.verifyKeySignature(keySignature, sig) != nil {
+		t.Errorf("signature = %s", signature)
+	}
+}
+
+func TestHTTPSignerInvalidSignature(t *testing.T) {
+
+	server := newStubSigner(t, true, false)
+	defer server.Close()
+
+	if _, err := tw.signWithSigner(NewHTTPSigner(server.URL, "token"), testKeySignature(OWNERADDRESS)); err == nil {
+		t.Errorf("64 bytes signature is accepted")
+	}
+}
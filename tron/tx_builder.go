@@ -0,0 +1,211 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+)
+
+//RefBlock 交易参考区块，取区块ID的第6~8字节和第8~16字节
+type RefBlock struct {
+	RefBlockBytes []byte
+	RefBlockHash  []byte
+}
+
+//NewRefBlock 通过区块生成参考区块
+func NewRefBlock(block *Block) (*RefBlock, error) {
+	if block == nil {
+		return nil, fmt.Errorf("reference block is nil")
+	}
+	blockID, err := hex.DecodeString(block.GetBlockHashID())
+	if err != nil {
+		return nil, err
+	}
+	if len(blockID) != 32 {
+		return nil, fmt.Errorf("invalid reference block id: %s", block.GetBlockHashID())
+	}
+	return &RefBlock{
+		RefBlockBytes: blockID[6:8],
+		RefBlockHash:  blockID[8:16],
+	}, nil
+}
+
+//NewRefBlockFromHex 通过ref_block_bytes和ref_block_hash生成参考区块
+func NewRefBlockFromHex(refBlockBytes, refBlockHash string) (*RefBlock, error) {
+	refBytes, err := hex.DecodeString(refBlockBytes)
+	if err != nil || len(refBytes) != 2 {
+		return nil, fmt.Errorf("invalid ref_block_bytes: %s", refBlockBytes)
+	}
+	refHash, err := hex.DecodeString(refBlockHash)
+	if err != nil || len(refHash) != 8 {
+		return nil, fmt.Errorf("invalid ref_block_hash: %s", refBlockHash)
+	}
+	return &RefBlock{
+		RefBlockBytes: refBytes,
+		RefBlockHash:  refHash,
+	}, nil
+}
+
+//lastTxTimestamp 上一笔交易单的时间戳，毫秒
+var (
+	lastTxTimestamp   int64
+	lastTxTimestampMu sync.Mutex
+)
+
+//nextTxTimestamp 交易单时间戳，同一进程内严格递增。参考区块缓存期间，
+//同一毫秒创建的两笔相同交易会有相同的交易ID，后广播的一笔被节点当作重复交易
+func nextTxTimestamp() int64 {
+	lastTxTimestampMu.Lock()
+	defer lastTxTimestampMu.Unlock()
+
+	timestamp := time.Now().UnixNano() / 1000000
+	if timestamp <= lastTxTimestamp {
+		timestamp = lastTxTimestamp + 1
+	}
+	lastTxTimestamp = timestamp
+	return timestamp
+}
+
+//BuildTokenTransaction 离线创建主币或代币交易单，参考区块由调用方提供，不访问网络
+func (wm *WalletManager) BuildTokenTransaction(refBlock *RefBlock, toAddress, ownerAddress string, amount string, contract openwallet.SmartContract, opts *TxOptions) (txRawHex string, txID string, err error) {
+
+	message, contractType, err := wm.makeTransferContract(toAddress, ownerAddress, amount, contract)
+	if err != nil {
+		return "", "", err
+	}
+
+	return wm.BuildContractTransaction(refBlock, message, contractType, opts)
+}
+
+//BuildContractTransaction 离线创建任意合约类型的交易单，参考区块由调用方提供，不访问网络
+func (wm *WalletManager) BuildContractTransaction(refBlock *RefBlock, message proto.Message, contractType core.Transaction_Contract_ContractType, opts *TxOptions) (txRawHex string, txID string, err error) {
+
+	if refBlock == nil {
+		return "", "", fmt.Errorf("reference block is nil")
+	}
+
+	msgBytes, err := proto.Marshal(message)
+	if err != nil {
+		return "", "", err
+	}
+
+	txContact := &core.Transaction_Contract{
+		Type:         contractType,
+		Parameter:    &any.Any{Value: msgBytes, TypeUrl: "type.googleapis.com/protocol." + contractType.String()},
+		Provider:     nil,
+		ContractName: nil,
+	}
+
+	// ********Set timestamp ********
+	timestamp := nextTxTimestamp()
+
+	// ******** Create Traction ********
+	txRaw := &core.TransactionRaw{
+		RefBlockBytes: refBlock.RefBlockBytes,
+		RefBlockHash:  refBlock.RefBlockHash,
+		Contract:      []*core.Transaction_Contract{txContact},
+		Expiration:    timestamp + wm.txExpiration(opts).Nanoseconds()/1000000,
+		Timestamp:     timestamp,
+	}
+
+	if opts != nil && opts.FeeLimit > 0 {
+		txRaw.FeeLimit = opts.FeeLimit
+	} else if wm.Config.FeeLimit > 0 {
+		txRaw.FeeLimit = wm.Config.FeeLimit
+	}
+
+	if opts != nil && len(opts.Memo) > 0 {
+		if !utf8.ValidString(opts.Memo) {
+			return "", "", fmt.Errorf("memo is not a valid UTF-8 string")
+		}
+		txRaw.Data = []byte(opts.Memo)
+	}
+
+	tx := &core.Transaction{
+		RawData: txRaw,
+	}
+
+	x, err := proto.Marshal(tx)
+	if err != nil {
+		wm.Log.Info("marshal tx failed;unexpected error:%v", err)
+		return "", "", err
+	}
+
+	txHash, err := getTxHash(tx)
+	if err != nil {
+		return "", "", err
+	}
+
+	return hex.EncodeToString(x), hex.EncodeToString(txHash), nil
+}
+
+//RefBlockCache 参考区块缓存，多笔交易共用同一个参考区块，过期后重新获取
+type RefBlockCache struct {
+	mu       sync.Mutex
+	Duration time.Duration
+	blocks   map[string]*refBlockCacheItem
+}
+
+type refBlockCacheItem struct {
+	block     *Block
+	updatedAt time.Time
+}
+
+//NewRefBlockCache 创建参考区块缓存，duration为缓存刷新间隔
+func NewRefBlockCache(duration time.Duration) *RefBlockCache {
+	return &RefBlockCache{
+		Duration: duration,
+		blocks:   make(map[string]*refBlockCacheItem),
+	}
+}
+
+//Get 获取缓存的参考区块，缓存过期时通过fetch重新获取
+func (c *RefBlockCache) Get(refBlockType string, fetch func() (*Block, error)) (*Block, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.blocks[refBlockType]
+	if ok && c.Duration > 0 && time.Since(item.updatedAt) < c.Duration {
+		return item.block, nil
+	}
+
+	block, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	c.blocks[refBlockType] = &refBlockCacheItem{
+		block:     block,
+		updatedAt: time.Now(),
+	}
+	return block, nil
+}
+
+//Reset 清空缓存
+func (c *RefBlockCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blocks = make(map[string]*refBlockCacheItem)
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/log"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+	"github.com/golang/protobuf/proto"
+	"github.com/tidwall/gjson"
+)
+
+func TestWalletManager_BuildTokenTransaction(t *testing.T) {
+
+	refBlock, err := NewRefBlockFromHex("e1c0", "f60afaa8387fd17f")
+	if err != nil {
+		t.Errorf("NewRefBlockFromHex failed: %v\n", err)
+		return
+	}
+
+	trc20Contract := openwallet.SmartContract{
+		Address:  "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t",
+		Protocol: TRC20,
+		Decimals: 6,
+	}
+
+	txRaw, txID, err := tw.BuildTokenTransaction(refBlock, TOADDRESS, OWNERADDRESS, "1.5", trc20Contract, nil)
+	if err != nil {
+		t.Errorf("BuildTokenTransaction failed: %v\n", err)
+		return
+	}
+
+	txHash, err := getTxHash1(txRaw)
+	if err != nil {
+		t.Errorf("getTxHash1 failed: %v\n", err)
+		return
+	}
+	if hex.EncodeToString(txHash) != txID {
+		t.Errorf("txid: %s is not equal to tx hash: %x", txID, txHash)
+		return
+	}
+	log.Infof("txRaw: %s", txRaw)
+	log.Infof("txID: %s", txID)
+}
+
+func TestWalletManager_BuildTokenTransaction_UniqueTxID(t *testing.T) {
+
+	refBlock, _ := NewRefBlockFromHex("e1c0", "f60afaa8387fd17f")
+
+	//参考区块相同的两笔相同转账，交易ID也不能相同
+	txIDs := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		_, txID, err := tw.BuildTokenTransaction(refBlock, TOADDRESS, OWNERADDRESS, "1.5", openwallet.SmartContract{}, nil)
+		if err != nil {
+			t.Fatalf("BuildTokenTransaction failed: %v", err)
+		}
+		if txIDs[txID] {
+			t.Fatalf("duplicate txid: %s", txID)
+		}
+		txIDs[txID] = true
+	}
+}
+
+func TestRefBlockCache_Get(t *testing.T) {
+
+	fetchCount := 0
+	fetch := func() (*Block, error) {
+		fetchCount++
+		return &Block{Hash: "000000000035e1c0f60afaa8387fd17fd9b84fe4381265ff084d739f814558ea", Height: 3531200}, nil
+	}
+
+	cache := NewRefBlockCache(time.Minute)
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Get(RefBlockHead, fetch); err != nil {
+			t.Errorf("RefBlockCache Get failed: %v\n", err)
+			return
+		}
+	}
+	if fetchCount != 1 {
+		t.Errorf("reference block should be fetched once, got %d", fetchCount)
+	}
+
+	//不缓存
+	cache = NewRefBlockCache(0)
+	cache.Get(RefBlockHead, fetch)
+	cache.Get(RefBlockHead, fetch)
+	if fetchCount != 3 {
+		t.Errorf("reference block should be fetched every time, got %d", fetchCount)
+	}
+}
+
+func TestWalletManager_BuildTokenTransaction_Memo(t *testing.T) {
+
+	refBlock, err := NewRefBlockFromHex("e1c0", "f60afaa8387fd17f")
+	if err != nil {
+		t.Errorf("NewRefBlockFromHex failed: %v\n", err)
+		return
+	}
+
+	memo := "deposit 充值 10086"
+	txRaw, _, err := tw.BuildTokenTransaction(refBlock, TOADDRESS, OWNERADDRESS, "1", openwallet.SmartContract{}, &TxOptions{Memo: memo})
+	if err != nil {
+		t.Errorf("BuildTokenTransaction failed: %v\n", err)
+		return
+	}
+
+	txBytes, _ := hex.DecodeString(txRaw)
+	tx := &core.Transaction{}
+	if err := proto.Unmarshal(txBytes, tx); err != nil {
+		t.Errorf("Unmarshal failed: %v\n", err)
+		return
+	}
+	if string(tx.GetRawData().GetData()) != memo {
+		t.Errorf("raw_data.data = %s, want %s", tx.GetRawData().GetData(), memo)
+	}
+
+	if EstimateBandwidth(txRaw) != int64(len(txBytes)+SignatureBandwidth+ResultBandwidth) {
+		t.Errorf("EstimateBandwidth mismatch")
+	}
+
+	_, _, err = tw.BuildTokenTransaction(refBlock, TOADDRESS, OWNERADDRESS, "1", openwallet.SmartContract{}, &TxOptions{Memo: string([]byte{0xff, 0xfe})})
+	if err == nil {
+		t.Errorf("invalid UTF-8 memo should be rejected")
+	}
+}
+
+func TestNewTransaction_Memo(t *testing.T) {
+	raw := `{"txID":"a1","raw_data":{"data":"6465706f736974","contract":[{"parameter":{"value":{"amount":1000000,"owner_address":"41a614f803b6fd780986a42c78ec9c7f77e6ded13c","to_address":"41e9d79cc47518930bc322d9bf7cddd260a0260a8d"},"type_url":"type.googleapis.com/protocol.TransferContract"},"type":"TransferContract"}]},"ret":[{"contractRet":"SUCCESS"}]}`
+	json := gjson.Parse(raw)
+	tx := NewTransaction(&json, "", 1, 0, false)
+	if tx.Memo != "deposit" {
+		t.Errorf("memo = %s, want deposit", tx.Memo)
+	}
+	if len(tx.Contract) != 1 || tx.Contract[0].Memo != "deposit" {
+		t.Errorf("contract memo not set")
+	}
+}
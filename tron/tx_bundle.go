@@ -0,0 +1,332 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+const (
+	//TxBundleVersion 交易单包格式版本
+	TxBundleVersion = 1
+	//txBundleCompactPrefix 紧凑编码前缀，冒号与base32字符都在二维码字母数字模式内
+	txBundleCompactPrefix = "TRXB1:"
+)
+
+//txBundleEncoding 紧凑编码使用的无填充base32
+var txBundleEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+//TxBundle 离线签名交易单包，在线机器构建导出，离线机器签名后再导回在线机器广播
+type TxBundle struct {
+	Version      int                  `json:"version"`
+	Symbol       string               `json:"symbol"`
+	IsTestNet    bool                 `json:"isTestNet"`
+	CreatedTime  int64                `json:"createdTime"`
+	Transactions []*BundleTransaction `json:"transactions"`
+	Checksum     string               `json:"checksum"` //版本、网络与原始交易单的sha256前8字节，预览不参与校验
+}
+
+//BundleTransaction 交易单包中的一笔原始交易单
+type BundleTransaction struct {
+	RawTx   *openwallet.RawTransaction `json:"rawTx"`
+	Preview []*DecodedTransaction      `json:"preview"` //RawHex按顺序解析的预览，导入时重新生成
+}
+
+//NewTxBundle 把已构建的原始交易单打包
+func (wm *WalletManager) NewTxBundle(rawTxs ...*openwallet.RawTransaction) (*TxBundle, error) {
+
+	if len(rawTxs) == 0 {
+		return nil, fmt.Errorf("no raw transaction to bundle")
+	}
+
+	bundle := &TxBundle{
+		Version:     TxBundleVersion,
+		Symbol:      wm.Symbol(),
+		IsTestNet:   wm.Config.IsTestNet,
+		CreatedTime: time.Now().Unix(),
+	}
+	for _, rawTx := range rawTxs {
+		if !rawTx.IsBuilt || len(rawTx.RawHex) == 0 {
+			return nil, fmt.Errorf("raw transaction is not built")
+		}
+		bundle.Transactions = append(bundle.Transactions, &BundleTransaction{RawTx: rawTx})
+	}
+	if err := wm.previewTxBundle(bundle); err != nil {
+		return nil, err
+	}
+	if err := bundle.seal(); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+//RawTransactions 交易单包中的原始交易单
+func (bundle *TxBundle) RawTransactions() []*openwallet.RawTransaction {
+	rawTxs := make([]*openwallet.RawTransaction, 0, len(bundle.Transactions))
+	for _, tx := range bundle.Transactions {
+		rawTxs = append(rawTxs, tx.RawTx)
+	}
+	return rawTxs
+}
+
+//ExportJSON 导出JSON格式
+func (bundle *TxBundle) ExportJSON() ([]byte, error) {
+	if err := bundle.seal(); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(bundle, "", "  ")
+}
+
+//ExportCompact 导出紧凑编码，不含预览，deflate压缩后base32编码，适合二维码传输
+func (bundle *TxBundle) ExportCompact() (string, error) {
+
+	if err := bundle.seal(); err != nil {
+		return "", err
+	}
+	compact := *bundle
+	compact.Transactions = make([]*BundleTransaction, 0, len(bundle.Transactions))
+	for _, tx := range bundle.Transactions {
+		compact.Transactions = append(compact.Transactions, &BundleTransaction{RawTx: tx.RawTx})
+	}
+	data, err := json.Marshal(&compact)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return txBundleCompactPrefix + txBundleEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+//ImportTxBundle 导入JSON或紧凑编码的交易单包，校验版本、网络和校验和，并按RawHex重新生成预览
+func (wm *WalletManager) ImportTxBundle(data []byte) (*TxBundle, error) {
+
+	text := strings.TrimSpace(string(data))
+	if strings.HasPrefix(strings.ToUpper(text), txBundleCompactPrefix) {
+		compressed, err := txBundleEncoding.DecodeString(strings.ToUpper(text[len(txBundleCompactPrefix):]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid compact bundle: %v", err)
+		}
+		data, err = ioutil.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid compact bundle: %v", err)
+		}
+	}
+
+	bundle := &TxBundle{}
+	if err := json.Unmarshal(data, bundle); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %v", err)
+	}
+	if bundle.Version != TxBundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version: %d", bundle.Version)
+	}
+	if bundle.Symbol != wm.Symbol() || bundle.IsTestNet != wm.Config.IsTestNet {
+		return nil, fmt.Errorf("bundle is for %s (testnet: %v)", bundle.Symbol, bundle.IsTestNet)
+	}
+	checksum, err := bundle.checksum()
+	if err != nil {
+		return nil, err
+	}
+	if checksum != bundle.Checksum {
+		return nil, fmt.Errorf("bundle checksum mismatch")
+	}
+	for _, tx := range bundle.Transactions {
+		if tx.RawTx == nil || tx.RawTx.Account == nil || len(tx.RawTx.RawHex) == 0 {
+			return nil, fmt.Errorf("bundle contains an incomplete raw transaction")
+		}
+	}
+	if err := wm.previewTxBundle(bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+//SignTxBundle 离线签名，只依赖交易单包和钥匙，签名前检查交易内容与交易单一致
+func (decoder *TransactionDecoder) SignTxBundle(bundle *TxBundle, key *hdkeystore.HDKey) error {
+
+	for _, tx := range bundle.Transactions {
+		rawTx := tx.RawTx
+		if len(rawTx.Account.WalletID) > 0 && rawTx.Account.WalletID != key.KeyID {
+			return fmt.Errorf("raw transaction belongs to wallet: %s, key is: %s", rawTx.Account.WalletID, key.KeyID)
+		}
+		if err := decoder.verifyRawTransactionIntent(rawTx); err != nil {
+			return openwallet.Errorf(openwallet.ErrSignRawTransactionFailed, "%v", err)
+		}
+		for _, keySignature := range rawTx.Signatures[rawTx.Account.AccountID] {
+			childKey, err := key.DerivedKeyWithPath(keySignature.Address.HDPath, decoder.wm.CurveType())
+			if err != nil {
+				return err
+			}
+			priKeyBytes, err := childKey.GetPrivateKeyBytes()
+			if err != nil {
+				return err
+			}
+			signature, err := decoder.wm.SignTransactionRef(keySignature.Message, hex.EncodeToString(priKeyBytes))
+			if err != nil {
+				return err
+			}
+			sig, _ := hex.DecodeString(signature)
+			//派生路径与签名地址不一致时拒绝
+			if err := decoder.wm.verifyKeySignature(keySignature, sig); err != nil {
+				return openwallet.Errorf(openwallet.ErrSignRawTransactionFailed, "%v", err)
+			}
+			keySignature.Signature = signature
+		}
+	}
+	return bundle.seal()
+}
+
+//SignTxBundleWithKeystore 从钥匙库读取钥匙后离线签名
+func (decoder *TransactionDecoder) SignTxBundleWithKeystore(bundle *TxBundle, keystore *hdkeystore.HDKeystore, keyFile, password string) error {
+	walletID := ""
+	if len(bundle.Transactions) > 0 {
+		walletID = bundle.Transactions[0].RawTx.Account.WalletID
+	}
+	key, err := keystore.GetKey(walletID, keyFile, password)
+	if err != nil {
+		return err
+	}
+	return decoder.SignTxBundle(bundle, key)
+}
+
+//MergeTxBundleSignatures 把签名后交易单包中的签名合并回在线机器保存的原始交易单，
+//交易数据、签名地址和被签消息必须与原始交易单一致，签名通过InsertSignatureIntoRawTransaction合并并验证
+func (decoder *TransactionDecoder) MergeTxBundleSignatures(rawTxs []*openwallet.RawTransaction, signed *TxBundle) error {
+
+	if len(rawTxs) != len(signed.Transactions) {
+		return fmt.Errorf("signed bundle has %d transactions, expected %d", len(signed.Transactions), len(rawTxs))
+	}
+	for i, rawTx := range rawTxs {
+		signedTx := signed.Transactions[i].RawTx
+		if signedTx.RawHex != rawTx.RawHex {
+			return fmt.Errorf("transaction %d of signed bundle is not equal to the raw transaction", i)
+		}
+		keySignatures := rawTx.Signatures[rawTx.Account.AccountID]
+		signatures := signedTx.Signatures[rawTx.Account.AccountID]
+		if len(signatures) != len(keySignatures) {
+			return fmt.Errorf("transaction %d signature count: %d is not equal to: %d", i, len(signatures), len(keySignatures))
+		}
+		txHexArray := splitRawHex(rawTx.RawHex)
+		if len(txHexArray) != len(keySignatures) {
+			return fmt.Errorf("transaction %d signature count: %d is not equal to transaction count: %d", i, len(keySignatures), len(txHexArray))
+		}
+		for j, keySignature := range keySignatures {
+			sig := signatures[j]
+			if sig.Address == nil || sig.Address.Address != keySignature.Address.Address || sig.Message != keySignature.Message {
+				return fmt.Errorf("transaction %d signature %d is not signed for: %s", i, j, keySignature.Address.Address)
+			}
+			signedHex, err := InsertSignatureIntoRawTransaction(txHexArray[j], sig.Signature)
+			if err != nil {
+				return err
+			}
+			if err := decoder.wm.ValidSignedTokenTransaction(signedHex); err != nil {
+				return fmt.Errorf("transaction %d signature %d verify failed: %v", i, j, err)
+			}
+		}
+		for j, keySignature := range keySignatures {
+			keySignature.Signature = signatures[j].Signature
+		}
+	}
+	return nil
+}
+
+//SubmitTxBundle 合并签名后逐笔验证并广播，返回与交易单顺序一致的交易，失败的为nil，错误为第一个失败原因
+func (decoder *TransactionDecoder) SubmitTxBundle(wrapper openwallet.WalletDAI, rawTxs []*openwallet.RawTransaction, signed *TxBundle) ([]*openwallet.Transaction, error) {
+
+	if err := decoder.MergeTxBundleSignatures(rawTxs, signed); err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrSubmitRawTransactionFailed, "%v", err)
+	}
+
+	var (
+		txs       = make([]*openwallet.Transaction, len(rawTxs))
+		submitErr error
+	)
+	for i, rawTx := range rawTxs {
+		err := decoder.VerifyRawTransaction(wrapper, rawTx)
+		if err == nil {
+			txs[i], err = decoder.SubmitRawTransaction(wrapper, rawTx)
+		}
+		if err != nil {
+			decoder.wm.Log.Infof("submit bundle transaction %d failed;unexpected error: %v", i, err)
+			if submitErr == nil {
+				submitErr = err
+			}
+		}
+	}
+	return txs, submitErr
+}
+
+//previewTxBundle 按RawHex解析每笔TRON交易生成预览
+func (wm *WalletManager) previewTxBundle(bundle *TxBundle) error {
+	for _, tx := range bundle.Transactions {
+		tx.Preview = make([]*DecodedTransaction, 0)
+		for _, txHex := range splitRawHex(tx.RawTx.RawHex) {
+			decoded, err := wm.DecodeRawTransaction(txHex)
+			if err != nil {
+				return err
+			}
+			tx.Preview = append(tx.Preview, decoded)
+		}
+	}
+	return nil
+}
+
+//seal 重新计算校验和
+func (bundle *TxBundle) seal() error {
+	checksum, err := bundle.checksum()
+	if err != nil {
+		return err
+	}
+	bundle.Checksum = checksum
+	return nil
+}
+
+//checksum 版本、网络、创建时间和原始交易单的sha256前8字节
+func (bundle *TxBundle) checksum() (string, error) {
+	data, err := json.Marshal(struct {
+		Version     int                          `json:"version"`
+		Symbol      string                       `json:"symbol"`
+		IsTestNet   bool                         `json:"isTestNet"`
+		CreatedTime int64                        `json:"createdTime"`
+		RawTxs      []*openwallet.RawTransaction `json:"rawTxs"`
+	}{bundle.Version, bundle.Symbol, bundle.IsTestNet, bundle.CreatedTime, bundle.RawTransactions()})
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:8]), nil
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+)
+
+//testColdRawTx 用固定种子的钥匙构建一笔质押交易单，返回钥匙和交易单
+func testColdRawTx(t *testing.T) (*hdkeystore.HDKey, *openwallet.RawTransaction) {
+
+	key, err := hdkeystore.NewHDKey(bytes.Repeat([]byte{1}, 32), "cold", hdkeystore.OpenwCoinTypePath)
+	if err != nil {
+		t.Fatalf("NewHDKey failed: %v", err)
+	}
+	hdPath := key.RootPath + "/0/0"
+	childKey, err := key.DerivedKeyWithPath(hdPath, tw.CurveType())
+	if err != nil {
+		t.Fatalf("DerivedKeyWithPath failed: %v", err)
+	}
+	address, err := tw.AddrDecoder.PublicKeyToAddress(childKey.GetPublicKeyBytes(), false)
+	if err != nil {
+		t.Fatalf("PublicKeyToAddress failed: %v", err)
+	}
+
+	txRaw, err := tw.CreateFreezeBalanceV2Transaction(address, "100", core.ResourceCode_ENERGY, testStakeTxOptions())
+	if err != nil {
+		t.Fatalf("CreateFreezeBalanceV2Transaction failed: %v", err)
+	}
+	decoded, err := tw.DecodeRawTransaction(txRaw)
+	if err != nil {
+		t.Fatalf("DecodeRawTransaction failed: %v", err)
+	}
+
+	rawTx := &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: "TRX"},
+		Account: &openwallet.AssetsAccount{AccountID: "A", WalletID: key.KeyID},
+		To:      map[string]string{address: "100"},
+		RawHex:  txRaw,
+		IsBuilt: true,
+		Signatures: map[string][]*openwallet.KeySignature{
+			"A": {{EccType: tw.CurveType(), Address: &openwallet.Address{Address: address, HDPath: hdPath}, Message: decoded.TxID}},
+		},
+	}
+	rawTx.SetExtParam(contractTypeExtKey, core.Transaction_Contract_FreezeBalanceV2Contract.String())
+	rawTx.SetExtParam(resourceExtKey, "ENERGY")
+	return key, rawTx
+}
+
+func TestTxBundle_ColdSigning(t *testing.T) {
+
+	decoder := NewTransactionDecoder(tw)
+	key, rawTx := testColdRawTx(t)
+
+	//在线机器导出
+	bundle, err := tw.NewTxBundle(rawTx)
+	if err != nil {
+		t.Fatalf("NewTxBundle failed: %v", err)
+	}
+	compact, err := bundle.ExportCompact()
+	if err != nil {
+		t.Fatalf("ExportCompact failed: %v", err)
+	}
+	if !strings.HasPrefix(compact, txBundleCompactPrefix) || strings.ContainsAny(compact, "abcdefghijklmnopqrstuvwxyz=") {
+		t.Errorf("compact bundle is not QR alphanumeric: %s", compact)
+	}
+
+	//离线机器导入、签名、导出
+	cold, err := tw.ImportTxBundle([]byte(compact))
+	if err != nil {
+		t.Fatalf("ImportTxBundle compact failed: %v", err)
+	}
+	if c := cold.Transactions[0].Preview[0].Contracts[0]; c.Type != core.Transaction_Contract_FreezeBalanceV2Contract.String() || c.Amount != "100" {
+		t.Errorf("unexpected preview: %+v", c)
+	}
+	if err := decoder.SignTxBundle(cold, key); err != nil {
+		t.Fatalf("SignTxBundle failed: %v", err)
+	}
+	signedJSON, err := cold.ExportJSON()
+	if err != nil {
+		t.Fatalf("ExportJSON failed: %v", err)
+	}
+
+	//在线机器导入签名后的交易单包，合并签名
+	signed, err := tw.ImportTxBundle(signedJSON)
+	if err != nil {
+		t.Fatalf("ImportTxBundle json failed: %v", err)
+	}
+	if err := decoder.MergeTxBundleSignatures([]*openwallet.RawTransaction{rawTx}, signed); err != nil {
+		t.Fatalf("MergeTxBundleSignatures failed: %v", err)
+	}
+	if err := decoder.VerifyRawTransaction(nil, rawTx); err != nil {
+		t.Errorf("VerifyRawTransaction failed: %v", err)
+	}
+
+	//篡改数量后校验和不一致
+	for address := range rawTx.To {
+		tampered := bytes.Replace(signedJSON, []byte(`"`+address+`": "100"`), []byte(`"`+address+`": "1000"`), 1)
+		if bytes.Equal(tampered, signedJSON) {
+			t.Fatalf("amount of %s is not found in bundle", address)
+		}
+		if _, err := tw.ImportTxBundle(tampered); err == nil {
+			t.Errorf("tampered bundle is accepted")
+		}
+	}
+}
+
+func TestTxBundle_WrongKey(t *testing.T) {
+
+	_, rawTx := testColdRawTx(t)
+	bundle, err := tw.NewTxBundle(rawTx)
+	if err != nil {
+		t.Fatalf("NewTxBundle failed: %v", err)
+	}
+	other, err := hdkeystore.NewHDKey(bytes.Repeat([]byte{2}, 32), "other", hdkeystore.OpenwCoinTypePath)
+	if err != nil {
+		t.Fatalf("NewHDKey failed: %v", err)
+	}
+	if err := NewTransactionDecoder(tw).SignTxBundle(bundle, other); err == nil {
+		t.Errorf("bundle is signed by another wallet")
+	}
+}
@@ -17,6 +17,7 @@ package tron
 
 import (
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"github.com/blocktree/openwallet/v2/common"
 	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
@@ -28,6 +29,7 @@ import (
 
 	"github.com/blocktree/openwallet/v2/openwallet"
 	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
 	// "github.com/blocktree/openwallet/assets/qtum/btcLikeTxDriver"
 	// "github.com/blocktree/openwallet/log"
 	// "github.com/shopspring/decimal"
@@ -39,14 +41,120 @@ type TransactionDecoder struct {
 	wm *WalletManager //钱包管理者
 }
 
+const (
+	//rawHexSeparator 多个接收地址的原始交易单，RawHex由多笔TRON交易数据按顺序拼接
+	rawHexSeparator = ","
+	//txPartsExtKey 原始交易单扩展参数中记录拆分交易明细的字段
+	txPartsExtKey = "txParts"
+	//trc20MethodExtKey TRC20方法，默认transfer
+	trc20MethodExtKey = "trc20Method"
+	//tokenFromExtKey transferFrom的代币转出地址
+	tokenFromExtKey = "tokenFrom"
+	//spenderExtKey 汇总扩展参数中通过transferFrom汇总的spender
+	spenderExtKey = "spender"
+	//feesSupportStrategyExtKey 汇总扩展参数中的手续费支持策略
+	feesSupportStrategyExtKey = "feesSupportStrategy"
+	//contractTypeExtKey 非转账交易单的合约类型，如DelegateResourceContract
+	contractTypeExtKey = "contractType"
+	//resourceExtKey 代理的资源类型：BANDWIDTH、ENERGY
+	resourceExtKey = "resource"
+	//summaryStepExtKey 代理能量汇总的步骤：delegate、summary、undelegate
+	summaryStepExtKey = "summaryStep"
+	//votesExtKey 投票交易单的超级代表地址和票数
+	votesExtKey = "votes"
+	//fromExtKey 调用方指定的发送地址
+	fromExtKey = "from"
+	//addressStrategyExtKey 发送地址选择策略，未设置时使用配置addressSelectStrategy
+	addressStrategyExtKey = "addressStrategy"
+)
+
+//手续费支持策略，TRC20汇总时地址能量不足的处理方式
+const (
+	FeesSupportStrategyTransfer = "transfer" //手续费账户充值TRX，默认
+	FeesSupportStrategyDelegate = "delegate" //手续费账户代理能量，汇总确认后取回
+)
+
+//代理能量汇总的步骤，按顺序广播，取回交易单在汇总确认后通过CreateUndelegateRawTransaction创建
+const (
+	SummaryStepDelegate   = "delegate"
+	SummaryStepSummary    = "summary"
+	SummaryStepUndelegate = "undelegate"
+)
+
+//RawTxPart 原始交易单中的单笔TRON交易，一个接收地址对应一笔
+type RawTxPart struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount string `json:"amount"`
+	Fees   string `json:"fees"`
+	TxID   string `json:"txid,omitempty"`  //广播后记录的交易ID
+	Error  string `json:"error,omitempty"` //广播失败原因，为空表示广播成功
+
+	rawHex  string
+	balance *AddrBalance
+	feeInfo *txFeeInfo
+}
+
 func CheckRawTransaction(rawTx *openwallet.RawTransaction) error {
-	//账户模型原始账单只有一个To
-	if len(rawTx.To) != 1 {
-		return fmt.Errorf("noly one to address can be set!")
+	//账户模型原始账单至少有一个To，多个To会拆分为多笔交易
+	if len(rawTx.To) == 0 {
+		return fmt.Errorf("at least one to address should be set!")
 	}
 	return nil
 }
 
+//GetRawTxParts 获取原始交易单拆分的交易明细，顺序与RawHex中的交易一致
+func GetRawTxParts(rawTx *openwallet.RawTransaction) ([]*RawTxPart, error) {
+
+	parts := make([]*RawTxPart, 0)
+
+	ext := rawTx.GetExtParam().Get(txPartsExtKey)
+	if ext.Exists() {
+		if err := json.Unmarshal([]byte(ext.Raw), &parts); err != nil {
+			return nil, err
+		}
+		return parts, nil
+	}
+
+	//兼容没有记录明细的交易单
+	for i, to := range rawTx.TxTo {
+		part := &RawTxPart{}
+		part.To, part.Amount = splitAddressAmount(to)
+		if i < len(rawTx.TxFrom) {
+			part.From, _ = splitAddressAmount(rawTx.TxFrom[i])
+		}
+		part.Fees = rawTx.Fees
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+func splitAddressAmount(s string) (string, string) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i+1:]
+}
+
+func splitRawHex(rawHex string) []string {
+	return strings.Split(rawHex, rawHexSeparator)
+}
+
+func joinRawHex(txHexArray []string) string {
+	return strings.Join(txHexArray, rawHexSeparator)
+}
+
+//sortedRecipients 接收地址按字典排序，保证拆分交易的顺序稳定
+func sortedRecipients(to map[string]string) []string {
+	recipients := make([]string, 0, len(to))
+	for k := range to {
+		recipients = append(recipients, k)
+	}
+	sort.Strings(recipients)
+	return recipients
+}
+
 func InsertSignatureIntoRawTransaction(txHex string, signature string) (string, error) {
 
 	tx := &core.Transaction{}
@@ -87,10 +195,11 @@ func NewTransactionDecoder(wm *WalletManager) *TransactionDecoder {
 func (decoder *TransactionDecoder) CreateSimpleTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
 
 	var (
-		accountID       = rawTx.Account.AccountID
-		findAddrBalance *AddrBalance
-		rawHex          string
-		feeInfo         *txFeeInfo
+		accountID = rawTx.Account.AccountID
+		parts     = make([]*RawTxPart, 0)
+		txOpts    = NewTxOptions(rawTx.GetExtParam())
+		//多个接收地址时，记录每个地址已分配的消耗
+		spent = make(map[string]decimal.Decimal)
 	)
 
 	//获取wallet
@@ -113,88 +222,95 @@ func (decoder *TransactionDecoder) CreateSimpleTransaction(wrapper openwallet.Wa
 		return err
 	}
 
-	var amountStr, to string
-	for k, v := range rawTx.To {
-		to = k
-		amountStr = v
-		break
-	}
-
-	//地址余额从大到小排序
-	sort.Slice(addrBalanceArray, func(i int, j int) bool {
-		a_amount, _ := decimal.NewFromString(addrBalanceArray[i].Balance)
-		b_amount, _ := decimal.NewFromString(addrBalanceArray[j].Balance)
-		if a_amount.LessThan(b_amount) {
-			return true
-		} else {
-			return false
-		}
-	})
-
-	amountDec, _ := decimal.NewFromString(amountStr)
-
-	//检查目标地址是否存在
-	_, exist, err := decoder.wm.GetTRXAccount(to)
+	//按选择策略排序候选发送地址
+	addrBalanceArray, err = decoder.selectSenderBalances(rawTx, addrBalanceArray)
 	if err != nil {
 		return err
 	}
 
-	for _, addrBalance := range addrBalanceArray {
+	//每个接收地址拆分为一笔TRON交易
+	for _, to := range sortedRecipients(rawTx.To) {
 
-		totalCost := amountDec
+		var (
+			findAddrBalance *AddrBalance
+			rawHex          string
+			feeInfo         *txFeeInfo
+			totalCost       decimal.Decimal
+		)
 
-		addrBalance_dec, _ := decimal.NewFromString(addrBalance.Balance)
+		amountStr := rawTx.To[to]
+		amountDec, _ := decimal.NewFromString(amountStr)
 
-		//创建空交易单
-		rawHex, err = decoder.wm.CreateTokenTransaction(to, addrBalance.Address, amountStr, openwallet.SmartContract{})
+		//检查目标地址是否存在
+		_, exist, err := decoder.wm.GetTRXAccount(to)
 		if err != nil {
 			return err
 		}
 
-		rawTx.RawHex = rawHex
+		for _, addrBalance := range addrBalanceArray {
 
-		//计算手续费
-		feeInfo, err = decoder.wm.GetTransactionFeeEstimated(addrBalance.Address, rawHex)
-		if err != nil {
-			decoder.wm.Log.Std.Error("GetTransactionFeeEstimated from[%v] -> to[%v] failed, err=%v", addrBalance.Address, to, err)
-			continue
-		}
+			totalCost = amountDec
 
-		totalCost = totalCost.Add(feeInfo.Fee)
+			//扣除已分配给其他接收地址的消耗
+			addrBalance_dec, _ := decimal.NewFromString(addrBalance.Balance)
+			addrBalance_dec = addrBalance_dec.Sub(spent[addrBalance.Address])
 
-		//目标地址不存在，总消耗要加0.1
-		if !exist {
-			newAccountCost := decimal.New(CreateAccountCost, 0)
-			newAccountCost = newAccountCost.Shift(-decoder.wm.Decimal())
-			totalCost = totalCost.Add(newAccountCost)
-		}
+			//创建空交易单
+			rawHex, err = decoder.wm.CreateTokenTransactionWithOptions(to, addrBalance.Address, amountStr, openwallet.SmartContract{}, txOpts)
+			if err != nil {
+				return err
+			}
 
-		//总消耗数量 = 转账数量 + 手续费
-		if addrBalance_dec.LessThan(totalCost) {
-			continue
-		}
+			//计算手续费
+			feeInfo, err = decoder.wm.GetTransactionFeeEstimated(addrBalance.Address, rawHex)
+			if err != nil {
+				decoder.wm.Log.Std.Error("GetTransactionFeeEstimated from[%v] -> to[%v] failed, err=%v", addrBalance.Address, to, err)
+				continue
+			}
 
-		//只要找到一个合适使用的地址余额就停止遍历
-		findAddrBalance = &AddrBalance{Address: addrBalance.Address, TronBalance: common.StringNumToBigIntWithExp(amountStr, Decimals)}
-		break
-	}
+			totalCost = totalCost.Add(feeInfo.Fee)
 
-	if findAddrBalance == nil {
-		if exist {
-			return openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "the balance is not enough")
-		} else {
-			return openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "the balance is not enough, [%s] is not exist should cost 0.1 %s to create", to, decoder.wm.Symbol())
+			//目标地址不存在，总消耗要加0.1
+			if !exist {
+				newAccountCost := decimal.New(CreateAccountCost, 0)
+				newAccountCost = newAccountCost.Shift(-decoder.wm.Decimal())
+				totalCost = totalCost.Add(newAccountCost)
+			}
+
+			//总消耗数量 = 转账数量 + 手续费
+			if addrBalance_dec.LessThan(totalCost) {
+				continue
+			}
+
+			//只要找到一个合适使用的地址余额就停止遍历
+			findAddrBalance = &AddrBalance{Address: addrBalance.Address, TronBalance: common.StringNumToBigIntWithExp(amountStr, Decimals)}
+			break
+		}
+
+		if findAddrBalance == nil {
+			if exist {
+				return openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "the balance is not enough")
+			} else {
+				return openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "the balance is not enough, [%s] is not exist should cost 0.1 %s to create", to, decoder.wm.Symbol())
+			}
 		}
 
+		spent[findAddrBalance.Address] = spent[findAddrBalance.Address].Add(totalCost)
+
+		parts = append(parts, &RawTxPart{
+			To:      to,
+			Amount:  amountStr,
+			rawHex:  rawHex,
+			balance: findAddrBalance,
+			feeInfo: feeInfo,
+		})
 	}
 
 	//最后创建交易单
-	createTxErr := decoder.createRawTransaction(
+	createTxErr := decoder.createRawTransactionParts(
 		wrapper,
 		rawTx,
-		findAddrBalance,
-		feeInfo,
-		"")
+		parts)
 	if createTxErr != nil {
 		return createTxErr
 	}
@@ -202,14 +318,63 @@ func (decoder *TransactionDecoder) CreateSimpleTransaction(wrapper openwallet.Wa
 	return nil
 }
 
+//selectSenderBalances 按选择策略排序候选发送地址，跳过有未完成交易的地址。
+//扩展参数from指定发送地址时只使用该地址
+func (decoder *TransactionDecoder) selectSenderBalances(rawTx *openwallet.RawTransaction, balances []*openwallet.Balance) ([]*openwallet.Balance, error) {
+	return decoder.wm.selectSenderBalances(
+		rawTx.Account.AccountID,
+		rawTx.GetExtParam().Get(fromExtKey).String(),
+		rawTx.GetExtParam().Get(addressStrategyExtKey).String(),
+		balances)
+}
+
+//selectSenderBalances 按指定地址或选择策略排序可用的发送地址，跳过被未确认交易锁定的地址
+func (wm *WalletManager) selectSenderBalances(accountID, from, strategy string, balances []*openwallet.Balance) ([]*openwallet.Balance, error) {
+
+	if len(from) > 0 {
+		for _, b := range balances {
+			if b.Address != from {
+				continue
+			}
+			if wm.AddressLocker.IsLocked(from) {
+				return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "address: %s is locked by pending transaction", from)
+			}
+			return []*openwallet.Balance{b}, nil
+		}
+		return nil, openwallet.Errorf(openwallet.ErrAddressNotFound, "address: %s is not found in account: %s", from, accountID)
+	}
+
+	if len(strategy) == 0 {
+		strategy = wm.Config.AddressSelectStrategy
+	}
+	if len(strategy) == 0 {
+		strategy = AddressSelectSmallest
+	}
+	selector, ok := wm.AddressSelectors[strategy]
+	if !ok {
+		return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "address select strategy: %s is not supported", strategy)
+	}
+
+	unlocked := make([]*openwallet.Balance, 0, len(balances))
+	for _, b := range balances {
+		if !wm.AddressLocker.IsLocked(b.Address) {
+			unlocked = append(unlocked, b)
+		}
+	}
+	return selector.Sort(accountID, unlocked), nil
+}
+
 //CreateTokenTransaction 创建代币交易单
 func (decoder *TransactionDecoder) CreateTokenTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
 
 	var (
-		accountID       = rawTx.Account.AccountID
-		findAddrBalance *AddrBalance
-		rawHex          string
-		feeInfo         *txFeeInfo
+		accountID = rawTx.Account.AccountID
+		parts     = make([]*RawTxPart, 0)
+		txOpts    = NewTxOptions(rawTx.GetExtParam())
+		//多个接收地址时，记录每个地址已分配的代币、主币消耗及交易笔数
+		spentToken = make(map[string]decimal.Decimal)
+		spentTRX   = make(map[string]*big.Int)
+		txCount    = make(map[string]int64)
 	)
 
 	tokenDecimals := rawTx.Coin.Contract.Decimals
@@ -235,14 +400,7 @@ func (decoder *TransactionDecoder) CreateTokenTransaction(wrapper openwallet.Wal
 		return err
 	}
 
-	var amountStr, to string
-	for k, v := range rawTx.To {
-		to = k
-		amountStr = v
-		break
-	}
-
-	//地址余额从大到小排序
+	//地址余额从小到大排序
 	sort.Slice(addrBalanceArray, func(i int, j int) bool {
 		a_amount, _ := decimal.NewFromString(addrBalanceArray[i].Balance.Balance)
 		b_amount, _ := decimal.NewFromString(addrBalanceArray[j].Balance.Balance)
@@ -253,105 +411,145 @@ func (decoder *TransactionDecoder) CreateTokenTransaction(wrapper openwallet.Wal
 		}
 	})
 
-	tokenBalanceNotEnough := false
-	balanceNotEnough := false
-	errStr := ""
-
-	amountDec, _ := decimal.NewFromString(amountStr)
-
-	//检查目标地址是否存在
-	_, exist, err := decoder.wm.GetTRXAccount(to)
-	if err != nil {
-		return err
-	}
+	//每个接收地址拆分为一笔TRON交易
+	for _, to := range sortedRecipients(rawTx.To) {
 
-	for _, addrBalance := range addrBalanceArray {
+		var (
+			findAddrBalance *AddrBalance
+			rawHex          string
+			feeInfo         *txFeeInfo
+			trxCost         *big.Int
+		)
 
-		trxBalance := big.NewInt(0)
+		tokenBalanceNotEnough := false
+		balanceNotEnough := false
+		errStr := ""
 
-		addrBalance_dec, _ := decimal.NewFromString(addrBalance.Balance.Balance)
+		amountStr := rawTx.To[to]
+		amountDec, _ := decimal.NewFromString(amountStr)
 
-		//创建空交易单
-		rawHex, err = decoder.wm.CreateTokenTransaction(to, addrBalance.Balance.Address, amountStr, rawTx.Coin.Contract)
+		//检查目标地址是否存在
+		_, exist, err := decoder.wm.GetTRXAccount(to)
 		if err != nil {
 			return err
 		}
 
-		rawTx.RawHex = rawHex
-
-		//计算手续费
-		feeInfo, err = decoder.wm.GetTransactionFeeEstimated(addrBalance.Balance.Address, rawHex)
-		if err != nil {
-			decoder.wm.Log.Std.Error("GetTransactionFeeEstimated from[%v] -> to[%v] failed, err=%v", addrBalance.Balance.Address, to, err)
-			continue
-		}
+		for _, addrBalance := range addrBalanceArray {
 
-		//查询主币余额是否足够
-		addrTRXBalanceArray, err := decoder.wm.Blockscanner.GetBalanceByAddress(addrBalance.Balance.Address)
-		if err != nil {
-			return err
-		}
-		if len(addrTRXBalanceArray) > 0 {
-			trxBalance = common.StringNumToBigIntWithExp(addrTRXBalanceArray[0].Balance, decoder.wm.Decimal())
-		}
+			trxBalance := big.NewInt(0)
+			trxCost = big.NewInt(0)
+			address := addrBalance.Balance.Address
 
-		//总消耗数量 = 转账数量 + 手续费
-		if addrBalance_dec.LessThan(amountDec) {
-			tokenBalanceNotEnough = true
-			continue
-		} else {
-			tokenBalanceNotEnough = false
-		}
+			//扣除已分配给其他接收地址的代币
+			addrBalance_dec, _ := decimal.NewFromString(addrBalance.Balance.Balance)
+			addrBalance_dec = addrBalance_dec.Sub(spentToken[address])
 
-		//目标地址不存在，总消耗要加0.1
-		if !exist {
-			newAccountCost := big.NewInt(CreateAccountCost)
-			trxBalance.Sub(trxBalance, newAccountCost)
-		}
+			//创建空交易单
+			rawHex, err = decoder.wm.CreateTokenTransactionWithOptions(to, address, amountStr, rawTx.Coin.Contract, txOpts)
+			if err != nil {
+				return err
+			}
 
-		//TRC20，需要检查能量是否足够调用合约
-		if strings.EqualFold(tokenProtocol, TRC20) {
-			//判断账户资源是否足够
-			isEnoughEnegry, energyRest, feeMini := decoder.wm.IsEnoughEnergyToTransferTRC20(addrBalance.Balance.Address, trxBalance)
-			if !isEnoughEnegry {
-				balanceNotEnough = true
-				errStr = fmt.Sprintf("address[%s] available energy: %d is less than feeMini: %d", addrBalance.Balance.Address, energyRest, feeMini)
+			//计算手续费
+			feeInfo, err = decoder.wm.GetTransactionFeeEstimated(address, rawHex)
+			if err != nil {
+				decoder.wm.Log.Std.Error("GetTransactionFeeEstimated from[%v] -> to[%v] failed, err=%v", address, to, err)
 				continue
+			}
 
+			//查询主币余额是否足够
+			addrTRXBalanceArray, err := decoder.wm.Blockscanner.GetBalanceByAddress(address)
+			if err != nil {
+				return err
 			}
-		} else {
-			if trxBalance.Cmp(big.NewInt(0)) < 0 {
-				balanceNotEnough = true
-				errStr = fmt.Sprintf("the %s balance is not enough, [%s] is not exist should cost 0.1 %s to create", decoder.wm.Symbol(), to, decoder.wm.Symbol())
+			if len(addrTRXBalanceArray) > 0 {
+				trxBalance = common.StringNumToBigIntWithExp(addrTRXBalanceArray[0].Balance, decoder.wm.Decimal())
+			}
+			if used, ok := spentTRX[address]; ok {
+				trxBalance.Sub(trxBalance, used)
+			}
+
+			//总消耗数量 = 转账数量 + 手续费
+			if addrBalance_dec.LessThan(amountDec) {
+				tokenBalanceNotEnough = true
 				continue
+			} else {
+				tokenBalanceNotEnough = false
+			}
+
+			//目标地址不存在，总消耗要加0.1
+			if !exist {
+				newAccountCost := big.NewInt(CreateAccountCost)
+				trxBalance.Sub(trxBalance, newAccountCost)
+				trxCost.Add(trxCost, newAccountCost)
+			}
+
+			//TRC20，需要检查能量是否足够调用合约
+			if strings.EqualFold(tokenProtocol, TRC20) {
+				//判断账户资源是否足够，同一地址发起多笔交易时，能量需满足全部交易
+				isEnoughEnegry, energyRest, feeMini := decoder.wm.IsEnoughEnergyToTransferTRC20(address, trxBalance)
+				if !isEnoughEnegry || energyRest < feeMini*(txCount[address]+1) {
+					balanceNotEnough = true
+					errStr = fmt.Sprintf("address[%s] available energy: %d is less than feeMini: %d", address, energyRest, feeMini*(txCount[address]+1))
+					continue
+
+				}
+			} else {
+				if trxBalance.Cmp(big.NewInt(0)) < 0 {
+					balanceNotEnough = true
+					errStr = fmt.Sprintf("the %s balance is not enough, [%s] is not exist should cost 0.1 %s to create", decoder.wm.Symbol(), to, decoder.wm.Symbol())
+					continue
+				}
+			}
+
+			//只要找到一个合适使用的地址余额就停止遍历
+			findAddrBalance = &AddrBalance{
+				Address:      address,
+				TokenBalance: common.StringNumToBigIntWithExp(amountStr, int32(tokenDecimals)),
+				TronBalance:  trxBalance,
 			}
+			break
 		}
 
-		//只要找到一个合适使用的地址余额就停止遍历
-		findAddrBalance = &AddrBalance{
-			Address:      addrBalance.Balance.Address,
-			TokenBalance: common.StringNumToBigIntWithExp(amountStr, int32(tokenDecimals)),
-			TronBalance:  trxBalance,
+		if findAddrBalance == nil {
+			if tokenBalanceNotEnough {
+				return openwallet.Errorf(openwallet.ErrInsufficientTokenBalanceOfAddress, "the balance: %s is not enough", amountStr)
+			}
+			if balanceNotEnough {
+				return openwallet.Errorf(openwallet.ErrInsufficientFees, errStr)
+			}
+			return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "can not find address to send [%s] %s", to, amountStr)
 		}
-		break
-	}
 
-	if findAddrBalance == nil {
-		if tokenBalanceNotEnough {
-			return openwallet.Errorf(openwallet.ErrInsufficientTokenBalanceOfAddress, "the balance: %s is not enough", amountStr)
+		//模拟执行合约调用，预计revert则不创建交易单
+		if strings.EqualFold(tokenProtocol, TRC20) && (decoder.wm.Config.DryRun || txOpts.DryRun) {
+			if err := decoder.wm.DryRunTransaction(rawHex); err != nil {
+				return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "%v", err)
+			}
 		}
-		if balanceNotEnough {
-			return openwallet.Errorf(openwallet.ErrInsufficientFees, errStr)
+
+		address := findAddrBalance.Address
+		spentToken[address] = spentToken[address].Add(amountDec)
+		if _, ok := spentTRX[address]; !ok {
+			spentTRX[address] = big.NewInt(0)
 		}
+		spentTRX[address].Add(spentTRX[address], trxCost)
+		txCount[address]++
+
+		parts = append(parts, &RawTxPart{
+			To:      to,
+			Amount:  amountStr,
+			rawHex:  rawHex,
+			balance: findAddrBalance,
+			feeInfo: feeInfo,
+		})
 	}
 
 	//最后创建交易单
-	createTxErr := decoder.createRawTransaction(
+	createTxErr := decoder.createRawTransactionParts(
 		wrapper,
 		rawTx,
-		findAddrBalance,
-		feeInfo,
-		"")
+		parts)
 	if createTxErr != nil {
 		return createTxErr
 	}
@@ -359,106 +557,363 @@ func (decoder *TransactionDecoder) CreateTokenTransaction(wrapper openwallet.Wal
 	return nil
 }
 
-func (decoder *TransactionDecoder) CreateRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
-	if !rawTx.Coin.IsContract {
-		return decoder.CreateSimpleTransaction(wrapper, rawTx)
-	} else {
-		return decoder.CreateTokenTransaction(wrapper, rawTx)
-	}
-	//contract To Do
-}
+//CreateAccountCreateRawTransaction 创建激活账户的交易单，rawTx.To为待激活的地址，可批量激活。
+//扩展参数contractType为AccountCreateContract时由CreateRawTransaction调用，激活费用按链参数计算
+func (decoder *TransactionDecoder) CreateAccountCreateRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
 
-//SignRawTransaction 签名交易单
-func (decoder *TransactionDecoder) SignRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
+	var (
+		accountID = rawTx.Account.AccountID
+		parts     = make([]*RawTxPart, 0)
+		txOpts    = NewTxOptions(rawTx.GetExtParam())
+		//记录每个地址已分配的激活费用
+		spent = make(map[string]decimal.Decimal)
+	)
 
-	if rawTx.Signatures == nil || len(rawTx.Signatures) == 0 {
-		//this.wm.Log.Std.Error("len of signatures error. ")
-		return fmt.Errorf("transaction signature is empty")
+	if rawTx.Coin.IsContract {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "account create transaction should not be contract")
+	}
+	if len(rawTx.To) == 0 {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "accounts to create are empty")
 	}
 
-	key, err := wrapper.HDKey()
+	params, err := decoder.wm.GetChainParameters()
 	if err != nil {
-		decoder.wm.Log.Info("wrapper HDkey failed;unexpected error:%v", err)
 		return err
 	}
-	keySignatures := rawTx.Signatures[rawTx.Account.AccountID]
-	//fmt.Println("keySignatures:=", keySignatures)
-	if keySignatures != nil {
-		for _, keySignature := range keySignatures {
-			childKey, err := key.DerivedKeyWithPath(keySignature.Address.HDPath, decoder.wm.CurveType())
-			if err != nil {
-				decoder.wm.Log.Info("derived key with path failed;unexpected error:%v", err)
-				return err
+
+	addresses, err := wrapper.GetAddressList(0, -1, "AccountID", accountID)
+	if err != nil {
+		return err
+	}
+	if len(addresses) == 0 {
+		return openwallet.Errorf(openwallet.ErrAccountNotAddress, "[%s] have not addresses", accountID)
+	}
+	searchAddrs := make([]string, 0)
+	for _, address := range addresses {
+		searchAddrs = append(searchAddrs, address.Address)
+	}
+	addrBalanceArray, err := decoder.wm.Blockscanner.GetBalanceByAddress(searchAddrs...)
+	if err != nil {
+		return err
+	}
+	//按选择策略排序，跳过被未完成交易锁定的地址，创建交易单时锁定选中的发送地址
+	addrBalanceArray, err = decoder.selectSenderBalances(rawTx, addrBalanceArray)
+	if err != nil {
+		return err
+	}
+
+	//激活交易不转账，数量为0，不修改调用方的To
+	to := make(map[string]string, len(rawTx.To))
+	for _, address := range sortedRecipients(rawTx.To) {
+
+		var (
+			findAddrBalance *AddrBalance
+			rawHex          string
+			feeInfo         *txFeeInfo
+		)
+
+		//已激活的地址不能重复激活
+		_, exist, err := decoder.wm.GetTRXAccount(address)
+		if err != nil {
+			return err
+		}
+		if exist {
+			return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "account: %s is already activated", address)
+		}
+
+		for _, addrBalance := range addrBalanceArray {
+
+			addrBalance_dec, _ := decimal.NewFromString(addrBalance.Balance)
+			addrBalance_dec = addrBalance_dec.Sub(spent[addrBalance.Address])
+			if addrBalance_dec.LessThanOrEqual(decimal.Zero) {
+				continue
 			}
-			priKeyBytes, err := childKey.GetPrivateKeyBytes()
+
+			rawHex, err = decoder.wm.CreateAccountCreateTransaction(addrBalance.Address, address, txOpts)
 			if err != nil {
-				decoder.wm.Log.Info("get privatekey bytes failed;unexpected error:%v", err)
 				return err
 			}
-			//txHashBytes, err := getTxHash1(rawTx.RawHex)
-			//if err != nil {
-			//	decoder.wm.Log.Info("get Tx hash failed;unexpected error:%v", err)
-			//	return err
-			//}
-			//txHash := hex.EncodeToString(txHashBytes)
-			txHash := keySignature.Message
-			priKey := hex.EncodeToString(priKeyBytes)
-			signature, err := decoder.wm.SignTransactionRef(txHash, priKey)
+
+			feeInfo, err = decoder.wm.GetAccountCreateFeeEstimated(addrBalance.Address, rawHex, params)
 			if err != nil {
-				decoder.wm.Log.Info("sign Tx failed;unexpected error:%v", err)
-				return err
+				decoder.wm.Log.Std.Error("GetAccountCreateFeeEstimated from[%v] -> to[%v] failed, err=%v", addrBalance.Address, address, err)
+				continue
+			}
+
+			if addrBalance_dec.LessThan(feeInfo.Fee) {
+				continue
 			}
-			keySignature.Signature = signature
+
+			findAddrBalance = &AddrBalance{Address: addrBalance.Address, TronBalance: big.NewInt(0)}
+			break
+		}
+
+		if findAddrBalance == nil {
+			return openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAccount, "the balance is not enough to create account: %s", address)
 		}
+
+		spent[findAddrBalance.Address] = spent[findAddrBalance.Address].Add(feeInfo.Fee)
+
+		to[address] = "0"
+		parts = append(parts, &RawTxPart{
+			To:      address,
+			Amount:  "0",
+			rawHex:  rawHex,
+			balance: findAddrBalance,
+			feeInfo: feeInfo,
+		})
+	}
+
+	rawTx.To = to
+	createTxErr := decoder.createRawTransactionParts(wrapper, rawTx, parts)
+	if createTxErr != nil {
+		return createTxErr
 	}
-	decoder.wm.Log.Info("Tx hash sign success")
-	//rawTx.Signatures[rawTx.Account.AccountID] = keySignatures
 	return nil
 }
 
-//VerifyRawTransaction 验证交易单，验证交易单并返回加入签名后的交易单
-func (decoder *TransactionDecoder) VerifyRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
-	//检测交易单基本字段
-	err := CheckRawTransaction(rawTx)
-	if err != nil {
-		decoder.wm.Log.Info("verify Tx base field failed;unexpected error:%v", err)
-		return err
-	}
+//CreateStakeRawTransaction 创建质押和投票相关的交易单，To只有一个地址，为发起交易的本账户地址。
+//质押和解除质押的数量为To的数量，扩展参数resource指定资源类型；投票的票数由扩展参数votes指定，
+//数量为总票数对应的TRX；提取、取消解除质押和领取投票奖励的数量为0
+func (decoder *TransactionDecoder) CreateStakeRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction, contractType core.Transaction_Contract_ContractType) error {
 
-	if rawTx.Signatures == nil || len(rawTx.Signatures) == 0 {
-		//this.wm.Log.Std.Error("len of signatures error. ")
-		return fmt.Errorf("transaction signature is empty")
-	}
+	var (
+		accountID = rawTx.Account.AccountID
+		txOpts    = NewTxOptions(rawTx.GetExtParam())
+		resource  = rawTx.GetExtParam().Get(resourceExtKey).String()
+		rawHex    string
+		spend     = decimal.Zero
+	)
 
-	sig, exist := rawTx.Signatures[rawTx.Account.AccountID]
-	if !exist {
-		return fmt.Errorf("wallet signature not found")
+	if rawTx.Coin.IsContract {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "%s transaction should not be contract", contractType.String())
 	}
-
-	if len(sig) == 0 {
-		//this.wm.Log.Std.Error("len of signatures error. ")
-		return fmt.Errorf("transaction signature is empty")
+	if len(rawTx.To) != 1 {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "%s transaction should have only one owner address", contractType.String())
 	}
+	owner := sortedRecipients(rawTx.To)[0]
+	amount := rawTx.To[owner]
 
-	mergeTxHex, err := InsertSignatureIntoRawTransaction(rawTx.RawHex, sig[0].Signature)
+	addresses, err := wrapper.GetAddressList(0, -1, "AccountID", accountID)
 	if err != nil {
-		decoder.wm.Log.Info("merge empty transaction and signature failed;unexpected error:%v", err)
 		return err
 	}
-	verifyRet := decoder.wm.ValidSignedTokenTransaction(mergeTxHex)
-	if verifyRet != nil {
-		decoder.wm.Log.Info("Tx signature verify failed;unexpected error:%v", verifyRet)
-		return fmt.Errorf("Tx signature verify failed")
-	} else {
-		rawTx.IsCompleted = true
-		//rawTx.RawHex = mergeTxHex
+	searchAddrs := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		searchAddrs = append(searchAddrs, address.Address)
 	}
-	return nil
-}
-
-//SubmitRawTransaction 广播交易单
+	addrBalanceArray, err := decoder.wm.Blockscanner.GetBalanceByAddress(searchAddrs...)
+	if err != nil {
+		return err
+	}
+	//只能由本账户的地址发起，地址被未完成交易锁定时不能创建
+	addrBalanceArray, err = decoder.wm.selectSenderBalances(accountID, owner, "", addrBalanceArray)
+	if err != nil {
+		return err
+	}
+	addrBalance := addrBalanceArray[0]
+
+	switch contractType {
+	case core.Transaction_Contract_FreezeBalanceV2Contract, core.Transaction_Contract_UnfreezeBalanceV2Contract:
+		value, ok := core.ResourceCode_value[resource]
+		if !ok {
+			return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "resource: %s is not supported", resource)
+		}
+		if contractType == core.Transaction_Contract_FreezeBalanceV2Contract {
+			rawHex, err = decoder.wm.CreateFreezeBalanceV2Transaction(owner, amount, core.ResourceCode(value), txOpts)
+			//质押的TRX从可用余额扣除
+			spend, _ = decimal.NewFromString(amount)
+		} else {
+			rawHex, err = decoder.wm.CreateUnfreezeBalanceV2Transaction(owner, amount, core.ResourceCode(value), txOpts)
+		}
+	case core.Transaction_Contract_VoteWitnessContract:
+		if len(resource) > 0 {
+			return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "%s transaction should not have resource", contractType.String())
+		}
+		votes := make(map[string]int64)
+		totalVotes := int64(0)
+		for witness, count := range rawTx.GetExtParam().Get(votesExtKey).Map() {
+			votes[witness] = count.Int()
+			totalVotes += count.Int()
+		}
+		//1票需要1 TRX的质押，数量为总票数对应的TRX
+		voteAmount := decimal.New(totalVotes*MinDelegateBalance, -decoder.wm.Decimal())
+		if amountDec, _ := decimal.NewFromString(amount); !amountDec.IsZero() && !amountDec.Equal(voteAmount) {
+			return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "vote amount: %s is not equal to total votes: %d TRX", amount, totalVotes)
+		}
+		amount = voteAmount.String()
+		rawHex, err = decoder.wm.CreateVoteWitnessTransaction(owner, votes, txOpts)
+	case core.Transaction_Contract_WithdrawExpireUnfreezeContract,
+		core.Transaction_Contract_CancelAllUnfreezeV2Contract,
+		core.Transaction_Contract_WithdrawBalanceContract:
+		if len(resource) > 0 {
+			return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "%s transaction should not have resource", contractType.String())
+		}
+		if amountDec, _ := decimal.NewFromString(amount); !amountDec.IsZero() {
+			return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "%s transaction amount should be 0", contractType.String())
+		}
+		amount = "0"
+		switch contractType {
+		case core.Transaction_Contract_WithdrawExpireUnfreezeContract:
+			rawHex, err = decoder.wm.CreateWithdrawExpireUnfreezeTransaction(owner, txOpts)
+		case core.Transaction_Contract_CancelAllUnfreezeV2Contract:
+			rawHex, err = decoder.wm.CreateCancelAllUnfreezeV2Transaction(owner, txOpts)
+		default:
+			rawHex, err = decoder.wm.CreateWithdrawBalanceTransaction(owner, txOpts)
+		}
+	default:
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "unsupported contract type: %s", contractType.String())
+	}
+	if err != nil {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "%v", err)
+	}
+
+	feeInfo, err := decoder.wm.GetTransactionFeeEstimated(owner, rawHex)
+	if err != nil {
+		return err
+	}
+	balance, _ := decimal.NewFromString(addrBalance.Balance)
+	if balance.LessThan(spend.Add(feeInfo.Fee)) {
+		return openwallet.Errorf(openwallet.ErrInsufficientBalanceOfAddress, "the balance: %s of address: %s is not enough", addrBalance.Balance, owner)
+	}
+
+	rawTx.To = map[string]string{owner: amount}
+	createTxErr := decoder.createRawTransactionParts(wrapper, rawTx, []*RawTxPart{
+		{
+			To:      owner,
+			Amount:  amount,
+			rawHex:  rawHex,
+			balance: &AddrBalance{Address: owner, TronBalance: big.NewInt(0)},
+			feeInfo: feeInfo,
+		},
+	})
+	if createTxErr != nil {
+		return createTxErr
+	}
+	return nil
+}
+
+func (decoder *TransactionDecoder) CreateRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
+	decoder.wm.senderMu.Lock()
+	defer decoder.wm.senderMu.Unlock()
+	return decoder.buildRawTransaction(wrapper, rawTx)
+}
+
+//buildRawTransaction 按交易类型创建交易单，调用方需持有senderMu
+func (decoder *TransactionDecoder) buildRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
+	name := rawTx.GetExtParam().Get(contractTypeExtKey).String()
+	switch name {
+	case core.Transaction_Contract_AccountCreateContract.String():
+		return decoder.CreateAccountCreateRawTransaction(wrapper, rawTx)
+	case core.Transaction_Contract_CreateSmartContract.String():
+		param, err := deployContractParamFromExt(rawTx.GetExtParam(), decoder.wm.Decimal(), decoder.wm.Config.IsTestNet)
+		if err != nil {
+			return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "%v", err)
+		}
+		return decoder.CreateDeployContractRawTransaction(wrapper, rawTx, param)
+	case core.Transaction_Contract_FreezeBalanceV2Contract.String(),
+		core.Transaction_Contract_UnfreezeBalanceV2Contract.String(),
+		core.Transaction_Contract_WithdrawExpireUnfreezeContract.String(),
+		core.Transaction_Contract_CancelAllUnfreezeV2Contract.String(),
+		core.Transaction_Contract_VoteWitnessContract.String(),
+		core.Transaction_Contract_WithdrawBalanceContract.String():
+		contractType := core.Transaction_Contract_ContractType(core.Transaction_Contract_ContractType_value[name])
+		return decoder.CreateStakeRawTransaction(wrapper, rawTx, contractType)
+	case "":
+	default:
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "unsupported contract type: %s", name)
+	}
+	if !rawTx.Coin.IsContract {
+		return decoder.CreateSimpleTransaction(wrapper, rawTx)
+	} else {
+		return decoder.CreateTokenTransaction(wrapper, rawTx)
+	}
+	//contract To Do
+}
+
+//SignRawTransaction 签名交易单
+func (decoder *TransactionDecoder) SignRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
+
+	if rawTx.Signatures == nil || len(rawTx.Signatures) == 0 {
+		//this.wm.Log.Std.Error("len of signatures error. ")
+		return fmt.Errorf("transaction signature is empty")
+	}
+
+	//签名前检查交易单内容与原始交易单一致
+	if err := decoder.verifyRawTransactionIntent(rawTx); err != nil {
+		return openwallet.Errorf(openwallet.ErrSignRawTransactionFailed, "%v", err)
+	}
+
+	if err := decoder.wm.signKeySignatures(wrapper, rawTx.Signatures[rawTx.Account.AccountID]); err != nil {
+		return err
+	}
+	decoder.wm.Log.Info("Tx hash sign success")
+	return nil
+}
+
+//VerifyRawTransaction 验证交易单，验证交易单并返回加入签名后的交易单
+func (decoder *TransactionDecoder) VerifyRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) error {
+	//检测交易单基本字段
+	err := CheckRawTransaction(rawTx)
+	if err != nil {
+		decoder.wm.Log.Info("verify Tx base field failed;unexpected error:%v", err)
+		return err
+	}
+
+	if rawTx.Signatures == nil || len(rawTx.Signatures) == 0 {
+		//this.wm.Log.Std.Error("len of signatures error. ")
+		return fmt.Errorf("transaction signature is empty")
+	}
+
+	sig, exist := rawTx.Signatures[rawTx.Account.AccountID]
+	if !exist {
+		return fmt.Errorf("wallet signature not found")
+	}
+
+	if len(sig) == 0 {
+		//this.wm.Log.Std.Error("len of signatures error. ")
+		return fmt.Errorf("transaction signature is empty")
+	}
+
+	//每笔TRON交易对应一个签名
+	txHexArray := splitRawHex(rawTx.RawHex)
+	if len(sig) != len(txHexArray) {
+		return fmt.Errorf("transaction signature count: %d is not equal to transaction count: %d", len(sig), len(txHexArray))
+	}
+
+	if err := decoder.verifyRawTransactionIntent(rawTx); err != nil {
+		return openwallet.Errorf(openwallet.ErrVerifyRawTransactionFailed, "%v", err)
+	}
+
+	for i, txHex := range txHexArray {
+		mergeTxHex, err := InsertSignatureIntoRawTransaction(txHex, sig[i].Signature)
+		if err != nil {
+			decoder.wm.Log.Info("merge empty transaction and signature failed;unexpected error:%v", err)
+			return err
+		}
+		verifyRet := decoder.wm.ValidSignedTokenTransaction(mergeTxHex)
+		if verifyRet != nil {
+			decoder.wm.Log.Info("Tx signature verify failed;unexpected error:%v", verifyRet)
+			return fmt.Errorf("Tx signature verify failed")
+		}
+	}
+
+	rawTx.IsCompleted = true
+	//rawTx.RawHex = mergeTxHex
+	return nil
+}
+
+//SubmitRawTransaction 广播交易单
 func (decoder *TransactionDecoder) SubmitRawTransaction(wrapper openwallet.WalletDAI, rawTx *openwallet.RawTransaction) (*openwallet.Transaction, error) {
 
+	var (
+		signedHexArray = make([]string, 0)
+		txIDs          = make([]string, 0)
+		txFrom         = make([]string, 0)
+		txTo           = make([]string, 0)
+		submitErr      error
+	)
+
 	if len(rawTx.RawHex) == 0 {
 		return nil, fmt.Errorf("transaction hex is empty")
 	}
@@ -475,25 +930,93 @@ func (decoder *TransactionDecoder) SubmitRawTransaction(wrapper openwallet.Walle
 		return nil, fmt.Errorf("transaction signature is empty")
 	}
 
-	mergeTxHex, err := InsertSignatureIntoRawTransaction(rawTx.RawHex, sig[0].Signature)
+	txHexArray := splitRawHex(rawTx.RawHex)
+	if len(sig) != len(txHexArray) {
+		return nil, fmt.Errorf("transaction signature count: %d is not equal to transaction count: %d", len(sig), len(txHexArray))
+	}
+
+	//广播前检查交易单内容与原始交易单一致
+	if err := decoder.verifyRawTransactionIntent(rawTx); err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrSubmitRawTransactionFailed, "%v", err)
+	}
+
+	parts, err := GetRawTxParts(rawTx)
 	if err != nil {
-		decoder.wm.Log.Info("merge empty transaction and signature failed;unexpected error:%v", err)
 		return nil, err
 	}
+	if len(parts) != len(txHexArray) {
+		return nil, fmt.Errorf("transaction parts count: %d is not equal to transaction count: %d", len(parts), len(txHexArray))
+	}
+
+	for i, txHex := range txHexArray {
+		mergeTxHex, err := InsertSignatureIntoRawTransaction(txHex, sig[i].Signature)
+		if err != nil {
+			decoder.wm.Log.Info("merge empty transaction and signature failed;unexpected error:%v", err)
+			return nil, err
+		}
+		signedHexArray = append(signedHexArray, mergeTxHex)
+	}
 
-	rawTx.RawHex = mergeTxHex
+	dryRun := decoder.wm.Config.DryRun || rawTx.GetExtParam().Get("dryRun").Bool()
 	//********广播交易单********
-	txid, err := decoder.wm.BroadcastTransaction(rawTx.RawHex)
-	if err != nil {
-		decoder.wm.Log.Infof("submit transaction failed;unexpected error: %v", err)
-		return nil, err
+	for i, signedHex := range signedHexArray {
+		//确认发送地址已锁定，广播失败时解锁，广播成功后由交易跟踪器解锁或到有效期结束
+		lockedTxID, err := decoder.wm.lockSender(parts[i].From, txHexArray[i])
+		if err != nil {
+			return nil, err
+		}
+		parts[i].TxID = lockedTxID
+		parts[i].Error = ""
+		//模拟执行合约调用，预计revert则不广播
+		if dryRun {
+			if err := decoder.wm.DryRunTransaction(signedHex); err != nil {
+				decoder.wm.Log.Infof("dry run transaction failed;unexpected error: %v", err)
+				decoder.wm.AddressLocker.Unlock(lockedTxID)
+				parts[i].Error = err.Error()
+				if submitErr == nil {
+					submitErr = openwallet.Errorf(openwallet.ErrSubmitRawTransactionFailed, "%v", err)
+				}
+				continue
+			}
+		}
+		_, err = decoder.wm.BroadcastTransaction(signedHex)
+		if broadcastErr, ok := err.(*BroadcastError); ok {
+			if broadcastErr.IsResubmitted() {
+				//本方重复广播的交易已在节点交易池中
+				err = nil
+			} else {
+				err = broadcastErr.OWError()
+			}
+		}
+		if err != nil {
+			decoder.wm.Log.Infof("submit transaction failed;unexpected error: %v", err)
+			decoder.wm.AddressLocker.Unlock(lockedTxID)
+			parts[i].Error = err.Error()
+			if submitErr == nil {
+				submitErr = err
+			}
+			continue
+		}
+		decoder.wm.trackSubmitted(lockedTxID, signedHex)
+		txIDs = append(txIDs, lockedTxID)
+		txFrom = append(txFrom, fmt.Sprintf("%s:%s", parts[i].From, parts[i].Amount))
+		txTo = append(txTo, fmt.Sprintf("%s:%s", parts[i].To, parts[i].Amount))
 	}
-	rawTx.TxID = txid
+
+	//每笔交易的ID和广播结果记录在扩展参数txParts中
+	rawTx.SetExtParam(txPartsExtKey, parts)
+	if submitErr != nil {
+		return nil, submitPartsError(parts, submitErr)
+	}
+
+	//多笔交易时TxID为第一笔交易ID，全部交易ID见扩展参数txParts
+	rawTx.TxID = txIDs[0]
 	rawTx.IsSubmit = true
+
 	decimals := decoder.wm.Decimal()
 	tx := openwallet.Transaction{
-		From:       rawTx.TxFrom,
-		To:         rawTx.TxTo,
+		From:       txFrom,
+		To:         txTo,
 		Amount:     rawTx.TxAmount,
 		Coin:       rawTx.Coin,
 		TxID:       rawTx.TxID,
@@ -503,10 +1026,38 @@ func (decoder *TransactionDecoder) SubmitRawTransaction(wrapper openwallet.Walle
 		SubmitTime: time.Now().Unix(),
 		TxType:     0,
 	}
+	if len(parts) > 1 {
+		tx.SetExtParam(txPartsExtKey, parts)
+	}
+	if memo := rawTx.GetExtParam().Get("memo").String(); len(memo) > 0 {
+		tx.IsMemo = true
+		tx.Memo = memo
+		tx.SetExtParam("memo", memo)
+	}
 	tx.WxID = openwallet.GenTransactionWxID(&tx)
 	return &tx, nil
 }
 
+//submitPartsError 广播失败的错误。只有一笔交易时返回原错误，多笔交易时列出失败和已广播的交易ID，
+//重新提交时已广播的交易按重复交易处理
+func submitPartsError(parts []*RawTxPart, submitErr error) error {
+	if len(parts) == 1 {
+		return submitErr
+	}
+	failed := make([]string, 0, len(parts))
+	submitted := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if len(part.Error) > 0 {
+			failed = append(failed, fmt.Sprintf("%s(to: %s): %s", part.TxID, part.To, part.Error))
+		} else if len(part.TxID) > 0 {
+			submitted = append(submitted, part.TxID)
+		}
+	}
+	return openwallet.Errorf(openwallet.ConvertError(submitErr).Code(),
+		"%d of %d transactions failed: [%s], submitted: [%s]",
+		len(failed), len(parts), strings.Join(failed, "; "), strings.Join(submitted, ", "))
+}
+
 //CreateSummaryRawTransaction 创建汇总交易，返回原始交易单数组
 func (decoder *TransactionDecoder) CreateSummaryRawTransaction(wrapper openwallet.WalletDAI, sumRawTx *openwallet.SummaryRawTransaction) ([]*openwallet.RawTransaction, error) {
 	var (
@@ -543,11 +1094,15 @@ func (decoder *TransactionDecoder) CreateSummaryRawTransactionWithError(wrapper
 //CreateSimpleSummaryRawTransaction 创建主币汇总交易
 func (decoder *TransactionDecoder) CreateSimpleSummaryRawTransaction(wrapper openwallet.WalletDAI, sumRawTx *openwallet.SummaryRawTransaction) ([]*openwallet.RawTransactionWithError, error) {
 
+	decoder.wm.senderMu.Lock()
+	defer decoder.wm.senderMu.Unlock()
+
 	var (
 		rawTxArray      = make([]*openwallet.RawTransactionWithError, 0)
 		accountID       = sumRawTx.Account.AccountID
 		minTransfer     = common.StringNumToBigIntWithExp(sumRawTx.MinTransfer, Decimals)
 		retainedBalance = common.StringNumToBigIntWithExp(sumRawTx.RetainedBalance, Decimals)
+		txOpts          = NewTxOptions(sumRawTx.GetExtParam())
 	)
 
 	if minTransfer.Cmp(retainedBalance) < 0 {
@@ -581,6 +1136,11 @@ func (decoder *TransactionDecoder) CreateSimpleSummaryRawTransaction(wrapper ope
 		return nil, err
 	}
 
+	params, err := decoder.wm.GetChainParameters()
+	if err != nil {
+		return nil, err
+	}
+
 	for _, addrBalance := range addrBalanceArray {
 
 		//检查余额是否超过最低转账
@@ -589,39 +1149,17 @@ func (decoder *TransactionDecoder) CreateSimpleSummaryRawTransaction(wrapper ope
 		if addrBalance_BI.Cmp(minTransfer) < 0 || addrBalance_BI.Cmp(big.NewInt(0)) <= 0 {
 			continue
 		}
-		//计算汇总数量 = 余额 - 保留余额
-		sumAmount_BI := new(big.Int)
-		sumAmount_BI.Sub(addrBalance_BI, retainedBalance)
-
-		//目标地址不存在，总消耗要加0.1
-		if !exist {
-			newAccountCost := big.NewInt(CreateAccountCost)
-			sumAmount_BI.Sub(sumAmount_BI, newAccountCost)
-		}
-
-		sumAmount := common.BigIntToDecimals(sumAmount_BI, Decimals)
-
-		//创建空交易单
-		rawHex, createErr := decoder.wm.CreateTokenTransaction(sumRawTx.SummaryAddress,
-			addrBalance.Address, sumAmount.String(), sumRawTx.Coin.Contract)
+		//计算汇总数量 = 余额 - 保留余额 - 燃烧的手续费
+		sumAmount, rawHex, fee, createErr := decoder.exactSweepAmount(addrBalance.Address, sumRawTx.SummaryAddress,
+			new(big.Int).Sub(addrBalance_BI, retainedBalance), exist, params, txOpts)
 		if createErr != nil {
+			decoder.wm.Log.Std.Error("create summary transaction from[%v] -> to[%v] failed, err=%v", addrBalance.Address, sumRawTx.SummaryAddress, createErr)
 			return nil, createErr
 		}
-
-		//this.wm.Log.Debug("sumAmount:", sumAmount)
-		//计算手续费
-		fee, createErr := decoder.wm.GetTransactionFeeEstimated(addrBalance.Address, rawHex)
-		if createErr != nil {
-			decoder.wm.Log.Std.Error("GetTransactionFeeEstimated from[%v] -> to[%v] failed, err=%v", addrBalance.Address, sumRawTx.SummaryAddress, createErr)
-			return nil, createErr
+		if sumAmount.LessThanOrEqual(decimal.Zero) {
+			continue
 		}
 
-		//减去手续费
-		//sumAmount = sumAmount.Sub(fee.Fee)
-		//if sumAmount.LessThanOrEqual(decimal.Zero) {
-		//	continue
-		//}
-
 		decoder.wm.Log.Debugf("balance: %v", addrBalance.Balance)
 		decoder.wm.Log.Debugf("fees: %v", fee.Fee)
 		decoder.wm.Log.Debugf("sumAmount: %v", sumAmount)
@@ -656,13 +1194,58 @@ func (decoder *TransactionDecoder) CreateSimpleSummaryRawTransaction(wrapper ope
 	return rawTxArray, nil
 }
 
+//exactSweepAmount 计算TRX汇总的实际转账数量，available为可汇总的余额（单位SUN）。
+//手续费与交易长度有关，按转账数量重新创建交易单直到 转账数量 + 手续费 = available，
+//数量编码长度变化导致无法恰好相等时，取手续费足够的较小数量
+func (decoder *TransactionDecoder) exactSweepAmount(
+	from, to string,
+	available *big.Int,
+	toExist bool,
+	params map[string]int64,
+	txOpts *TxOptions) (decimal.Decimal, string, *txFeeInfo, error) {
+
+	accountNet, err := decoder.wm.GetAccountNet(from)
+	if err != nil {
+		return decimal.Zero, "", nil, err
+	}
+
+	build := func(amount int64) (string, error) {
+		return decoder.wm.CreateTokenTransactionWithOptions(to, from, decimal.New(amount, -Decimals).String(), openwallet.SmartContract{}, txOpts)
+	}
+	return sweepAmount(available.Int64(), accountNet, params, toExist, build)
+}
+
+//sweepAmount exactSweepAmount的计算过程，build按数量创建交易单
+func sweepAmount(available int64, accountNet *AccountNet, params map[string]int64, toExist bool, build func(amount int64) (string, error)) (decimal.Decimal, string, *txFeeInfo, error) {
+
+	amount := available
+	for amount > 0 {
+		rawHex, err := build(amount)
+		if err != nil {
+			return decimal.Zero, "", nil, err
+		}
+		fee := EstimateTransferBurn(accountNet, params, rawHex, toExist)
+		next := available - fee.Fee.Shift(Decimals).IntPart()
+		//数量不再减少，当前数量的手续费足够
+		if next >= amount {
+			return decimal.New(amount, -Decimals), rawHex, fee, nil
+		}
+		amount = next
+	}
+	return decimal.Zero, "", nil, nil
+}
+
 //CreateTokenSummaryRawTransaction 创建代币汇总交易
 func (decoder *TransactionDecoder) CreateTokenSummaryRawTransaction(wrapper openwallet.WalletDAI, sumRawTx *openwallet.SummaryRawTransaction) ([]*openwallet.RawTransactionWithError, error) {
 
+	decoder.wm.senderMu.Lock()
+	defer decoder.wm.senderMu.Unlock()
+
 	var (
 		rawTxArray         = make([]*openwallet.RawTransactionWithError, 0)
 		accountID          = sumRawTx.Account.AccountID
 		feesSupportAccount *openwallet.AssetsAccount
+		txOpts             = NewTxOptions(sumRawTx.GetExtParam())
 	)
 
 	tokenDecimals := int32(sumRawTx.Coin.Contract.Decimals)
@@ -680,6 +1263,11 @@ func (decoder *TransactionDecoder) CreateTokenSummaryRawTransaction(wrapper open
 	}
 
 	tokenProtocol := sumRawTx.Coin.Contract.Protocol
+	feesSupportStrategy := sumRawTx.GetExtParam().Get(feesSupportStrategyExtKey).String()
+	//手续费账户各地址本次已代理的质押数量
+	delegated := make(map[string]int64)
+	//TRC10计算手续费的链参数
+	var params map[string]int64
 
 	if minTransfer.Cmp(retainedBalance) < 0 {
 		return nil, fmt.Errorf("mini transfer amount must be greater than address retained balance")
@@ -712,6 +1300,11 @@ func (decoder *TransactionDecoder) CreateTokenSummaryRawTransaction(wrapper open
 		return nil, err
 	}
 
+	//通过已授权的spender汇总
+	if strings.EqualFold(tokenProtocol, TRC20) && sumRawTx.GetExtParam().Get(spenderExtKey).Exists() {
+		return decoder.createTRC20SpenderSummaryRawTransaction(wrapper, sumRawTx, addrBalanceArray, feesSupportAccount)
+	}
+
 	for _, addrBalance := range addrBalanceArray {
 
 		trxBalance := big.NewInt(0)
@@ -728,8 +1321,8 @@ func (decoder *TransactionDecoder) CreateTokenSummaryRawTransaction(wrapper open
 		sumAmount := common.BigIntToDecimals(sumAmount_BI, tokenDecimals)
 
 		//创建空交易单
-		rawHex, createErr := decoder.wm.CreateTokenTransaction(sumRawTx.SummaryAddress,
-			addrBalance.Balance.Address, sumAmount.String(), sumRawTx.Coin.Contract)
+		rawHex, createErr := decoder.wm.CreateTokenTransactionWithOptions(sumRawTx.SummaryAddress,
+			addrBalance.Balance.Address, sumAmount.String(), sumRawTx.Coin.Contract, txOpts)
 		if createErr != nil {
 			return nil, createErr
 		}
@@ -757,15 +1350,16 @@ func (decoder *TransactionDecoder) CreateTokenSummaryRawTransaction(wrapper open
 			trxBalance = common.StringNumToBigIntWithExp(addrTRXBalanceArray[0].Balance, decoder.wm.Decimal())
 		}
 
-		//目标地址不存在，总消耗要加0.1
-		if !exist {
-			newAccountCost := big.NewInt(CreateAccountCost)
-			trxBalance.Sub(trxBalance, newAccountCost)
-		}
 		makeFeesSupport := false
 		supportAddress := ""
+		supportFees := decimal.Zero
 		//TRC20，需要检查能量是否足够调用合约
 		if strings.EqualFold(tokenProtocol, TRC20) {
+			//目标地址不存在，总消耗要加0.1
+			if !exist {
+				newAccountCost := big.NewInt(CreateAccountCost)
+				trxBalance.Sub(trxBalance, newAccountCost)
+			}
 			//判断账户资源是否足够
 			isEnoughEnegry, energyRest, feeMini := decoder.wm.IsEnoughEnergyToTransferTRC20(addrBalance.Balance.Address, trxBalance)
 			if !isEnoughEnegry {
@@ -781,77 +1375,73 @@ func (decoder *TransactionDecoder) CreateTokenSummaryRawTransaction(wrapper open
 					continue
 				}
 
+				//手续费账户代理能量，按顺序创建代理、汇总交易单，汇总确认后再创建取回交易单
+				if feesSupportStrategy == FeesSupportStrategyDelegate {
+					rawTx := &openwallet.RawTransaction{
+						Coin:    sumRawTx.Coin,
+						Account: sumRawTx.Account,
+						To: map[string]string{
+							sumRawTx.SummaryAddress: sumAmount.StringFixed(tokenDecimals),
+						},
+						Required: 1,
+						RawHex:   rawHex,
+					}
+					rawTxArray = append(rawTxArray, decoder.createDelegateSummaryRawTransaction(
+						wrapper,
+						sumRawTx,
+						feesSupportAccount,
+						rawTx,
+						&AddrBalance{
+							Address:      addrBalance.Balance.Address,
+							TokenBalance: addrBalance_BI,
+							TronBalance:  trxBalance},
+						fee,
+						delegated,
+						txOpts)...)
+					continue
+				}
+
 				makeFeesSupport = true
 				supportAddress = addrBalance.Balance.Address
+				supportFees = decoder.trc20SupportFees()
 
 				decoder.wm.Log.Debugf("use fees support account: %s to recharge energy", feesSupportAccount.AccountID)
 			}
 
 		} else {
-			if trxBalance.Cmp(big.NewInt(0)) < 0 {
-				makeFeesSupport = true
-				supportAddress = sumRawTx.SummaryAddress
-				//重置为存在
-				exist = true
-
-				decoder.wm.Log.Debugf("use fees support account: %s to recharge summary address", feesSupportAccount.AccountID)
-			}
-		}
-
-		//是否构造手续费支持交易单
-		if makeFeesSupport {
-
-			//通过手续费账户创建交易单
-			feeMini := decoder.wm.Config.FeeMini
-			//supportAddress := addrBalance.Balance.Address
-			supportAmount := decimal.Zero
-			feesSupportScale, _ := decimal.NewFromString(sumRawTx.FeesSupportAccount.FeesSupportScale)
-			fixSupportAmount, _ := decimal.NewFromString(sumRawTx.FeesSupportAccount.FixSupportAmount)
-			//1 Energy = 140 SUN, 1 trx = 1000000 SUN, fees(trx) = Energy * 100000
-			fees := decimal.New(feeMini * 140, -decoder.wm.Decimal())
-
-			//优先采用固定支持数量
-			if fixSupportAmount.GreaterThan(decimal.Zero) {
-				supportAmount = fixSupportAmount
-			} else {
-				//没有固定支持数量，有手续费倍率，计算支持数量
-				if feesSupportScale.GreaterThan(decimal.Zero) {
-					supportAmount = feesSupportScale.Mul(fees)
-				} else {
-					//默认支持数量为手续费
-					supportAmount = fees
+			//TRC10，资产免费带宽和账户带宽都不足时燃烧TRX，接收地址未激活时还需支付激活费用
+			if params == nil {
+				if params, createErr = decoder.wm.GetChainParameters(); createErr != nil {
+					return nil, createErr
 				}
 			}
-
-			decoder.wm.Log.Debugf("create transaction for fees support account")
-			decoder.wm.Log.Debugf("fees account: %s", feesSupportAccount.AccountID)
-			decoder.wm.Log.Debugf("mini support amount: %s", fees.String())
-			decoder.wm.Log.Debugf("allow support amount: %s", supportAmount.String())
-			decoder.wm.Log.Debugf("support address: %s", supportAddress)
-
-			supportCoin := openwallet.Coin{
-				Symbol:     sumRawTx.Coin.Symbol,
-				IsContract: false,
+			fee, createErr = decoder.wm.GetTRC10TransactionFeeEstimated(addrBalance.Balance.Address, sumRawTx.Coin.Contract.Address, rawHex, exist, params)
+			if createErr != nil {
+				decoder.wm.Log.Std.Error("GetTRC10TransactionFeeEstimated from[%v] -> to[%v] failed, err=%v", addrBalance.Balance.Address, sumRawTx.SummaryAddress, createErr)
+				return nil, createErr
 			}
+			trxBalanceDec := decimal.NewFromBigInt(trxBalance, -decoder.wm.Decimal())
+			if trxBalanceDec.LessThan(fee.Fee) {
+				decoder.wm.Log.Debugf("address[%s] TRX balance: %s is less than fees: %s", addrBalance.Balance.Address, trxBalanceDec, fee.Fee)
+				//没有手续费账户支持，记录该交易单失败
+				if feesSupportAccount == nil {
+					rawTxArray = append(rawTxArray, &openwallet.RawTransactionWithError{
+						Error: openwallet.Errorf(openwallet.ErrInsufficientFees, "address[%s] TRX balance: %s is less than fees: %s", addrBalance.Balance.Address, trxBalanceDec, fee.Fee),
+					})
+					continue
+				}
 
-			//创建一笔交易单
-			rawTx := &openwallet.RawTransaction{
-				Coin:    supportCoin,
-				Account: feesSupportAccount,
-				To: map[string]string{
-					supportAddress: supportAmount.String(),
-				},
-				Required: 1,
-			}
+				makeFeesSupport = true
+				supportAddress = addrBalance.Balance.Address
+				supportFees = fee.Fee.Sub(trxBalanceDec)
 
-			createTxErr := decoder.CreateRawTransaction(wrapper, rawTx)
-			rawTxWithErr := &openwallet.RawTransactionWithError{
-				RawTx: rawTx,
-				Error: openwallet.ConvertError(createTxErr),
+				decoder.wm.Log.Debugf("use fees support account: %s to recharge bandwidth fees", feesSupportAccount.AccountID)
 			}
+		}
 
-			//创建成功，添加到队列
-			rawTxArray = append(rawTxArray, rawTxWithErr)
+		//是否构造手续费支持交易单
+		if makeFeesSupport {
+			rawTxArray = append(rawTxArray, decoder.createFeesSupportRawTransaction(wrapper, sumRawTx, feesSupportAccount, supportAddress, supportFees))
 
 			//汇总下一个
 			continue
@@ -894,90 +1484,895 @@ func (decoder *TransactionDecoder) CreateTokenSummaryRawTransaction(wrapper open
 	return rawTxArray, nil
 }
 
-//createRawTransaction
-func (decoder *TransactionDecoder) createRawTransaction(
+//createFeesSupportRawTransaction 通过手续费账户给地址充值TRX，用于支付能量、带宽或激活费用，fees为需要补充的最少数量
+func (decoder *TransactionDecoder) createFeesSupportRawTransaction(
 	wrapper openwallet.WalletDAI,
-	rawTx *openwallet.RawTransaction,
-	addrBalance *AddrBalance,
-	feeInfo *txFeeInfo,
-	callData string) *openwallet.Error {
+	sumRawTx *openwallet.SummaryRawTransaction,
+	feesSupportAccount *openwallet.AssetsAccount,
+	supportAddress string,
+	fees decimal.Decimal) *openwallet.RawTransactionWithError {
+
+	//通过手续费账户创建交易单
+	supportAmount := decimal.Zero
+	feesSupportScale, _ := decimal.NewFromString(sumRawTx.FeesSupportAccount.FeesSupportScale)
+	fixSupportAmount, _ := decimal.NewFromString(sumRawTx.FeesSupportAccount.FixSupportAmount)
+
+	//优先采用固定支持数量
+	if fixSupportAmount.GreaterThan(decimal.Zero) {
+		supportAmount = fixSupportAmount
+	} else {
+		//没有固定支持数量，有手续费倍率，计算支持数量
+		if feesSupportScale.GreaterThan(decimal.Zero) {
+			supportAmount = feesSupportScale.Mul(fees)
+		} else {
+			//默认支持数量为手续费
+			supportAmount = fees
+		}
+	}
+	//支持数量不能少于手续费
+	if supportAmount.LessThan(fees) {
+		supportAmount = fees
+	}
 
-	var (
-		accountTotalSent = decimal.Zero
-		txFrom           = make([]string, 0)
-		txTo             = make([]string, 0)
-		keySignList      = make([]*openwallet.KeySignature, 0)
-		amountStr        string
-		destination      string
-		rawHex           string
-	)
+	decoder.wm.Log.Debugf("create transaction for fees support account")
+	decoder.wm.Log.Debugf("fees account: %s", feesSupportAccount.AccountID)
+	decoder.wm.Log.Debugf("mini support amount: %s", fees.String())
+	decoder.wm.Log.Debugf("allow support amount: %s", supportAmount.String())
+	decoder.wm.Log.Debugf("support address: %s", supportAddress)
 
-	decimals := int32(0)
-	if rawTx.Coin.IsContract {
-		decimals = int32(rawTx.Coin.Contract.Decimals)
-	} else {
-		decimals = decoder.wm.Decimal()
+	supportCoin := openwallet.Coin{
+		Symbol:     sumRawTx.Coin.Symbol,
+		IsContract: false,
 	}
-	//isContract := rawTx.Coin.IsContract
-	//contractAddress := rawTx.Coin.Contract.Address
-	//tokenCoin := rawTx.Coin.Contract.Token
-	//tokenDecimals := int(rawTx.Coin.Contract.Decimals)
-	//coinDecimals := this.wm.Decimal()
 
-	for k, v := range rawTx.To {
-		destination = k
-		amountStr = v
-		break
+	//创建一笔交易单
+	rawTx := &openwallet.RawTransaction{
+		Coin:    supportCoin,
+		Account: feesSupportAccount,
+		To: map[string]string{
+			supportAddress: supportAmount.String(),
+		},
+		Required: 1,
 	}
 
-	//计算账户的实际转账amount
-	accountTotalSentAddresses, findErr := wrapper.GetAddressList(0, -1, "AccountID", rawTx.Account.AccountID, "Address", destination)
-	if findErr != nil || len(accountTotalSentAddresses) == 0 {
-		amountDec, _ := decimal.NewFromString(amountStr)
-		accountTotalSent = accountTotalSent.Add(amountDec)
+	createTxErr := decoder.buildRawTransaction(wrapper, rawTx)
+	return &openwallet.RawTransactionWithError{
+		RawTx: rawTx,
+		Error: openwallet.ConvertError(createTxErr),
 	}
+}
 
-	txFrom = []string{fmt.Sprintf("%s:%s", addrBalance.Address, amountStr)}
-	txTo = []string{fmt.Sprintf("%s:%s", destination, amountStr)}
+//createDelegateSummaryRawTransaction 手续费账户代理能量给汇总地址，返回按顺序广播的代理、汇总交易单，
+//任一步骤失败只返回一个失败记录。取回交易单在汇总确认后创建，避免在汇总确认前过期
+func (decoder *TransactionDecoder) createDelegateSummaryRawTransaction(
+	wrapper openwallet.WalletDAI,
+	sumRawTx *openwallet.SummaryRawTransaction,
+	feesSupportAccount *openwallet.AssetsAccount,
+	summaryRawTx *openwallet.RawTransaction,
+	addrBalance *AddrBalance,
+	fee *txFeeInfo,
+	delegated map[string]int64,
+	txOpts *TxOptions) []*openwallet.RawTransactionWithError {
+
+	var (
+		address   = addrBalance.Address
+		resource  = core.ResourceCode_ENERGY
+		failedTxs = func(err error) []*openwallet.RawTransactionWithError {
+			return []*openwallet.RawTransactionWithError{{Error: openwallet.ConvertError(err)}}
+		}
+	)
+
+	//能量不足部分按全网质押比例换算为代理数量
+	res, err := decoder.wm.GetAccountResource(address)
+	if err != nil {
+		return failedTxs(err)
+	}
+	energy := decoder.wm.Config.FeeMini - (res.EnergyLimit - res.EnergyUsed)
+	balance, err := EnergyDelegateBalance(energy, res)
+	if err != nil {
+		return failedTxs(err)
+	}
 
-	addr, err := wrapper.GetAddress(addrBalance.Address)
+	delegator, err := decoder.selectDelegateAddress(wrapper, feesSupportAccount, balance, resource, delegated)
 	if err != nil {
-		return openwallet.ConvertError(err)
+		return failedTxs(err)
 	}
+	amount := decimal.New(balance, -decoder.wm.Decimal()).String()
 
-	rawHex = rawTx.RawHex
+	decoder.wm.Log.Debugf("fees account: %s delegate %s TRX of energy from %s to %s", feesSupportAccount.AccountID, amount, delegator, address)
 
-	txHashBytes, err := getTxHash1(rawHex)
+	delegateHex, err := decoder.wm.CreateDelegateResourceTransaction(delegator, address, amount, resource, false, 0, txOpts)
+	if err != nil {
+		return failedTxs(err)
+	}
+	delegateRawTx, err := decoder.createResourceRawTransaction(wrapper, feesSupportAccount, delegator, address, amount,
+		resource, core.Transaction_Contract_DelegateResourceContract, delegateHex)
 	if err != nil {
-		decoder.wm.Log.Info("get Tx hash failed;unexpected error:%v", err)
-		return openwallet.ConvertError(err)
+		return failedTxs(err)
 	}
-	txHash := hex.EncodeToString(txHashBytes)
 
-	if rawTx.Signatures == nil {
-		rawTx.Signatures = make(map[string][]*openwallet.KeySignature)
+	summaryRawTx.SetExtParam(summaryStepExtKey, SummaryStepSummary)
+	if createErr := decoder.createRawTransaction(wrapper, summaryRawTx, addrBalance, fee, ""); createErr != nil {
+		decoder.wm.releaseRawTransaction(delegateRawTx)
+		return failedTxs(createErr)
 	}
+	delegated[delegator] += balance
 
-	signature := openwallet.KeySignature{
-		EccType: decoder.wm.Config.CurveType,
-		Address: addr,
-		Message: txHash,
-		RSV:     true,
+	return []*openwallet.RawTransactionWithError{
+		{RawTx: delegateRawTx},
+		{RawTx: summaryRawTx},
 	}
-	keySignList = append(keySignList, &signature)
+}
 
-	feesDec, _ := decimal.NewFromString(rawTx.Fees)
-	accountTotalSent = accountTotalSent.Add(feesDec)
-	accountTotalSent = decimal.Zero.Sub(accountTotalSent)
+//CreateUndelegateRawTransaction 汇总交易确认后，根据代理步骤的交易单创建取回代理能量的交易单，
+//使用新的参考区块和有效期，取回交易单过期未上链时可再次调用重新创建
+func (decoder *TransactionDecoder) CreateUndelegateRawTransaction(wrapper openwallet.WalletDAI, delegateRawTx *openwallet.RawTransaction) (*openwallet.RawTransaction, error) {
 
-	//rawTx.RawHex = rawHex
-	rawTx.Signatures[rawTx.Account.AccountID] = keySignList
-	rawTx.FeeRate = feeInfo.GasPrice.String()
-	rawTx.Fees = feeInfo.Fee.String()
-	rawTx.IsBuilt = true
-	rawTx.TxAmount = accountTotalSent.StringFixed(decimals)
-	rawTx.TxFrom = txFrom
-	rawTx.TxTo = txTo
+	decoder.wm.senderMu.Lock()
+	defer decoder.wm.senderMu.Unlock()
 
+	ext := delegateRawTx.GetExtParam()
+	if ext.Get(contractTypeExtKey).String() != core.Transaction_Contract_DelegateResourceContract.String() ||
+		ext.Get(summaryStepExtKey).String() != SummaryStepDelegate {
+		return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "raw transaction is not the delegate step of summary")
+	}
+	value, ok := core.ResourceCode_value[ext.Get(resourceExtKey).String()]
+	if !ok {
+		return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "resource: %s is not supported", ext.Get(resourceExtKey).String())
+	}
+	parts, err := GetRawTxParts(delegateRawTx)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) != 1 {
+		return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "delegate raw transaction should have one transaction")
+	}
+	delegator, address, amount := parts[0].From, parts[0].To, parts[0].Amount
+
+	undelegateHex, err := decoder.wm.CreateUnDelegateResourceTransaction(delegator, address, amount, core.ResourceCode(value), NewTxOptions(ext))
+	if err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "%v", err)
+	}
+	return decoder.createResourceRawTransaction(wrapper, delegateRawTx.Account, delegator, address, amount,
+		core.ResourceCode(value), core.Transaction_Contract_UnDelegateResourceContract, undelegateHex)
+}
+
+//createResourceRawTransaction 创建手续费账户代理或取回能量的交易单
+func (decoder *TransactionDecoder) createResourceRawTransaction(
+	wrapper openwallet.WalletDAI,
+	feesSupportAccount *openwallet.AssetsAccount,
+	delegator, address, amount string,
+	resource core.ResourceCode,
+	contractType core.Transaction_Contract_ContractType,
+	rawHex string) (*openwallet.RawTransaction, error) {
+
+	step := SummaryStepDelegate
+	if contractType == core.Transaction_Contract_UnDelegateResourceContract {
+		step = SummaryStepUndelegate
+	}
+
+	stepFee, err := decoder.wm.GetTransactionFeeEstimated(delegator, rawHex)
+	if err != nil {
+		return nil, err
+	}
+	rawTx := &openwallet.RawTransaction{
+		Coin: openwallet.Coin{
+			Symbol:     decoder.wm.Symbol(),
+			IsContract: false,
+		},
+		Account: feesSupportAccount,
+		To: map[string]string{
+			address: amount,
+		},
+		Required: 1,
+		RawHex:   rawHex,
+	}
+	rawTx.SetExtParam(contractTypeExtKey, contractType.String())
+	rawTx.SetExtParam(resourceExtKey, resource.String())
+	rawTx.SetExtParam(summaryStepExtKey, step)
+	if createErr := decoder.createRawTransaction(wrapper, rawTx, &AddrBalance{Address: delegator}, stepFee, ""); createErr != nil {
+		return nil, createErr
+	}
+	return rawTx, nil
+}
+
+
+//selectDelegateAddress 选择手续费账户中可代理质押数量足够的地址，扣除本次汇总已代理的数量
+func (decoder *TransactionDecoder) selectDelegateAddress(
+	wrapper openwallet.WalletDAI,
+	feesSupportAccount *openwallet.AssetsAccount,
+	balance int64,
+	resource core.ResourceCode,
+	delegated map[string]int64) (string, error) {
+
+	addresses, err := wrapper.GetAddressList(0, -1, "AccountID", feesSupportAccount.AccountID)
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addresses {
+		maxSize, err := decoder.wm.GetCanDelegatedMaxSize(addr.Address, resource)
+		if err != nil {
+			return "", err
+		}
+		if maxSize-delegated[addr.Address] >= balance {
+			return addr.Address, nil
+		}
+	}
+	return "", openwallet.Errorf(openwallet.ErrInsufficientFees, "fees support account: %s can not delegate %s TRX of %s",
+		feesSupportAccount.AccountID, decimal.New(balance, -decoder.wm.Decimal()).String(), resource.String())
+}
+
+//trc20SupportFees 调用TRC20合约最少需要的手续费，1 Energy = 140 SUN
+func (decoder *TransactionDecoder) trc20SupportFees() decimal.Decimal {
+	return decimal.New(decoder.wm.Config.FeeMini*140, -decoder.wm.Decimal())
+}
+
+//createTRC20SpenderSummaryRawTransaction 通过已授权的spender调用transferFrom汇总代币，能量由spender支付。
+//授权不足的地址先创建approve交易单，授权数量为uint256最大值。
+//spender通过汇总扩展参数设置：
+//	{
+//		"spender": {
+//			"accountID": "spender所属资产账户",
+//			"address": "spender地址"
+//		}
+//	}
+func (decoder *TransactionDecoder) createTRC20SpenderSummaryRawTransaction(
+	wrapper openwallet.WalletDAI,
+	sumRawTx *openwallet.SummaryRawTransaction,
+	addrBalanceArray []*openwallet.TokenBalance,
+	feesSupportAccount *openwallet.AssetsAccount) ([]*openwallet.RawTransactionWithError, error) {
+
+	var (
+		rawTxArray      = make([]*openwallet.RawTransactionWithError, 0)
+		txOpts          = NewTxOptions(sumRawTx.GetExtParam())
+		contract        = sumRawTx.Coin.Contract
+		tokenDecimals   = int32(contract.Decimals)
+		minTransfer     = common.StringNumToBigIntWithExp(sumRawTx.MinTransfer, tokenDecimals)
+		retainedBalance = common.StringNumToBigIntWithExp(sumRawTx.RetainedBalance, tokenDecimals)
+		spender         = sumRawTx.GetExtParam().Get(spenderExtKey)
+		spenderAddress  = spender.Get("address").String()
+		spenderTxCount  = int64(0)
+	)
+
+	spenderAccount, err := wrapper.GetAssetsAccountInfo(spender.Get("accountID").String())
+	if err != nil {
+		return nil, openwallet.Errorf(openwallet.ErrAccountNotFound, "can not find spender account")
+	}
+
+	for _, addrBalance := range addrBalanceArray {
+
+		address := addrBalance.Balance.Address
+
+		//检查余额是否超过最低转账
+		addrBalance_BI := common.StringNumToBigIntWithExp(addrBalance.Balance.Balance, tokenDecimals)
+		if addrBalance_BI.Cmp(minTransfer) < 0 || addrBalance_BI.Cmp(big.NewInt(0)) <= 0 {
+			continue
+		}
+		//计算汇总数量 = 余额 - 保留余额
+		sumAmount_BI := new(big.Int).Sub(addrBalance_BI, retainedBalance)
+		sumAmount := common.BigIntToDecimals(sumAmount_BI, tokenDecimals)
+
+		//授权不足，先创建approve交易单
+		allowance, err := decoder.wm.GetTRC20Allowance(address, spenderAddress, contract.Address)
+		if err != nil {
+			return nil, err
+		}
+		if allowance.Cmp(sumAmount_BI) < 0 {
+			rawTxArray = append(rawTxArray, decoder.createTRC20ApproveRawTransaction(wrapper, sumRawTx, address, spenderAddress, feesSupportAccount, txOpts))
+			continue
+		}
+
+		//创建空交易单
+		rawHex, err := decoder.wm.CreateTRC20TransferFromTransaction(spenderAddress, address, sumRawTx.SummaryAddress, sumAmount.String(), contract, txOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		//计算手续费
+		fee, err := decoder.wm.GetTransactionFeeEstimated(spenderAddress, rawHex)
+		if err != nil {
+			decoder.wm.Log.Std.Error("GetTransactionFeeEstimated from[%v] -> to[%v] failed, err=%v", spenderAddress, sumRawTx.SummaryAddress, err)
+			return nil, err
+		}
+
+		//spender发起多笔transferFrom，能量需满足全部交易
+		trxBalance := big.NewInt(0)
+		addrTRXBalanceArray, err := decoder.wm.Blockscanner.GetBalanceByAddress(spenderAddress)
+		if err != nil {
+			return nil, err
+		}
+		if len(addrTRXBalanceArray) > 0 {
+			trxBalance = common.StringNumToBigIntWithExp(addrTRXBalanceArray[0].Balance, decoder.wm.Decimal())
+		}
+		isEnoughEnegry, energyRest, feeMini := decoder.wm.IsEnoughEnergyToTransferTRC20(spenderAddress, new(big.Int).Set(trxBalance))
+		if !isEnoughEnegry || energyRest < feeMini*(spenderTxCount+1) {
+			rawTxArray = append(rawTxArray, &openwallet.RawTransactionWithError{
+				Error: openwallet.Errorf(openwallet.ErrInsufficientFees, "spender[%s] available energy: %d is less than feeMini: %d", spenderAddress, energyRest, feeMini*(spenderTxCount+1)),
+			})
+			continue
+		}
+		spenderTxCount++
+
+		//创建一笔交易单，由spender签名
+		rawTx := &openwallet.RawTransaction{
+			Coin:    sumRawTx.Coin,
+			Account: spenderAccount,
+			To: map[string]string{
+				sumRawTx.SummaryAddress: sumAmount.StringFixed(tokenDecimals),
+			},
+			Required: 1,
+			RawHex:   rawHex,
+		}
+		rawTx.SetExtParam(trc20MethodExtKey, trc20Methods[TRC20_TRANSFER_FROM_METHOD_ID].name)
+		rawTx.SetExtParam(tokenFromExtKey, address)
+
+		createTxErr := decoder.createRawTransaction(
+			wrapper,
+			rawTx,
+			&AddrBalance{
+				Address:      spenderAddress,
+				TokenBalance: sumAmount_BI,
+				TronBalance:  trxBalance},
+			fee,
+			"")
+		rawTxArray = append(rawTxArray, &openwallet.RawTransactionWithError{
+			RawTx: rawTx,
+			Error: createTxErr,
+		})
+	}
+
+	return rawTxArray, nil
+}
+
+//createTRC20ApproveRawTransaction 创建授权spender的approve交易单，能量不足时通过手续费账户充值
+func (decoder *TransactionDecoder) createTRC20ApproveRawTransaction(
+	wrapper openwallet.WalletDAI,
+	sumRawTx *openwallet.SummaryRawTransaction,
+	ownerAddress, spenderAddress string,
+	feesSupportAccount *openwallet.AssetsAccount,
+	txOpts *TxOptions) *openwallet.RawTransactionWithError {
+
+	contract := sumRawTx.Coin.Contract
+	tokenDecimals := int32(contract.Decimals)
+
+	rawHex, err := decoder.wm.CreateTRC20ApproveTransaction(ownerAddress, spenderAddress, TRC20UnlimitedAllowance, contract, txOpts)
+	if err != nil {
+		return &openwallet.RawTransactionWithError{Error: openwallet.ConvertError(err)}
+	}
+
+	fee, err := decoder.wm.GetTransactionFeeEstimated(ownerAddress, rawHex)
+	if err != nil {
+		return &openwallet.RawTransactionWithError{Error: openwallet.ConvertError(err)}
+	}
+
+	trxBalance := big.NewInt(0)
+	addrTRXBalanceArray, err := decoder.wm.Blockscanner.GetBalanceByAddress(ownerAddress)
+	if err != nil {
+		return &openwallet.RawTransactionWithError{Error: openwallet.ConvertError(err)}
+	}
+	if len(addrTRXBalanceArray) > 0 {
+		trxBalance = common.StringNumToBigIntWithExp(addrTRXBalanceArray[0].Balance, decoder.wm.Decimal())
+	}
+
+	isEnoughEnegry, energyRest, feeMini := decoder.wm.IsEnoughEnergyToTransferTRC20(ownerAddress, new(big.Int).Set(trxBalance))
+	if !isEnoughEnegry {
+		decoder.wm.Log.Debugf("address[%s] available energy: %d is less than feeMini: %d", ownerAddress, energyRest, feeMini)
+		if feesSupportAccount == nil {
+			return &openwallet.RawTransactionWithError{
+				Error: openwallet.Errorf(openwallet.ErrInsufficientFees, "address[%s] available energy: %d is less than feeMini: %d", ownerAddress, energyRest, feeMini),
+			}
+		}
+		return decoder.createFeesSupportRawTransaction(wrapper, sumRawTx, feesSupportAccount, ownerAddress, decoder.trc20SupportFees())
+	}
+
+	allowance := common.BigIntToDecimals(trc20Allowance(TRC20UnlimitedAllowance, tokenDecimals), tokenDecimals)
+	rawTx := &openwallet.RawTransaction{
+		Coin:    sumRawTx.Coin,
+		Account: sumRawTx.Account,
+		To: map[string]string{
+			spenderAddress: allowance.StringFixed(tokenDecimals),
+		},
+		Required: 1,
+		RawHex:   rawHex,
+	}
+	rawTx.SetExtParam(trc20MethodExtKey, trc20Methods[TRC20_APPROVE_METHOD_ID].name)
+
+	createTxErr := decoder.createRawTransaction(
+		wrapper,
+		rawTx,
+		&AddrBalance{Address: ownerAddress, TronBalance: trxBalance},
+		fee,
+		"")
+	return &openwallet.RawTransactionWithError{
+		RawTx: rawTx,
+		Error: createTxErr,
+	}
+}
+
+//createRawTransaction
+func (decoder *TransactionDecoder) createRawTransaction(
+	wrapper openwallet.WalletDAI,
+	rawTx *openwallet.RawTransaction,
+	addrBalance *AddrBalance,
+	feeInfo *txFeeInfo,
+	callData string) *openwallet.Error {
+
+	var (
+		amountStr   string
+		destination string
+	)
+
+	for k, v := range rawTx.To {
+		destination = k
+		amountStr = v
+		break
+	}
+
+	return decoder.createRawTransactionParts(wrapper, rawTx, []*RawTxPart{
+		{
+			To:      destination,
+			Amount:  amountStr,
+			rawHex:  rawTx.RawHex,
+			balance: addrBalance,
+			feeInfo: feeInfo,
+		},
+	})
+}
+
+//createRawTransactionParts 把一笔或多笔TRON交易合并为一个原始交易单，每笔交易对应一个待签名信息
+func (decoder *TransactionDecoder) createRawTransactionParts(
+	wrapper openwallet.WalletDAI,
+	rawTx *openwallet.RawTransaction,
+	parts []*RawTxPart) *openwallet.Error {
+
+	var (
+		txFrom      = make([]string, 0)
+		txTo        = make([]string, 0)
+		keySignList = make([]*openwallet.KeySignature, 0)
+		rawHexArray = make([]string, 0)
+		totalFees   = decimal.Zero
+	)
+
+	if len(parts) == 0 {
+		return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "transaction is empty")
+	}
+
+	for _, part := range parts {
+
+		part.From = part.balance.Address
+		part.Fees = part.feeInfo.Fee.String()
+
+		txFrom = append(txFrom, fmt.Sprintf("%s:%s", part.From, part.Amount))
+		txTo = append(txTo, fmt.Sprintf("%s:%s", part.To, part.Amount))
+
+		addr, err := wrapper.GetAddress(part.From)
+		if err != nil {
+			return openwallet.ConvertError(err)
+		}
+
+		txHashBytes, err := getTxHash1(part.rawHex)
+		if err != nil {
+			decoder.wm.Log.Info("get Tx hash failed;unexpected error:%v", err)
+			return openwallet.ConvertError(err)
+		}
+		txHash := hex.EncodeToString(txHashBytes)
+
+		signature := openwallet.KeySignature{
+			EccType: decoder.wm.Config.CurveType,
+			Address: addr,
+			Message: txHash,
+			RSV:     true,
+		}
+		keySignList = append(keySignList, &signature)
+		rawHexArray = append(rawHexArray, part.rawHex)
+		totalFees = totalFees.Add(part.feeInfo.Fee)
+	}
+
+	//锁定选中的发送地址，交易完成、交易单被放弃或超过有效期前不再被选择
+	lockedTxIDs := make([]string, 0, len(parts))
+	for _, part := range parts {
+		txID, err := decoder.wm.lockSender(part.From, part.rawHex)
+		if err != nil {
+			for _, lockedTxID := range lockedTxIDs {
+				decoder.wm.AddressLocker.Unlock(lockedTxID)
+			}
+			return openwallet.Errorf(openwallet.ErrCreateRawTransactionFailed, "lock sender: %s failed: %v", part.From, err)
+		}
+		lockedTxIDs = append(lockedTxIDs, txID)
+	}
+
+	if rawTx.Signatures == nil {
+		rawTx.Signatures = make(map[string][]*openwallet.KeySignature)
+	}
+
+	//计算账户的实际转账amount
+	txAmount, _ := decoder.accountTotalSent(wrapper, rawTx, parts)
+
+	rawTx.RawHex = joinRawHex(rawHexArray)
+	rawTx.Signatures[rawTx.Account.AccountID] = keySignList
+	rawTx.FeeRate = parts[0].feeInfo.GasPrice.String()
+	rawTx.Fees = totalFees.String()
+	rawTx.IsBuilt = true
+	rawTx.TxAmount = txAmount
+	rawTx.TxFrom = txFrom
+	rawTx.TxTo = txTo
+	rawTx.SetExtParam(txPartsExtKey, parts)
+
+	return nil
+}
+
+//accountTotalSent 计算账户的实际转账数量（负数），接收地址属于本账户的不计入
+func (decoder *TransactionDecoder) accountTotalSent(
+	wrapper openwallet.WalletDAI,
+	rawTx *openwallet.RawTransaction,
+	parts []*RawTxPart) (string, string) {
+
+	var (
+		accountTotalSent = decimal.Zero
+		totalFees        = decimal.Zero
+	)
+
+	decimals := int32(0)
+	if rawTx.Coin.IsContract {
+		decimals = int32(rawTx.Coin.Contract.Decimals)
+	} else {
+		decimals = decoder.wm.Decimal()
+	}
+
+	//approve、transferFrom、质押和代理资源不转出本账户的资产，部署合约转出转入合约的TRX
+	method := rawTx.GetExtParam().Get(trc20MethodExtKey).String()
+	onlyFees := len(method) > 0 && method != trc20Methods[TRC20_TRANSFER_METHOD_ID].name
+	if contractType := rawTx.GetExtParam().Get(contractTypeExtKey); contractType.Exists() &&
+		contractType.String() != core.Transaction_Contract_CreateSmartContract.String() {
+		onlyFees = true
+	}
+
+	for _, part := range parts {
+		feesDec, _ := decimal.NewFromString(part.Fees)
+		totalFees = totalFees.Add(feesDec)
+		if onlyFees {
+			continue
+		}
+		accountTotalSentAddresses, findErr := wrapper.GetAddressList(0, -1, "AccountID", rawTx.Account.AccountID, "Address", part.To)
+		if findErr != nil || len(accountTotalSentAddresses) == 0 {
+			amountDec, _ := decimal.NewFromString(part.Amount)
+			accountTotalSent = accountTotalSent.Add(amountDec)
+		}
+	}
+
+	accountTotalSent = accountTotalSent.Add(totalFees)
+	accountTotalSent = decimal.Zero.Sub(accountTotalSent)
+
+	return accountTotalSent.StringFixed(decimals), totalFees.String()
+}
+
+//transferIntent 从交易单解析出的转账意图
+type transferIntent struct {
+	Owner        string
+	To           string
+	Amount       *big.Int
+	ContractType core.Transaction_Contract_ContractType
+	Token        string //TRC10为资产ID，TRC20为合约地址
+	TxID         string
+	Method       string //TRC20方法：transfer、transferFrom、approve
+	From         string //transferFrom的代币转出地址
+	Resource     string //代理的资源类型
+	Lock         bool   //代理资源是否锁定
+	Votes        map[string]int64
+}
+
+//trc20AddressWord 读取ABI编码的地址参数，高12字节必须为0，避免同一地址有多种编码通过验证
+func trc20AddressWord(word []byte) ([]byte, error) {
+	for _, b := range word[:12] {
+		if b != 0 {
+			return nil, fmt.Errorf("invalid TRC20 address argument: %x", word)
+		}
+	}
+	return word[12:32], nil
+}
+
+//decodeTransferIntent 解析交易单的转账意图，仅支持一个TransferContract、TransferAssetContract、TRC20调用或激活、质押、代理资源、投票、部署合约。
+//质押和投票类合约的接收地址为发送地址本身，部署合约的接收地址为预测的合约地址
+func decodeTransferIntent(txHex string, isTestnet bool) (*transferIntent, error) {
+
+	txBytes, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, err
+	}
+	tx := &core.Transaction{}
+	if err := proto.Unmarshal(txBytes, tx); err != nil {
+		return nil, err
+	}
+	contracts := tx.GetRawData().GetContract()
+	if len(contracts) != 1 {
+		return nil, fmt.Errorf("transaction should contain one contract, got %d", len(contracts))
+	}
+	txHash, err := getTxHash(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		c      = contracts[0]
+		value  = c.GetParameter().GetValue()
+		owner  []byte
+		to     []byte
+		intent = &transferIntent{ContractType: c.GetType(), TxID: hex.EncodeToString(txHash)}
+	)
+
+	switch c.GetType() {
+	case core.Transaction_Contract_TransferContract:
+		tc := &core.TransferContract{}
+		if err := proto.Unmarshal(value, tc); err != nil {
+			return nil, err
+		}
+		owner, to = tc.GetOwnerAddress(), tc.GetToAddress()
+		intent.Amount = big.NewInt(tc.GetAmount())
+	case core.Transaction_Contract_TransferAssetContract:
+		tc := &core.TransferAssetContract{}
+		if err := proto.Unmarshal(value, tc); err != nil {
+			return nil, err
+		}
+		owner, to = tc.GetOwnerAddress(), tc.GetToAddress()
+		intent.Amount = big.NewInt(tc.GetAmount())
+		intent.Token = string(tc.GetAssetName())
+	case core.Transaction_Contract_TriggerSmartContract:
+		tc := &core.TriggerSmartContract{}
+		if err := proto.Unmarshal(value, tc); err != nil {
+			return nil, err
+		}
+		if tc.GetCallValue() != 0 || tc.GetCallTokenValue() != 0 {
+			return nil, fmt.Errorf("TRC20 transfer should not carry call value")
+		}
+		data := tc.GetData()
+		if len(data) < 4 {
+			return nil, fmt.Errorf("contract call data is empty")
+		}
+		methodID, args := hex.EncodeToString(data[:4]), data[4:]
+		switch methodID {
+		case TRC20_TRANSFER_METHOD_ID, TRC20_APPROVE_METHOD_ID:
+			if len(args) != 32*2 {
+				return nil, fmt.Errorf("invalid TRC20 call data length")
+			}
+		case TRC20_TRANSFER_FROM_METHOD_ID:
+			if len(args) != 32*3 {
+				return nil, fmt.Errorf("invalid TRC20 call data length")
+			}
+			from, err := trc20AddressWord(args[:32])
+			if err != nil {
+				return nil, err
+			}
+			if intent.From, err = EncodeAddress(hex.EncodeToString(from), isTestnet); err != nil {
+				return nil, err
+			}
+			args = args[32:]
+		default:
+			return nil, fmt.Errorf("contract call is not a TRC20 transfer")
+		}
+		intent.Method = trc20Methods[methodID].name
+		owner = tc.GetOwnerAddress()
+		toAddress, err := trc20AddressWord(args[:32])
+		if err != nil {
+			return nil, err
+		}
+		to = append([]byte{0x41}, toAddress...)
+		intent.Amount = new(big.Int).SetBytes(args[32:64])
+		if intent.Token, err = EncodeAddress(hex.EncodeToString(tc.GetContractAddress()), isTestnet); err != nil {
+			return nil, err
+		}
+	case core.Transaction_Contract_CreateSmartContract:
+		tc := &core.CreateSmartContract{}
+		if err := proto.Unmarshal(value, tc); err != nil {
+			return nil, err
+		}
+		if tc.GetCallTokenValue() != 0 {
+			return nil, fmt.Errorf("contract deployment should not carry call token value")
+		}
+		owner = tc.GetOwnerAddress()
+		to = append([]byte{0x41}, generateContractAddress(txHash, owner)...)
+		intent.Amount = big.NewInt(tc.GetNewContract().GetCallValue())
+	case core.Transaction_Contract_FreezeBalanceV2Contract:
+		tc := &core.FreezeBalanceV2Contract{}
+		if err := proto.Unmarshal(value, tc); err != nil {
+			return nil, err
+		}
+		owner, to = tc.GetOwnerAddress(), tc.GetOwnerAddress()
+		intent.Amount = big.NewInt(tc.GetFrozenBalance())
+		intent.Resource = tc.GetResource().String()
+	case core.Transaction_Contract_UnfreezeBalanceV2Contract:
+		tc := &core.UnfreezeBalanceV2Contract{}
+		if err := proto.Unmarshal(value, tc); err != nil {
+			return nil, err
+		}
+		owner, to = tc.GetOwnerAddress(), tc.GetOwnerAddress()
+		intent.Amount = big.NewInt(tc.GetUnfreezeBalance())
+		intent.Resource = tc.GetResource().String()
+	case core.Transaction_Contract_WithdrawExpireUnfreezeContract:
+		tc := &core.WithdrawExpireUnfreezeContract{}
+		if err := proto.Unmarshal(value, tc); err != nil {
+			return nil, err
+		}
+		owner, to = tc.GetOwnerAddress(), tc.GetOwnerAddress()
+		intent.Amount = big.NewInt(0)
+	case core.Transaction_Contract_CancelAllUnfreezeV2Contract:
+		tc := &core.CancelAllUnfreezeV2Contract{}
+		if err := proto.Unmarshal(value, tc); err != nil {
+			return nil, err
+		}
+		owner, to = tc.GetOwnerAddress(), tc.GetOwnerAddress()
+		intent.Amount = big.NewInt(0)
+	case core.Transaction_Contract_VoteWitnessContract:
+		tc := &core.VoteWitnessContract{}
+		if err := proto.Unmarshal(value, tc); err != nil {
+			return nil, err
+		}
+		owner, to = tc.GetOwnerAddress(), tc.GetOwnerAddress()
+		//总票数按1票1 TRX计
+		intent.Amount = big.NewInt(0)
+		intent.Votes = make(map[string]int64)
+		for _, vote := range tc.GetVotes() {
+			witness, err := EncodeAddress(hex.EncodeToString(vote.GetVoteAddress()), isTestnet)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := intent.Votes[witness]; ok {
+				return nil, fmt.Errorf("duplicate vote witness: %s", witness)
+			}
+			intent.Votes[witness] = vote.GetVoteCount()
+			intent.Amount.Add(intent.Amount, new(big.Int).Mul(big.NewInt(vote.GetVoteCount()), big.NewInt(MinDelegateBalance)))
+		}
+	case core.Transaction_Contract_AccountCreateContract:
+		tc := &core.AccountCreateContract{}
+		if err := proto.Unmarshal(value, tc); err != nil {
+			return nil, err
+		}
+		owner, to = tc.GetOwnerAddress(), tc.GetAccountAddress()
+		intent.Amount = big.NewInt(0)
+	case core.Transaction_Contract_WithdrawBalanceContract:
+		tc := &core.WithdrawBalanceContract{}
+		if err := proto.Unmarshal(value, tc); err != nil {
+			return nil, err
+		}
+		owner, to = tc.GetOwnerAddress(), tc.GetOwnerAddress()
+		intent.Amount = big.NewInt(0)
+	case core.Transaction_Contract_DelegateResourceContract:
+		tc := &core.DelegateResourceContract{}
+		if err := proto.Unmarshal(value, tc); err != nil {
+			return nil, err
+		}
+		owner, to = tc.GetOwnerAddress(), tc.GetReceiverAddress()
+		intent.Amount = big.NewInt(tc.GetBalance())
+		intent.Resource = tc.GetResource().String()
+		intent.Lock = tc.GetLock()
+	case core.Transaction_Contract_UnDelegateResourceContract:
+		tc := &core.UnDelegateResourceContract{}
+		if err := proto.Unmarshal(value, tc); err != nil {
+			return nil, err
+		}
+		owner, to = tc.GetOwnerAddress(), tc.GetReceiverAddress()
+		intent.Amount = big.NewInt(tc.GetBalance())
+		intent.Resource = tc.GetResource().String()
+	default:
+		return nil, fmt.Errorf("unsupported contract type: %s", c.GetType().String())
+	}
+
+	if intent.Owner, err = EncodeAddress(hex.EncodeToString(owner), isTestnet); err != nil {
+		return nil, err
+	}
+	if intent.To, err = EncodeAddress(hex.EncodeToString(to), isTestnet); err != nil {
+		return nil, err
+	}
+	return intent, nil
+}
+
+//verifyRawTransactionIntent 检查交易单的接收地址、数量、币种和发送地址与原始交易单一致，防止交易单被篡改
+func (decoder *TransactionDecoder) verifyRawTransactionIntent(rawTx *openwallet.RawTransaction) error {
+
+	if err := CheckRawTransaction(rawTx); err != nil {
+		return err
+	}
+
+	sig := rawTx.Signatures[rawTx.Account.AccountID]
+	txHexArray := splitRawHex(rawTx.RawHex)
+	if len(txHexArray) != len(rawTx.To) {
+		return fmt.Errorf("transaction count: %d is not equal to receiver count: %d", len(txHexArray), len(rawTx.To))
+	}
+	if len(sig) != len(txHexArray) {
+		return fmt.Errorf("transaction signature count: %d is not equal to transaction count: %d", len(sig), len(txHexArray))
+	}
+
+	var (
+		decimals     = decoder.wm.Decimal()
+		contractType = core.Transaction_Contract_TransferContract
+		token        = ""
+		paid         = make(map[string]bool)
+		method       = ""
+		tokenFrom    = rawTx.GetExtParam().Get(tokenFromExtKey).String()
+		resource     = ""
+	)
+	if rawTx.Coin.IsContract {
+		decimals = int32(rawTx.Coin.Contract.Decimals)
+		token = rawTx.Coin.Contract.Address
+		if strings.EqualFold(rawTx.Coin.Contract.Protocol, TRC10) {
+			contractType = core.Transaction_Contract_TransferAssetContract
+		} else if strings.EqualFold(rawTx.Coin.Contract.Protocol, TRC20) {
+			contractType = core.Transaction_Contract_TriggerSmartContract
+			method = rawTx.GetExtParam().Get(trc20MethodExtKey).String()
+			if len(method) == 0 {
+				method = trc20Methods[TRC20_TRANSFER_METHOD_ID].name
+			}
+		} else {
+			return fmt.Errorf("unsupported token protocol: %s", rawTx.Coin.Contract.Protocol)
+		}
+	}
+	//激活、质押、代理资源、投票等非转账交易单，通过扩展参数指定合约类型
+	if name := rawTx.GetExtParam().Get(contractTypeExtKey).String(); len(name) > 0 {
+		value, ok := core.Transaction_Contract_ContractType_value[name]
+		if !ok || rawTx.Coin.IsContract {
+			return fmt.Errorf("unsupported contract type: %s", name)
+		}
+		contractType = core.Transaction_Contract_ContractType(value)
+		resource = rawTx.GetExtParam().Get(resourceExtKey).String()
+	}
+
+	for i, txHex := range txHexArray {
+		intent, err := decodeTransferIntent(txHex, decoder.wm.Config.IsTestNet)
+		if err != nil {
+			return err
+		}
+		if intent.ContractType != contractType {
+			return fmt.Errorf("transaction contract type: %s is not match coin: %s", intent.ContractType.String(), rawTx.Coin.Symbol)
+		}
+		if intent.Token != token {
+			return fmt.Errorf("transaction token: %s is not equal to: %s", intent.Token, token)
+		}
+		if intent.Method != method {
+			return fmt.Errorf("transaction method: %s is not equal to: %s", intent.Method, method)
+		}
+		if intent.From != tokenFrom {
+			return fmt.Errorf("transaction token sender: %s is not equal to: %s", intent.From, tokenFrom)
+		}
+		if intent.Resource != resource {
+			return fmt.Errorf("transaction resource: %s is not equal to: %s", intent.Resource, resource)
+		}
+		if intent.Lock {
+			return fmt.Errorf("transaction should not lock the delegated resource")
+		}
+		if err := verifyVotesIntent(intent.Votes, rawTx.GetExtParam().Get(votesExtKey)); err != nil {
+			return err
+		}
+		amount, ok := rawTx.To[intent.To]
+		if !ok || paid[intent.To] {
+			return fmt.Errorf("transaction receiver: %s is not expected", intent.To)
+		}
+		paid[intent.To] = true
+		expectAmount := common.StringNumToBigIntWithExp(amount, decimals)
+		if intent.Method == trc20Methods[TRC20_APPROVE_METHOD_ID].name {
+			expectAmount = trc20Allowance(amount, decimals)
+		}
+		if intent.Amount.Cmp(expectAmount) != 0 {
+			return fmt.Errorf("transaction amount: %s is not equal to: %s", intent.Amount.String(), amount)
+		}
+		if sig[i].Address == nil || intent.Owner != sig[i].Address.Address {
+			return fmt.Errorf("transaction owner: %s is not the signer", intent.Owner)
+		}
+		if sig[i].Message != intent.TxID {
+			return fmt.Errorf("transaction hash: %s is not equal to signature message: %s", intent.TxID, sig[i].Message)
+		}
+	}
+	return nil
+}
+
+//verifyVotesIntent 检查投票交易单的超级代表和票数与扩展参数一致
+func verifyVotesIntent(votes map[string]int64, expect gjson.Result) error {
+	expectVotes := expect.Map()
+	if len(votes) != len(expectVotes) {
+		return fmt.Errorf("transaction vote count: %d is not equal to: %d", len(votes), len(expectVotes))
+	}
+	for witness, count := range votes {
+		if v, ok := expectVotes[witness]; !ok || v.Int() != count {
+			return fmt.Errorf("transaction votes of witness: %s is not expected", witness)
+		}
+	}
 	return nil
 }
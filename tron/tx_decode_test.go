@@ -1,7 +1,15 @@
 package tron
 
 import (
+	"encoding/hex"
+	"math/big"
+	"strings"
 	"testing"
+
+	"github.com/blocktree/openwallet/v2/common"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+	"github.com/shopspring/decimal"
 )
 
 // var (
@@ -48,3 +56,286 @@ func testCreateRawTransaction(t *testing.T) {
 	// log.Info("rawTx:", rawTx)
 
 }
+
+func TestCheckRawTransaction(t *testing.T) {
+	rawTx := &openwallet.RawTransaction{
+		To: map[string]string{
+			"TSdXzXKSQ3RQzQ5Ge8TiYfMQEjofSVQ8ax": "0.1",
+			"TNQkiUv4qtDRKWDrKS628FTbDwxLMiqbAz": "0.2",
+		},
+	}
+	if err := CheckRawTransaction(rawTx); err != nil {
+		t.Errorf("CheckRawTransaction failed: %v", err)
+		return
+	}
+	rawTx.To = map[string]string{}
+	if err := CheckRawTransaction(rawTx); err == nil {
+		t.Errorf("CheckRawTransaction should fail without to address")
+		return
+	}
+}
+
+func TestGetRawTxParts(t *testing.T) {
+	recipients := sortedRecipients(map[string]string{
+		"TSdXzXKSQ3RQzQ5Ge8TiYfMQEjofSVQ8ax": "0.1",
+		"TNQkiUv4qtDRKWDrKS628FTbDwxLMiqbAz": "0.2",
+	})
+	if recipients[0] != "TNQkiUv4qtDRKWDrKS628FTbDwxLMiqbAz" {
+		t.Errorf("recipients is not sorted: %v", recipients)
+		return
+	}
+
+	txHexArray := splitRawHex(joinRawHex([]string{TXRAW, TXRAW}))
+	if len(txHexArray) != 2 || txHexArray[1] != TXRAW {
+		t.Errorf("split raw hex failed")
+		return
+	}
+
+	//没有明细记录的交易单，从TxFrom和TxTo还原
+	rawTx := &openwallet.RawTransaction{
+		TxFrom: []string{"TNQkiUv4qtDRKWDrKS628FTbDwxLMiqbAz:0.1"},
+		TxTo:   []string{"TSdXzXKSQ3RQzQ5Ge8TiYfMQEjofSVQ8ax:0.1"},
+	}
+	parts, err := GetRawTxParts(rawTx)
+	if err != nil {
+		t.Errorf("GetRawTxParts failed: %v", err)
+		return
+	}
+	if len(parts) != 1 || parts[0].From != "TNQkiUv4qtDRKWDrKS628FTbDwxLMiqbAz" || parts[0].Amount != "0.1" {
+		t.Errorf("GetRawTxParts unexpected parts: %+v", parts[0])
+		return
+	}
+
+	parts = append(parts, &RawTxPart{From: parts[0].From, To: "TNQkiUv4qtDRKWDrKS628FTbDwxLMiqbAz", Amount: "0.2", TxID: "abc"})
+	rawTx.SetExtParam(txPartsExtKey, parts)
+	parts, err = GetRawTxParts(rawTx)
+	if err != nil {
+		t.Errorf("GetRawTxParts failed: %v", err)
+		return
+	}
+	if len(parts) != 2 || parts[1].TxID != "abc" {
+		t.Errorf("GetRawTxParts unexpected parts: %+v", parts)
+		return
+	}
+}
+
+func TestSubmitPartsError(t *testing.T) {
+
+	submitErr := openwallet.Errorf(openwallet.ErrInsufficientFees, "bandwidth is not enough")
+	single := []*RawTxPart{{To: "B", TxID: "tx1", Error: submitErr.Error()}}
+	if err := submitPartsError(single, submitErr); err != submitErr {
+		t.Errorf("single transaction error = %v", err)
+	}
+
+	parts := []*RawTxPart{
+		{To: "B", TxID: "tx1"},
+		{To: "C", TxID: "tx2", Error: submitErr.Error()},
+	}
+	err := submitPartsError(parts, submitErr)
+	owErr := openwallet.ConvertError(err)
+	if owErr.Code() != openwallet.ErrInsufficientFees ||
+		!strings.Contains(err.Error(), "1 of 2 transactions failed: [tx2(to: C)") ||
+		!strings.Contains(err.Error(), "submitted: [tx1]") {
+		t.Errorf("partial broadcast error = %v", err)
+	}
+}
+
+func TestVerifyRawTransactionIntent(t *testing.T) {
+
+	decoder := NewTransactionDecoder(tw)
+	refBlock, _ := NewRefBlockFromHex("e1c0", "f60afaa8387fd17f")
+	trc20 := openwallet.SmartContract{
+		Address:  "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t",
+		Protocol: TRC20,
+		Decimals: 6,
+	}
+
+	newRawTx := func(to, amount string) *openwallet.RawTransaction {
+		txRaw, txID, err := tw.BuildTokenTransaction(refBlock, to, OWNERADDRESS, amount, trc20, nil)
+		if err != nil {
+			t.Fatalf("BuildTokenTransaction failed: %v\n", err)
+		}
+		return &openwallet.RawTransaction{
+			Coin:    openwallet.Coin{Symbol: "TRX", IsContract: true, Contract: trc20},
+			Account: &openwallet.AssetsAccount{AccountID: "A"},
+			To:      map[string]string{TOADDRESS: "1.5"},
+			RawHex:  txRaw,
+			Signatures: map[string][]*openwallet.KeySignature{
+				"A": {{Address: &openwallet.Address{Address: OWNERADDRESS}, Message: txID}},
+			},
+		}
+	}
+
+	if err := decoder.verifyRawTransactionIntent(newRawTx(TOADDRESS, "1.5")); err != nil {
+		t.Errorf("verifyRawTransactionIntent failed: %v\n", err)
+	}
+
+	//篡改接收地址
+	if err := decoder.verifyRawTransactionIntent(newRawTx(OWNERADDRESS, "1.5")); err == nil {
+		t.Errorf("tampered receiver should be rejected")
+	}
+
+	//篡改数量
+	if err := decoder.verifyRawTransactionIntent(newRawTx(TOADDRESS, "15")); err == nil {
+		t.Errorf("tampered amount should be rejected")
+	}
+
+	//篡改代币合约
+	rawTx := newRawTx(TOADDRESS, "1.5")
+	rawTx.Coin.Contract.Address = "TEkxiTehnzSmSe2XqrBj4w32RUN966rdz8"
+	if err := decoder.verifyRawTransactionIntent(rawTx); err == nil {
+		t.Errorf("tampered token should be rejected")
+	}
+
+	//签名地址不是发送地址
+	rawTx = newRawTx(TOADDRESS, "1.5")
+	rawTx.Signatures["A"][0].Address.Address = TOADDRESS
+	if err := decoder.verifyRawTransactionIntent(rawTx); err == nil {
+		t.Errorf("tampered owner should be rejected")
+	}
+
+	//签名消息不是交易哈希
+	rawTx = newRawTx(TOADDRESS, "1.5")
+	rawTx.Signatures["A"][0].Message = "00"
+	if err := decoder.verifyRawTransactionIntent(rawTx); err == nil {
+		t.Errorf("tampered message should be rejected")
+	}
+}
+
+func TestVerifyRawTransactionIntent_TransferFrom(t *testing.T) {
+
+	decoder := NewTransactionDecoder(tw)
+	refBlock, _ := NewRefBlockFromHex("e1c0", "f60afaa8387fd17f")
+	trc20 := openwallet.SmartContract{
+		Address:  "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t",
+		Protocol: TRC20,
+		Decimals: 6,
+	}
+	spender := "TEkxiTehnzSmSe2XqrBj4w32RUN966rdz8"
+
+	//spender从OWNERADDRESS转出代币到TOADDRESS
+	tc, err := tw.makeTRC20CallContract(spender, trc20, TRC20_TRANSFER_FROM_METHOD_ID, OWNERADDRESS, TOADDRESS, big.NewInt(1500000))
+	if err != nil {
+		t.Errorf("makeTRC20CallContract failed: %v\n", err)
+		return
+	}
+	txRaw, txID, err := tw.BuildContractTransaction(refBlock, tc, core.Transaction_Contract_TriggerSmartContract, nil)
+	if err != nil {
+		t.Errorf("BuildContractTransaction failed: %v\n", err)
+		return
+	}
+
+	rawTx := &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: "TRX", IsContract: true, Contract: trc20},
+		Account: &openwallet.AssetsAccount{AccountID: "A"},
+		To:      map[string]string{TOADDRESS: "1.5"},
+		RawHex:  txRaw,
+		Signatures: map[string][]*openwallet.KeySignature{
+			"A": {{Address: &openwallet.Address{Address: spender}, Message: txID}},
+		},
+	}
+
+	//未声明transferFrom
+	if err := decoder.verifyRawTransactionIntent(rawTx); err == nil {
+		t.Errorf("transferFrom should not be accepted as transfer")
+	}
+
+	rawTx.SetExtParam(trc20MethodExtKey, "transferFrom")
+	rawTx.SetExtParam(tokenFromExtKey, OWNERADDRESS)
+	if err := decoder.verifyRawTransactionIntent(rawTx); err != nil {
+		t.Errorf("verifyRawTransactionIntent failed: %v\n", err)
+	}
+
+	rawTx.SetExtParam(tokenFromExtKey, TOADDRESS)
+	if err := decoder.verifyRawTransactionIntent(rawTx); err == nil {
+		t.Errorf("tampered token sender should be rejected")
+	}
+
+	//地址参数高12字节不为0，不同的编码不能通过验证
+	for _, pos := range []int{4, 4 + 32} {
+		dirty := *tc
+		dirty.Data = append([]byte{}, tc.Data...)
+		dirty.Data[pos] = 0x01
+		dirtyRaw, _, _ := tw.BuildContractTransaction(refBlock, &dirty, core.Transaction_Contract_TriggerSmartContract, nil)
+		if _, err := decodeTransferIntent(dirtyRaw, false); err == nil {
+			t.Errorf("address word with dirty upper bytes at %d should be rejected", pos)
+		}
+	}
+
+	//无限授权
+	tc, _ = tw.makeTRC20CallContract(OWNERADDRESS, trc20, TRC20_APPROVE_METHOD_ID, spender, trc20Allowance(TRC20UnlimitedAllowance, 6))
+	txRaw, txID, _ = tw.BuildContractTransaction(refBlock, tc, core.Transaction_Contract_TriggerSmartContract, nil)
+	allowance := common.BigIntToDecimals(trc20Allowance(TRC20UnlimitedAllowance, 6), 6).StringFixed(6)
+	rawTx = &openwallet.RawTransaction{
+		Coin:    openwallet.Coin{Symbol: "TRX", IsContract: true, Contract: trc20},
+		Account: &openwallet.AssetsAccount{AccountID: "A"},
+		To:      map[string]string{spender: allowance},
+		RawHex:  txRaw,
+		Signatures: map[string][]*openwallet.KeySignature{
+			"A": {{Address: &openwallet.Address{Address: OWNERADDRESS}, Message: txID}},
+		},
+	}
+	rawTx.SetExtParam(trc20MethodExtKey, "approve")
+	if err := decoder.verifyRawTransactionIntent(rawTx); err != nil {
+		t.Errorf("verifyRawTransactionIntent approve failed: %v\n", err)
+	}
+	if hex.EncodeToString(tc.Data[len(tc.Data)-32:]) != strings.Repeat("ff", 32) {
+		t.Errorf("unlimited allowance should be max uint256")
+	}
+}
+
+func TestSweepAmount(t *testing.T) {
+
+	refBlock, _ := NewRefBlockFromHex("e1c0", "f60afaa8387fd17f")
+	build := func(amount int64) (string, error) {
+		txRaw, _, err := tw.BuildTokenTransaction(refBlock, TOADDRESS, OWNERADDRESS, decimal.New(amount, -Decimals).String(), openwallet.SmartContract{}, nil)
+		return txRaw, err
+	}
+	params := map[string]int64{
+		"getTransactionFee":                      1000,
+		"getCreateAccountFee":                    100000,
+		"getCreateNewAccountFeeInSystemContract": 1000000,
+	}
+	available := int64(12345678)
+
+	//带宽不足，转账数量 + 燃烧的带宽 = 可汇总余额
+	amount, rawHex, fee, err := sweepAmount(available, &AccountNet{}, params, true, build)
+	if err != nil {
+		t.Errorf("sweepAmount failed: %v\n", err)
+		return
+	}
+	if !fee.Fee.Equal(decimal.New(EstimateBandwidth(rawHex)*1000, -Decimals)) || !amount.Add(fee.Fee).Equal(decimal.New(available, -Decimals)) {
+		t.Errorf("unexpected sweep amount: %s, fee: %s", amount, fee.Fee)
+	}
+
+	//免费带宽足够，全部转出
+	amount, _, fee, _ = sweepAmount(available, &AccountNet{FreeNetLimit: 600}, params, true, build)
+	if !fee.Fee.IsZero() || !amount.Equal(decimal.New(available, -Decimals)) {
+		t.Errorf("unexpected sweep amount: %s, fee: %s", amount, fee.Fee)
+	}
+
+	//接收地址未激活，燃烧1.1 TRX
+	amount, _, fee, _ = sweepAmount(available, &AccountNet{FreeNetLimit: 600}, params, false, build)
+	if !fee.Fee.Equal(decimal.New(1100000, -Decimals)) || !amount.Equal(decimal.New(available-1100000, -Decimals)) {
+		t.Errorf("unexpected sweep amount: %s, fee: %s", amount, fee.Fee)
+	}
+
+	//余额不足以支付手续费
+	amount, _, _, _ = sweepAmount(1000, &AccountNet{}, params, true, build)
+	if !amount.IsZero() {
+		t.Errorf("unexpected sweep amount: %s", amount)
+	}
+
+	//数量编码长度变化，无法恰好相等时取较小数量
+	boundary := available - 200000
+	fakeBuild := func(amount int64) (string, error) {
+		if amount >= boundary {
+			return strings.Repeat("00", 101), nil
+		}
+		return strings.Repeat("00", 100), nil
+	}
+	amount, _, fee, _ = sweepAmount(available, &AccountNet{}, params, true, fakeBuild)
+	if amount.Add(fee.Fee).GreaterThan(decimal.New(available, -Decimals)) || amount.GreaterThanOrEqual(decimal.New(boundary, -Decimals)) {
+		t.Errorf("unexpected sweep amount: %s, fee: %s", amount, fee.Fee)
+	}
+}
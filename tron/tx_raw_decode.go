@@ -0,0 +1,340 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/blocktree/go-owcrypt"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+	"github.com/golang/protobuf/proto"
+	"github.com/shopspring/decimal"
+)
+
+//DecodedTransaction 解析后的交易单，TRX数量以TRX为单位，代币数量以最小单位表示
+type DecodedTransaction struct {
+	TxID          string             `json:"txid"`
+	RefBlockBytes string             `json:"ref_block_bytes"`
+	RefBlockHash  string             `json:"ref_block_hash"`
+	Timestamp     int64              `json:"timestamp"`
+	Expiration    int64              `json:"expiration"`
+	FeeLimit      string             `json:"fee_limit"`
+	Memo          string             `json:"memo,omitempty"`
+	Contracts     []*DecodedContract `json:"contracts"`
+	Signers       []string           `json:"signers"`
+}
+
+//DecodedContract 解析后的合约
+type DecodedContract struct {
+	Type         string                 `json:"type"`
+	PermissionID int32                  `json:"permission_id"`
+	Owner        string                 `json:"owner,omitempty"`
+	To           string                 `json:"to,omitempty"`
+	Amount       string                 `json:"amount,omitempty"`
+	Token        string                 `json:"token,omitempty"` //TRC10为资产ID，TRC20为合约地址
+	Call         *DecodedCall           `json:"call,omitempty"`
+	Parameter    map[string]interface{} `json:"parameter"`
+}
+
+//DecodedCall 解析后的合约方法调用
+type DecodedCall struct {
+	Method   string        `json:"method,omitempty"`
+	MethodID string        `json:"method_id"`
+	Params   []interface{} `json:"params,omitempty"`
+	Data     string        `json:"data,omitempty"` //无法识别的方法，原始参数
+}
+
+//trc20Methods 可识别的TRC20方法
+var trc20Methods = map[string]struct {
+	name   string
+	inputs []string
+}{
+	TRC20_TRANSFER_METHOD_ID:           {"transfer", []string{SOLIDITY_TYPE_ADDRESS, SOLIDITY_TYPE_UINT256}},
+	TRC20_TRANSFER_FROM_METHOD_ID:      {"transferFrom", []string{SOLIDITY_TYPE_ADDRESS, SOLIDITY_TYPE_ADDRESS, SOLIDITY_TYPE_UINT256}},
+	TRC20_APPROVE_METHOD_ID:            {"approve", []string{SOLIDITY_TYPE_ADDRESS, SOLIDITY_TYPE_UINT256}},
+	TRC20_INCREASE_ALLOWANCE_METHOD_ID: {"increaseAllowance", []string{SOLIDITY_TYPE_ADDRESS, SOLIDITY_TYPE_UINT256}},
+	TRC20_DECREASE_ALLOWANCE_METHOD_ID: {"decreaseAllowance", []string{SOLIDITY_TYPE_ADDRESS, SOLIDITY_TYPE_UINT256}},
+}
+
+//textFields 以字符串显示的bytes字段
+var textFields = map[string]bool{
+	"account_name": true,
+	"account_id":   true,
+	"asset_name":   true,
+	"name":         true,
+	"abbr":         true,
+	"description":  true,
+	"url":          true,
+	"update_url":   true,
+}
+
+//DecodeRawTransaction 解析任意交易单，用于签名前人工审核
+func (wm *WalletManager) DecodeRawTransaction(txHex string) (*DecodedTransaction, error) {
+
+	txBytes, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, err
+	}
+	tx := &core.Transaction{}
+	if err := proto.Unmarshal(txBytes, tx); err != nil {
+		return nil, err
+	}
+	rawData := tx.GetRawData()
+	if rawData == nil {
+		return nil, fmt.Errorf("transaction raw data is empty")
+	}
+
+	txHash, err := getTxHash(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := &DecodedTransaction{
+		TxID:          hex.EncodeToString(txHash),
+		RefBlockBytes: hex.EncodeToString(rawData.GetRefBlockBytes()),
+		RefBlockHash:  hex.EncodeToString(rawData.GetRefBlockHash()),
+		Timestamp:     rawData.GetTimestamp(),
+		Expiration:    rawData.GetExpiration(),
+		FeeLimit:      wm.sunToTRX(rawData.GetFeeLimit()),
+		Memo:          string(rawData.GetData()),
+		Contracts:     make([]*DecodedContract, 0),
+		Signers:       make([]string, 0),
+	}
+
+	for _, c := range rawData.GetContract() {
+		contract, err := wm.decodeContract(c)
+		if err != nil {
+			return nil, err
+		}
+		decoded.Contracts = append(decoded.Contracts, contract)
+	}
+
+	for _, sig := range tx.GetSignature() {
+		pkBytes, ret := owcrypt.RecoverPubkey(sig, txHash, wm.CurveType())
+		if ret != owcrypt.SUCCESS {
+			return nil, fmt.Errorf("recover signer public key failed")
+		}
+		pkHash := owcrypt.Hash(pkBytes, 0, owcrypt.HASH_ALG_KECCAK256)[12:32]
+		signer, err := EncodeAddress(hex.EncodeToString(pkHash), wm.Config.IsTestNet)
+		if err != nil {
+			return nil, err
+		}
+		decoded.Signers = append(decoded.Signers, signer)
+	}
+
+	return decoded, nil
+}
+
+//decodeContract 通过protobuf注册的类型解析合约参数
+func (wm *WalletManager) decodeContract(c *core.Transaction_Contract) (*DecodedContract, error) {
+
+	contract := &DecodedContract{
+		Type:         c.GetType().String(),
+		PermissionID: contractPermissionID(c),
+	}
+
+	typeName := strings.TrimPrefix(c.GetParameter().GetTypeUrl(), "type.googleapis.com/")
+	msgType := proto.MessageType(typeName)
+	if msgType == nil {
+		//未生成的合约类型，保留原始数据
+		contract.Parameter = map[string]interface{}{
+			"type_url": c.GetParameter().GetTypeUrl(),
+			"value":    hex.EncodeToString(c.GetParameter().GetValue()),
+		}
+		return contract, nil
+	}
+
+	msg := reflect.New(msgType.Elem()).Interface().(proto.Message)
+	if err := proto.Unmarshal(c.GetParameter().GetValue(), msg); err != nil {
+		return nil, err
+	}
+	contract.Parameter = wm.protoMessageToMap(reflect.ValueOf(msg))
+	if owner, ok := contract.Parameter["owner_address"].(string); ok {
+		contract.Owner = owner
+	}
+
+	switch tc := msg.(type) {
+	case *core.TransferContract:
+		contract.To = contract.Parameter["to_address"].(string)
+		contract.Amount = wm.sunToTRX(tc.GetAmount())
+	case *core.TransferAssetContract:
+		contract.To = contract.Parameter["to_address"].(string)
+		contract.Amount = big.NewInt(tc.GetAmount()).String()
+		contract.Token = string(tc.GetAssetName())
+	case *core.TriggerSmartContract:
+		contract.To = contract.Parameter["contract_address"].(string)
+		contract.Amount = wm.sunToTRX(tc.GetCallValue())
+		contract.Call = wm.decodeTRC20Call(tc.GetData())
+		if contract.Call.Method == "transfer" {
+			contract.Token = contract.To
+			contract.To = contract.Call.Params[0].(string)
+			contract.Amount = contract.Call.Params[1].(string)
+		}
+	case *core.FreezeBalanceV2Contract:
+		contract.Amount = wm.sunToTRX(tc.GetFrozenBalance())
+	case *core.UnfreezeBalanceV2Contract:
+		contract.Amount = wm.sunToTRX(tc.GetUnfreezeBalance())
+	case *core.DelegateResourceContract:
+		contract.To = contract.Parameter["receiver_address"].(string)
+		contract.Amount = wm.sunToTRX(tc.GetBalance())
+	case *core.UnDelegateResourceContract:
+		contract.To = contract.Parameter["receiver_address"].(string)
+		contract.Amount = wm.sunToTRX(tc.GetBalance())
+	}
+
+	return contract, nil
+}
+
+//decodeTRC20Call 解析TRC20方法调用，地址为base58格式，数量为代币最小单位
+func (wm *WalletManager) decodeTRC20Call(data []byte) *DecodedCall {
+	call := &DecodedCall{}
+	if len(data) < 4 {
+		call.Data = hex.EncodeToString(data)
+		return call
+	}
+	call.MethodID = hex.EncodeToString(data[:4])
+	args := data[4:]
+	method, ok := trc20Methods[call.MethodID]
+	if !ok || len(args) != 32*len(method.inputs) {
+		call.Data = hex.EncodeToString(args)
+		return call
+	}
+
+	call.Method = method.name
+	for i, input := range method.inputs {
+		word := args[i*32 : (i+1)*32]
+		switch input {
+		case SOLIDITY_TYPE_ADDRESS:
+			addr, _ := EncodeAddress(hex.EncodeToString(word[12:]), wm.Config.IsTestNet)
+			call.Params = append(call.Params, addr)
+		default:
+			call.Params = append(call.Params, new(big.Int).SetBytes(word).String())
+		}
+	}
+	return call
+}
+
+//protoMessageToMap 按protobuf字段名转换为map，地址转为base58格式
+func (wm *WalletManager) protoMessageToMap(v reflect.Value) map[string]interface{} {
+	result := make(map[string]interface{})
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return result
+		}
+		v = v.Elem()
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if strings.HasPrefix(field.Name, "XXX_") {
+			continue
+		}
+		name := protoFieldName(field)
+		if len(name) == 0 {
+			continue
+		}
+		result[name] = wm.protoValue(name, v.Field(i))
+	}
+	return result
+}
+
+func (wm *WalletManager) protoValue(name string, v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr:
+		return wm.protoMessageToMap(v)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return wm.protoBytes(name, v.Bytes())
+		}
+		list := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			list = append(list, wm.protoValue(name, v.Index(i)))
+		}
+		return list
+	case reflect.Int32:
+		//枚举类型
+		if s, ok := v.Interface().(fmt.Stringer); ok {
+			return s.String()
+		}
+		return v.Int()
+	default:
+		return v.Interface()
+	}
+}
+
+func (wm *WalletManager) protoBytes(name string, b []byte) interface{} {
+	if strings.HasSuffix(name, "address") && len(b) == 21 {
+		if addr, err := EncodeAddress(hex.EncodeToString(b), wm.Config.IsTestNet); err == nil {
+			return addr
+		}
+	}
+	if textFields[name] && utf8.Valid(b) {
+		return string(b)
+	}
+	return hex.EncodeToString(b)
+}
+
+//protoFieldName 读取protobuf标签中的字段名
+func protoFieldName(field reflect.StructField) string {
+	for _, s := range strings.Split(field.Tag.Get("protobuf"), ",") {
+		if strings.HasPrefix(s, "name=") {
+			return strings.TrimPrefix(s, "name=")
+		}
+	}
+	return ""
+}
+
+//contractPermissionID 读取合约的Permission_id(字段5)，当前生成的协议代码未包含该字段
+func contractPermissionID(c *core.Transaction_Contract) int32 {
+	buf := proto.NewBuffer(c.XXX_unrecognized)
+	for {
+		key, err := buf.DecodeVarint()
+		if err != nil {
+			return 0
+		}
+		switch key & 7 {
+		case proto.WireVarint:
+			v, err := buf.DecodeVarint()
+			if err != nil {
+				return 0
+			}
+			if key>>3 == 5 {
+				return int32(v)
+			}
+		case proto.WireFixed64:
+			_, err = buf.DecodeFixed64()
+		case proto.WireBytes:
+			_, err = buf.DecodeRawBytes(false)
+		case proto.WireFixed32:
+			_, err = buf.DecodeFixed32()
+		default:
+			return 0
+		}
+		if err != nil {
+			return 0
+		}
+	}
+}
+
+//sunToTRX 以TRX为单位显示
+func (wm *WalletManager) sunToTRX(sun int64) string {
+	return decimal.New(sun, 0).Shift(-wm.Decimal()).String()
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/blocktree/openwallet/v2/log"
+	"github.com/blocktree/openwallet/v2/openwallet"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+)
+
+func TestWalletManager_DecodeRawTransaction(t *testing.T) {
+
+	refBlock, _ := NewRefBlockFromHex("e1c0", "f60afaa8387fd17f")
+	trc20 := openwallet.SmartContract{
+		Address:  "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t",
+		Protocol: TRC20,
+		Decimals: 6,
+	}
+
+	txRaw, txID, err := tw.BuildTokenTransaction(refBlock, TOADDRESS, OWNERADDRESS, "1.5", trc20, &TxOptions{Memo: "hello"})
+	if err != nil {
+		t.Errorf("BuildTokenTransaction failed: %v\n", err)
+		return
+	}
+	signature, err := tw.SignTransactionRef(txID, PRIVATEKEY)
+	if err != nil {
+		t.Errorf("SignTransactionRef failed: %v\n", err)
+		return
+	}
+	txSigned, err := InsertSignatureIntoRawTransaction(txRaw, signature)
+	if err != nil {
+		t.Errorf("InsertSignatureIntoRawTransaction failed: %v\n", err)
+		return
+	}
+
+	decoded, err := tw.DecodeRawTransaction(txSigned)
+	if err != nil {
+		t.Errorf("DecodeRawTransaction failed: %v\n", err)
+		return
+	}
+	if decoded.TxID != txID || decoded.RefBlockBytes != "e1c0" || decoded.Memo != "hello" {
+		t.Errorf("unexpected transaction: %+v", decoded)
+	}
+	if len(decoded.Signers) != 1 || decoded.Signers[0] != OWNERADDRESS {
+		t.Errorf("unexpected signers: %v", decoded.Signers)
+	}
+	if len(decoded.Contracts) != 1 {
+		t.Errorf("unexpected contracts count: %d", len(decoded.Contracts))
+		return
+	}
+	c := decoded.Contracts[0]
+	if c.Type != core.Transaction_Contract_TriggerSmartContract.String() || c.Owner != OWNERADDRESS ||
+		c.To != TOADDRESS || c.Amount != "1500000" || c.Token != trc20.Address || c.Call.Method != "transfer" {
+		t.Errorf("unexpected contract: %+v", c)
+	}
+
+	js, _ := json.MarshalIndent(decoded, "", "  ")
+	log.Infof("decoded: %s", js)
+
+	txRaw, _, err = tw.BuildTokenTransaction(refBlock, TOADDRESS, OWNERADDRESS, "0.1", openwallet.SmartContract{}, nil)
+	if err != nil {
+		t.Errorf("BuildTokenTransaction failed: %v\n", err)
+		return
+	}
+	decoded, err = tw.DecodeRawTransaction(txRaw)
+	if err != nil {
+		t.Errorf("DecodeRawTransaction failed: %v\n", err)
+		return
+	}
+	c = decoded.Contracts[0]
+	if c.To != TOADDRESS || c.Amount != "0.1" || c.Parameter["amount"].(int64) != 100000 {
+		t.Errorf("unexpected contract: %+v", c)
+	}
+}
@@ -0,0 +1,357 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/blocktree/tron-adapter/tron/grpc-gateway/core"
+	"github.com/golang/protobuf/proto"
+	"github.com/imroc/req"
+	"github.com/tidwall/gjson"
+)
+
+//交易跟踪状态
+const (
+	TxStatusPending      = "pending"      //已广播，未上链
+	TxStatusConfirmed    = "confirmed"    //已上链，未固化
+	TxStatusSolidified   = "solidified"   //已固化
+	TxStatusFailed       = "failed"       //已上链，执行失败，如OUT_OF_ENERGY、REVERT
+	TxStatusExpired      = "expired"      //超过有效期未上链
+	TxStatusUnsolidified = "unsolidified" //已上链，超过有效期加固化超时仍未固化，停止跟踪
+)
+
+const (
+	//TxTrackerFile 交易跟踪数据库文件
+	TxTrackerFile = "txtracker.db"
+	//DefaultTxTrackerPollInterval 默认轮询间隔
+	DefaultTxTrackerPollInterval = 3 * time.Second
+	//DefaultTxRebroadcastInterval 默认重新广播间隔
+	DefaultTxRebroadcastInterval = 30 * time.Second
+	//DefaultTxSolidifyTimeout 默认固化超时，交易有效期后超过该时间仍未固化则停止跟踪
+	DefaultTxSolidifyTimeout = 5 * time.Minute
+)
+
+//TrackedTx 跟踪中的交易
+type TrackedTx struct {
+	TxID        string `storm:"id"`
+	RawHex      string //已签名的交易单，重新广播时原样提交
+	Expiration  int64  //交易有效期，毫秒时间戳
+	Status      string
+	Result      string //合约执行结果，如SUCCESS、OUT_OF_ENERGY
+	BlockHeight uint64
+	Rebroadcast int   //重新广播次数
+	BroadcastAt int64 //最近一次广播时间，秒
+	CreatedAt   int64
+	UpdatedAt   int64
+}
+
+//IsFinal 是否为终止状态
+func (tx *TrackedTx) IsFinal() bool {
+	return tx.Status == TxStatusSolidified || tx.Status == TxStatusFailed || tx.Status == TxStatusExpired || tx.Status == TxStatusUnsolidified
+}
+
+//TxStatusCallback 交易状态变化回调
+type TxStatusCallback func(tx *TrackedTx, oldStatus string)
+
+//TxTracker 广播后交易跟踪器，轮询交易上链结果，有效期内重新广播，状态持久化到数据库。
+//配置txTracker = true时加载配置后自动启动，否则需要调用方调用Start，未启动时发送地址锁到交易有效期结束才释放
+type TxTracker struct {
+	wm                  *WalletManager
+	mu                  sync.Mutex
+	quit                chan struct{}
+	running             bool
+	DBFile              string
+	PollInterval        time.Duration
+	RebroadcastInterval time.Duration
+	SolidifyTimeout     time.Duration
+	OnStatusChange      TxStatusCallback
+}
+
+//NewTxTracker 创建交易跟踪器
+func NewTxTracker(wm *WalletManager) *TxTracker {
+	return &TxTracker{
+		wm:                  wm,
+		PollInterval:        DefaultTxTrackerPollInterval,
+		RebroadcastInterval: DefaultTxRebroadcastInterval,
+		SolidifyTimeout:     DefaultTxSolidifyTimeout,
+	}
+}
+
+func (t *TxTracker) dbFile() string {
+	if len(t.DBFile) > 0 {
+		return t.DBFile
+	}
+	return filepath.Join(t.wm.Config.dbPath, TxTrackerFile)
+}
+
+//Track 添加已广播的交易到跟踪列表
+func (t *TxTracker) Track(signedHex string) (*TrackedTx, error) {
+
+	txBytes, err := hex.DecodeString(signedHex)
+	if err != nil {
+		return nil, err
+	}
+	tx := &core.Transaction{}
+	if err := proto.Unmarshal(txBytes, tx); err != nil {
+		return nil, err
+	}
+	txHash, err := getTxHash(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	tracked := &TrackedTx{
+		TxID:        hex.EncodeToString(txHash),
+		RawHex:      signedHex,
+		Expiration:  tx.GetRawData().GetExpiration(),
+		Status:      TxStatusPending,
+		BroadcastAt: now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	db, err := storm.Open(t.dbFile())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return tracked, db.Save(tracked)
+}
+
+//GetTrackedTx 获取跟踪的交易
+func (t *TxTracker) GetTrackedTx(txid string) (*TrackedTx, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	db, err := storm.Open(t.dbFile())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var tracked TrackedTx
+	if err := db.One("TxID", txid, &tracked); err != nil {
+		return nil, err
+	}
+	return &tracked, nil
+}
+
+//Remove 删除跟踪的交易
+func (t *TxTracker) Remove(txid string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	db, err := storm.Open(t.dbFile())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.DeleteStruct(&TrackedTx{TxID: txid})
+}
+
+//Start 启动跟踪，重启后继续跟踪数据库中未完成的交易
+func (t *TxTracker) Start() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.running {
+		return
+	}
+	t.running = true
+	t.quit = make(chan struct{})
+
+	go func(quit chan struct{}) {
+		ticker := time.NewTicker(t.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := t.Poll(); err != nil {
+					t.wm.Log.Std.Error("tx tracker poll failed, err=%v", err)
+				}
+			case <-quit:
+				return
+			}
+		}
+	}(t.quit)
+}
+
+//Stop 停止跟踪
+func (t *TxTracker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.running {
+		return
+	}
+	close(t.quit)
+	t.running = false
+}
+
+//IsRunning 是否已启动
+func (t *TxTracker) IsRunning() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.running
+}
+
+//Poll 检查所有未完成交易的状态
+func (t *TxTracker) Poll() error {
+
+	t.mu.Lock()
+	db, err := storm.Open(t.dbFile())
+	if err != nil {
+		t.mu.Unlock()
+		return err
+	}
+	var list []*TrackedTx
+	err = db.All(&list)
+	db.Close()
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, tracked := range list {
+		if tracked.IsFinal() {
+			continue
+		}
+		oldStatus := tracked.Status
+		if err := t.check(tracked); err != nil {
+			t.wm.Log.Std.Error("tx tracker check txid[%s] failed, err=%v", tracked.TxID, err)
+			continue
+		}
+		if err := t.update(tracked); err != nil {
+			return err
+		}
+		//交易已有结果，解锁发送地址
+		if tracked.Status != TxStatusPending {
+			t.wm.AddressLocker.Unlock(tracked.TxID)
+		}
+		if tracked.Status != oldStatus && t.OnStatusChange != nil {
+			t.OnStatusChange(tracked, oldStatus)
+		}
+	}
+	return nil
+}
+
+func (t *TxTracker) update(tracked *TrackedTx) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	db, err := storm.Open(t.dbFile())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tracked.UpdatedAt = time.Now().Unix()
+	return db.Save(tracked)
+}
+
+//check 查询交易上链结果，未上链时在有效期内重新广播
+func (t *TxTracker) check(tracked *TrackedTx) error {
+
+	if tracked.Status == TxStatusConfirmed {
+		info, err := t.wm.GetTransactionInfo(tracked.TxID, true)
+		if err == nil && info.Get("id").Exists() {
+			tracked.Status = TxStatusSolidified
+			return nil
+		}
+		if t.solidifyTimedOut(tracked, time.Now()) {
+			tracked.Status = TxStatusUnsolidified
+			return nil
+		}
+		return err
+	}
+
+	info, err := t.wm.GetTransactionInfo(tracked.TxID, false)
+	if err != nil {
+		return err
+	}
+	if info.Get("id").Exists() {
+		tracked.Status, tracked.Result, tracked.BlockHeight = parseTxTrackerInfo(info)
+		return nil
+	}
+
+	now := time.Now()
+	if now.UnixNano()/1000000 > tracked.Expiration {
+		tracked.Status = TxStatusExpired
+		return nil
+	}
+
+	if now.Unix()-tracked.BroadcastAt < int64(t.RebroadcastInterval.Seconds()) {
+		return nil
+	}
+
+	_, err = t.wm.BroadcastTransaction(tracked.RawHex)
+	tracked.Rebroadcast++
+	tracked.BroadcastAt = now.Unix()
+	if broadcastErr, ok := err.(*BroadcastError); ok {
+		if broadcastErr.IsExpired() {
+			tracked.Status = TxStatusExpired
+			return nil
+		}
+		if broadcastErr.IsDuplicate() {
+			return nil
+		}
+	}
+	return err
+}
+
+//solidifyTimedOut 已上链的交易超过有效期加固化超时仍未固化
+func (t *TxTracker) solidifyTimedOut(tracked *TrackedTx, now time.Time) bool {
+	deadline := tracked.Expiration + int64(t.SolidifyTimeout/time.Millisecond)
+	return now.UnixNano()/1000000 > deadline
+}
+
+//parseTxTrackerInfo 解析gettransactioninfobyid结果
+func parseTxTrackerInfo(info *gjson.Result) (status, result string, blockHeight uint64) {
+	blockHeight = info.Get("blockNumber").Uint()
+	result = info.Get("receipt.result").String()
+	if info.Get("result").String() == "FAILED" || (len(result) > 0 && result != SUCCESS) {
+		return TxStatusFailed, result, blockHeight
+	}
+	if len(result) == 0 {
+		result = SUCCESS
+	}
+	return TxStatusConfirmed, result, blockHeight
+}
+
+//GetTransactionInfo 获取交易执行结果，solidity为true时查询固化节点，交易未上链时返回空对象
+func (wm *WalletManager) GetTransactionInfo(txid string, solidity bool) (*gjson.Result, error) {
+	path := "/wallet/gettransactioninfobyid"
+	if solidity {
+		path = "/walletsolidity/gettransactioninfobyid"
+	}
+	r, err := wm.WalletClient.Call(path, req.Param{"value": txid})
+	if err != nil {
+		return nil, fmt.Errorf("get transaction info failed, err=%v", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestTxTracker_Track(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "txtracker")
+	if err != nil {
+		t.Errorf("TempDir failed: %v\n", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	tracker := NewTxTracker(tw)
+	tracker.DBFile = filepath.Join(dir, TxTrackerFile)
+
+	tracked, err := tracker.Track(pTxSigned)
+	if err != nil {
+		t.Errorf("Track failed: %v\n", err)
+		return
+	}
+	txHash, _ := getTxHash1(pTxSigned)
+	if tracked.Status != TxStatusPending || tracked.Expiration == 0 || tracked.TxID != hex.EncodeToString(txHash) {
+		t.Errorf("unexpected tracked tx: %+v", tracked)
+		return
+	}
+
+	//重新打开跟踪器，状态仍然存在
+	tracker = NewTxTracker(tw)
+	tracker.DBFile = filepath.Join(dir, TxTrackerFile)
+	saved, err := tracker.GetTrackedTx(tracked.TxID)
+	if err != nil {
+		t.Errorf("GetTrackedTx failed: %v\n", err)
+		return
+	}
+	if saved.RawHex != pTxSigned {
+		t.Errorf("saved raw hex is not equal to tracked raw hex")
+	}
+
+	if err := tracker.Remove(tracked.TxID); err != nil {
+		t.Errorf("Remove failed: %v\n", err)
+		return
+	}
+	if _, err := tracker.GetTrackedTx(tracked.TxID); err == nil {
+		t.Errorf("removed tx should not be found")
+	}
+}
+
+func TestParseTxTrackerInfo(t *testing.T) {
+
+	tests := []struct {
+		info   string
+		status string
+		result string
+	}{
+		{`{"id":"a1","blockNumber":100,"receipt":{"net_usage":267}}`, TxStatusConfirmed, SUCCESS},
+		{`{"id":"a1","blockNumber":100,"receipt":{"result":"SUCCESS"}}`, TxStatusConfirmed, SUCCESS},
+		{`{"id":"a1","blockNumber":100,"result":"FAILED","receipt":{"result":"OUT_OF_ENERGY"}}`, TxStatusFailed, "OUT_OF_ENERGY"},
+		{`{"id":"a1","blockNumber":100,"receipt":{"result":"REVERT"}}`, TxStatusFailed, "REVERT"},
+	}
+
+	for i, test := range tests {
+		info := gjson.Parse(test.info)
+		status, result, height := parseTxTrackerInfo(&info)
+		if status != test.status || result != test.result || height != 100 {
+			t.Errorf("case %d: got status: %s, result: %s, height: %d", i, status, result, height)
+		}
+	}
+}
+
+func TestTxTracker_SolidifyTimedOut(t *testing.T) {
+
+	tracker := NewTxTracker(tw)
+	now := time.Now()
+	tracked := &TrackedTx{Status: TxStatusConfirmed, Expiration: now.UnixNano() / 1000000}
+
+	if tracker.solidifyTimedOut(tracked, now.Add(time.Minute)) {
+		t.Errorf("confirmed tx should be polled within solidify timeout")
+	}
+	if !tracker.solidifyTimedOut(tracked, now.Add(DefaultTxSolidifyTimeout+time.Second)) {
+		t.Errorf("confirmed tx should give up after solidify timeout")
+	}
+	tracked.Status = TxStatusUnsolidified
+	if !tracked.IsFinal() {
+		t.Errorf("unsolidified tx should be final")
+	}
+}
+
+func TestWalletManager_StartTxTracker(t *testing.T) {
+
+	wm := NewWalletManager()
+	wm.startTxTracker()
+	if wm.TxTracker.IsRunning() {
+		t.Errorf("tx tracker should not start when txTracker is disabled")
+	}
+
+	c, _ := config.NewConfigData("ini", []byte("txTracker = true"))
+	wm.Config.loadTxConfig(c)
+	wm.startTxTracker()
+	defer wm.TxTracker.Stop()
+	if !wm.TxTracker.IsRunning() {
+		t.Errorf("tx tracker should start when txTracker is enabled")
+	}
+}
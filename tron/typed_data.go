@@ -0,0 +1,355 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	owcrypt "github.com/blocktree/go-owcrypt"
+	"github.com/blocktree/openwallet/v2/hdkeystore"
+	"github.com/blocktree/openwallet/v2/openwallet"
+)
+
+//typedDataDomainType 域类型名称
+const typedDataDomainType = "EIP712Domain"
+
+//typedDataDomainFields 未声明EIP712Domain类型时，按规范顺序使用域中出现的字段
+var typedDataDomainFields = []TypedDataField{
+	{Name: "name", Type: "string"},
+	{Name: "version", Type: "string"},
+	{Name: "chainId", Type: "uint256"},
+	{Name: "verifyingContract", Type: "address"},
+	{Name: "salt", Type: "bytes32"},
+}
+
+var typedDataArrayRegexp = regexp.MustCompile(`^(.+)\[(\d*)\]$`)
+
+//TypedDataField 结构体字段
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+//TypedData TIP-712结构化数据，与EIP-712相同，address可使用TRON base58地址，trcToken按uint256编码
+type TypedData struct {
+	Types       map[string][]TypedDataField `json:"types"`
+	PrimaryType string                      `json:"primaryType"`
+	Domain      map[string]interface{}      `json:"domain"`
+	Message     map[string]interface{}      `json:"message"`
+}
+
+//NewTypedData 解析JSON格式的结构化数据，数字保留原始精度
+func NewTypedData(data []byte) (*TypedData, error) {
+	td := &TypedData{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(td); err != nil {
+		return nil, err
+	}
+	if _, ok := td.Types[td.PrimaryType]; !ok {
+		return nil, fmt.Errorf("primary type: %s is not defined", td.PrimaryType)
+	}
+	return td, nil
+}
+
+//EncodeType 类型编码，主类型在前，依赖类型按名称排序
+func (td *TypedData) EncodeType(primaryType string) (string, error) {
+
+	deps := make(map[string]bool)
+	if err := td.dependencies(primaryType, deps); err != nil {
+		return "", err
+	}
+	delete(deps, primaryType)
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf strings.Builder
+	for _, name := range append([]string{primaryType}, names...) {
+		fields := make([]string, 0)
+		for _, field := range td.types(name) {
+			fields = append(fields, field.Type+" "+field.Name)
+		}
+		buf.WriteString(name + "(" + strings.Join(fields, ",") + ")")
+	}
+	return buf.String(), nil
+}
+
+//TypeHash 类型编码的Keccak-256
+func (td *TypedData) TypeHash(primaryType string) ([]byte, error) {
+	encodeType, err := td.EncodeType(primaryType)
+	if err != nil {
+		return nil, err
+	}
+	return owcrypt.Hash([]byte(encodeType), 0, owcrypt.HASH_ALG_KECCAK256), nil
+}
+
+//HashStruct 结构体哈希，keccak256(typeHash || encodeData)
+func (td *TypedData) HashStruct(primaryType string, data map[string]interface{}) ([]byte, error) {
+
+	typeHash, err := td.TypeHash(primaryType)
+	if err != nil {
+		return nil, err
+	}
+	buf := append([]byte{}, typeHash...)
+	for _, field := range td.types(primaryType) {
+		value, ok := data[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s.%s is missing", primaryType, field.Name)
+		}
+		encoded, err := td.encodeValue(field.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", primaryType, field.Name, err)
+		}
+		buf = append(buf, encoded...)
+	}
+	return owcrypt.Hash(buf, 0, owcrypt.HASH_ALG_KECCAK256), nil
+}
+
+//DomainSeparator 域分隔符
+func (td *TypedData) DomainSeparator() ([]byte, error) {
+	return td.HashStruct(typedDataDomainType, td.Domain)
+}
+
+//SignHash 签名摘要，keccak256(0x1901 || domainSeparator || hashStruct(message))
+func (td *TypedData) SignHash() ([]byte, error) {
+
+	domainSeparator, err := td.DomainSeparator()
+	if err != nil {
+		return nil, err
+	}
+	messageHash, err := td.HashStruct(td.PrimaryType, td.Message)
+	if err != nil {
+		return nil, err
+	}
+	data := append([]byte{0x19, 0x01}, domainSeparator...)
+	data = append(data, messageHash...)
+	return owcrypt.Hash(data, 0, owcrypt.HASH_ALG_KECCAK256), nil
+}
+
+//types 类型的字段，EIP712Domain未声明时从域数据推断
+func (td *TypedData) types(name string) []TypedDataField {
+	if fields, ok := td.Types[name]; ok || name != typedDataDomainType {
+		return fields
+	}
+	fields := make([]TypedDataField, 0)
+	for _, field := range typedDataDomainFields {
+		if _, ok := td.Domain[field.Name]; ok {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+//dependencies 递归收集引用到的结构体类型
+func (td *TypedData) dependencies(name string, deps map[string]bool) error {
+	if deps[name] {
+		return nil
+	}
+	if _, ok := td.Types[name]; !ok && name != typedDataDomainType {
+		return fmt.Errorf("type: %s is not defined", name)
+	}
+	deps[name] = true
+	for _, field := range td.types(name) {
+		fieldType := field.Type
+		for typedDataArrayRegexp.MatchString(fieldType) {
+			fieldType = typedDataArrayRegexp.FindStringSubmatch(fieldType)[1]
+		}
+		if _, ok := td.Types[fieldType]; ok {
+			if err := td.dependencies(fieldType, deps); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+//encodeValue 字段值编码为32字节
+func (td *TypedData) encodeValue(fieldType string, value interface{}) ([]byte, error) {
+
+	//数组：元素编码拼接后的哈希
+	if m := typedDataArrayRegexp.FindStringSubmatch(fieldType); m != nil {
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s value should be an array", fieldType)
+		}
+		if len(m[2]) > 0 {
+			if n, _ := strconv.Atoi(m[2]); n != len(items) {
+				return nil, fmt.Errorf("%s value has %d items", fieldType, len(items))
+			}
+		}
+		buf := make([]byte, 0, 32*len(items))
+		for _, item := range items {
+			encoded, err := td.encodeValue(m[1], item)
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, encoded...)
+		}
+		return owcrypt.Hash(buf, 0, owcrypt.HASH_ALG_KECCAK256), nil
+	}
+
+	//结构体：结构体哈希
+	if _, ok := td.Types[fieldType]; ok {
+		data, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s value should be an object", fieldType)
+		}
+		return td.HashStruct(fieldType, data)
+	}
+
+	switch fieldType {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("string value should be a string")
+		}
+		return owcrypt.Hash([]byte(s), 0, owcrypt.HASH_ALG_KECCAK256), nil
+	case "bytes":
+		b, err := abiBytesValue(value)
+		if err != nil {
+			return nil, err
+		}
+		return owcrypt.Hash(b, 0, owcrypt.HASH_ALG_KECCAK256), nil
+	case "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("bool value should be a bool")
+		}
+		if b {
+			return abiWord(big.NewInt(1)), nil
+		}
+		return abiWord(big.NewInt(0)), nil
+	case "address":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("address value should be a string")
+		}
+		addr, err := abiAddress(s)
+		if err != nil {
+			return nil, err
+		}
+		return append(make([]byte, 12), addr...), nil
+	case "trcToken":
+		return td.encodeValue("uint256", value)
+	}
+
+	if m := abiBytesRegexp.FindStringSubmatch(fieldType); m != nil {
+		size, _ := strconv.Atoi(m[1])
+		b, err := abiBytesValue(value)
+		if err != nil {
+			return nil, err
+		}
+		if size < 1 || size > 32 || len(b) > size {
+			return nil, fmt.Errorf("%s value has %d bytes", fieldType, len(b))
+		}
+		return append(b, make([]byte, 32-len(b))...), nil
+	}
+
+	if m := abiIntRegexp.FindStringSubmatch(fieldType); m != nil {
+		bits := 256
+		if len(m[2]) > 0 {
+			bits, _ = strconv.Atoi(m[2])
+		}
+		n, err := abiInt(value)
+		if err != nil {
+			return nil, err
+		}
+		if bits < 8 || bits > 256 || bits%8 != 0 {
+			return nil, fmt.Errorf("invalid type: %s", fieldType)
+		}
+		if m[1] == "u" {
+			if n.Sign() < 0 || n.BitLen() > bits {
+				return nil, fmt.Errorf("%s value: %s is out of range", fieldType, n.String())
+			}
+			return abiWord(n), nil
+		}
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+		if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, fmt.Errorf("%s value: %s is out of range", fieldType, n.String())
+		}
+		if n.Sign() < 0 {
+			//补码
+			n = new(big.Int).Add(n, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		return abiWord(n), nil
+	}
+
+	return nil, fmt.Errorf("unsupported type: %s", fieldType)
+}
+
+//SignTypedData 用私钥签名结构化数据，返回0x开头的r+s+v签名，v为27/28
+func (wm *WalletManager) SignTypedData(td *TypedData, privateKey []byte) (string, error) {
+
+	hash, err := td.SignHash()
+	if err != nil {
+		return "", err
+	}
+	signature, v, ret := owcrypt.Signature(privateKey, nil, hash, wm.CurveType())
+	if ret != owcrypt.SUCCESS {
+		return "", fmt.Errorf("typed data sign failed")
+	}
+	signature = append(signature, v+27)
+	return "0x" + hex.EncodeToString(signature), nil
+}
+
+//SignTypedDataWithHDKey 用钱包钥匙按派生路径签名结构化数据
+func (wm *WalletManager) SignTypedDataWithHDKey(key *hdkeystore.HDKey, hdPath string, td *TypedData) (string, error) {
+
+	childKey, err := key.DerivedKeyWithPath(hdPath, wm.CurveType())
+	if err != nil {
+		return "", err
+	}
+	priKeyBytes, err := childKey.GetPrivateKeyBytes()
+	if err != nil {
+		return "", err
+	}
+	return wm.SignTypedData(td, priKeyBytes)
+}
+
+//SignTypedDataWithWallet 用openwallet钱包中地址对应的派生私钥签名结构化数据
+func (wm *WalletManager) SignTypedDataWithWallet(wrapper openwallet.WalletDAI, address string, td *TypedData, password ...string) (string, error) {
+
+	addr, err := wrapper.GetAddress(address)
+	if err != nil {
+		return "", err
+	}
+	key, err := wrapper.HDKey(password...)
+	if err != nil {
+		return "", err
+	}
+	return wm.SignTypedDataWithHDKey(key, addr.HDPath, td)
+}
+
+//VerifyTypedData 验证结构化数据签名，返回签名者的base58地址
+func (wm *WalletManager) VerifyTypedData(td *TypedData, signature string) (string, error) {
+
+	hash, err := td.SignHash()
+	if err != nil {
+		return "", err
+	}
+	return wm.recoverSigner(hash, signature)
+}
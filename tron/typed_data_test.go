@@ -0,0 +1,163 @@
+/*
+ * Copyright 2018 The openwallet Authors
+ * This file is part of the openwallet library.
+ *
+ * The openwallet library is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The openwallet library is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU Lesser General Public License for more details.
+ */
+
+package tron
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	owcrypt "github.com/blocktree/go-owcrypt"
+)
+
+//testMailTypedData EIP-712规范中的Ether Mail示例，地址使用TRON base58和41开头的hex表示
+func testMailTypedData(t *testing.T) *TypedData {
+
+	verifyingContract, _ := EncodeAddress("41cccccccccccccccccccccccccccccccccccccccc", false)
+	cow, _ := EncodeAddress("41cd2a3d9f938e13cd947ec05abc7fe734df8dd826", false)
+	td, err := NewTypedData([]byte(fmt.Sprintf(`{
+		"types": {
+			"EIP712Domain": [
+				{"name": "name", "type": "string"},
+				{"name": "version", "type": "string"},
+				{"name": "chainId", "type": "uint256"},
+				{"name": "verifyingContract", "type": "address"}
+			],
+			"Person": [
+				{"name": "name", "type": "string"},
+				{"name": "wallet", "type": "address"}
+			],
+			"Mail": [
+				{"name": "from", "type": "Person"},
+				{"name": "to", "type": "Person"},
+				{"name": "contents", "type": "string"}
+			]
+		},
+		"primaryType": "Mail",
+		"domain": {"name": "Ether Mail", "version": "1", "chainId": 1, "verifyingContract": "%s"},
+		"message": {
+			"from": {"name": "Cow", "wallet": "%s"},
+			"to": {"name": "Bob", "wallet": "41bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"},
+			"contents": "Hello, Bob!"
+		}
+	}`, verifyingContract, cow)))
+	if err != nil {
+		t.Fatalf("NewTypedData failed: %v", err)
+	}
+	return td
+}
+
+func TestTypedData_MailVector(t *testing.T) {
+
+	td := testMailTypedData(t)
+
+	encodeType, _ := td.EncodeType("Mail")
+	if encodeType != "Mail(Person from,Person to,string contents)Person(string name,address wallet)" {
+		t.Errorf("EncodeType = %s", encodeType)
+	}
+
+	tests := []struct {
+		name   string
+		hash   func() ([]byte, error)
+		expect string
+	}{
+		{"typeHash", func() ([]byte, error) { return td.TypeHash("Mail") }, "a0cedeb2dc280ba39b857546d74f5549c3a1d7bdc2dd96bf881f76108e23dac2"},
+		{"hashStruct", func() ([]byte, error) { return td.HashStruct("Mail", td.Message) }, "c52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e"},
+		{"domainSeparator", td.DomainSeparator, "f2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f"},
+		{"signHash", td.SignHash, "be609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"},
+	}
+	for _, test := range tests {
+		hash, err := test.hash()
+		if err != nil {
+			t.Errorf("%s failed: %v", test.name, err)
+			continue
+		}
+		if hex.EncodeToString(hash) != test.expect {
+			t.Errorf("%s = %x, expected %s", test.name, hash, test.expect)
+		}
+	}
+
+	//规范中私钥keccak256("cow")的签名
+	cow, _ := EncodeAddress("41cd2a3d9f938e13cd947ec05abc7fe734df8dd826", false)
+	signature := "0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d" +
+		"07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b91562" + "1c"
+	if address, err := tw.VerifyTypedData(td, signature); err != nil || address != cow {
+		t.Errorf("VerifyTypedData = %s, %v, expected %s", address, err, cow)
+	}
+
+	priKey := owcrypt.Hash([]byte("cow"), 0, owcrypt.HASH_ALG_KECCAK256)
+	signed, err := tw.SignTypedData(td, priKey)
+	if err != nil {
+		t.Fatalf("SignTypedData failed: %v", err)
+	}
+	if address, err := tw.VerifyTypedData(td, signed); err != nil || address != cow {
+		t.Errorf("VerifyTypedData = %s, %v, expected %s", address, err, cow)
+	}
+
+	td.Message["contents"] = "Hello, Alice!"
+	if address, _ := tw.VerifyTypedData(td, signed); address == cow {
+		t.Errorf("tampered message is verified")
+	}
+}
+
+func TestTypedData_EncodeValue(t *testing.T) {
+
+	td := testMailTypedData(t)
+	td.Types["Group"] = []TypedDataField{
+		{Name: "members", Type: "Person[]"},
+		{Name: "ids", Type: "uint8[2]"},
+		{Name: "delta", Type: "int8"},
+	}
+	people := td.Message["from"].(map[string]interface{})
+	group := map[string]interface{}{
+		"members": []interface{}{people, people},
+		"ids":     []interface{}{"1", "0x02"},
+		"delta":   -1,
+	}
+
+	encodeType, _ := td.EncodeType("Group")
+	if encodeType != "Group(Person[] members,uint8[2] ids,int8 delta)Person(string name,address wallet)" {
+		t.Errorf("EncodeType = %s", encodeType)
+	}
+
+	//数组为元素编码拼接后的哈希
+	member, _ := td.HashStruct("Person", people)
+	members, err := td.encodeValue("Person[]", group["members"])
+	if err != nil {
+		t.Fatalf("encodeValue failed: %v", err)
+	}
+	if expect := owcrypt.Hash(append(member, member...), 0, owcrypt.HASH_ALG_KECCAK256); !bytes.Equal(members, expect) {
+		t.Errorf("Person[] = %x, expected %x", members, expect)
+	}
+
+	//负数为补码
+	delta, _ := td.encodeValue("int8", -1)
+	if !bytes.Equal(delta, bytes.Repeat([]byte{0xff}, 32)) {
+		t.Errorf("int8(-1) = %x", delta)
+	}
+
+	if _, err := td.HashStruct("Group", group); err != nil {
+		t.Errorf("HashStruct failed: %v", err)
+	}
+	group["ids"] = []interface{}{"1"}
+	if _, err := td.HashStruct("Group", group); err == nil {
+		t.Errorf("fixed size array with wrong length is accepted")
+	}
+	if _, err := td.encodeValue("uint8", 256); err == nil {
+		t.Errorf("uint8 overflow is accepted")
+	}
+}